@@ -0,0 +1,45 @@
+// Package cache 提供带过期时间的键值缓存，包含内存（LRU）和文件两种实现
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Cache 键值缓存，ttl <= 0 表示永不过期
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+	Clear() int // 删除所有条目，返回删除的条目数
+}
+
+// Key 由多个部分计算内容寻址的缓存键（SHA-256 十六进制）
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0}) // 分隔符，避免 "ab"+"c" 与 "a"+"bc" 冲突
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NormalizeText 规范化文本：去掉首尾空白并把连续空白合并为一个空格
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// expiry 计算过期时间，ttl <= 0 返回零值表示永不过期
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// expired 判断是否已过期
+func expired(now, expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
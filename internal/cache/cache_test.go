@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_LRUAndTTL(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewMemoryCache(2)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), 0)
+	c.Get("a") // a 最近使用，b 应被淘汰
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if value, ok := c.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("Expected a to be cached, got %q, %v", value, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to expire")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("Expected entry without TTL to remain")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected 1 entry, got %d", c.Len())
+	}
+
+	if removed := c.Clear(); removed != 1 || c.Len() != 0 {
+		t.Errorf("Expected Clear to remove 1 entry, removed %d, %d left", removed, c.Len())
+	}
+}
+
+func TestFileCache_PersistsAcrossInstances(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	first.Set("key", []byte(`{"content":"ok"}`), time.Hour)
+
+	second, _ := NewFileCache(dir)
+	if value, ok := second.Get("key"); !ok || string(value) != `{"content":"ok"}` {
+		t.Errorf("Expected value from disk, got %q, %v", value, ok)
+	}
+
+	second.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, ok := second.Get("key"); ok {
+		t.Error("Expected expired entry to be dropped")
+	}
+	if _, ok := first.Get("key"); ok {
+		t.Error("Expected expired entry file to be deleted")
+	}
+
+	first.Set("a", []byte("1"), 0)
+	first.Set("b", []byte("2"), 0)
+	if removed := second.Clear(); removed != 2 {
+		t.Errorf("Expected Clear to remove 2 files, removed %d", removed)
+	}
+	if _, ok := first.Get("a"); ok {
+		t.Error("Expected cleared entry to be gone")
+	}
+}
+
+func TestKeyAndNormalizeText(t *testing.T) {
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Error("Expected separator to distinguish key parts")
+	}
+	if NormalizeText("  杭州\n\n 3天 ") != "杭州 3天" {
+		t.Errorf("Unexpected normalized text %q", NormalizeText("  杭州\n\n 3天 "))
+	}
+}
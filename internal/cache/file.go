@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileCache 持久化缓存，每个条目保存为目录下的一个JSON文件，重启后仍然有效
+type FileCache struct {
+	dir string
+	now func() time.Time
+}
+
+type fileEntry struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Value     []byte    `json:"value"`
+}
+
+// NewFileCache 创建文件缓存，目录不存在时自动创建
+func NewFileCache(dir string) (*FileCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	return &FileCache{dir: dir, now: time.Now}, nil
+}
+
+// Get 获取缓存，过期或损坏的条目会被删除
+func (c *FileCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry fileEntry
+	if err := json.Unmarshal(data, &entry); err != nil || expired(c.now(), entry.ExpiresAt) {
+		c.Delete(key)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Set 写入缓存，先写临时文件再重命名，避免读到写了一半的条目
+func (c *FileCache) Set(key string, value []byte, ttl time.Duration) {
+	data, err := json.Marshal(fileEntry{ExpiresAt: expiry(c.now(), ttl), Value: value})
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		log.Printf("写入缓存失败: %v", err)
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		log.Printf("写入缓存失败: %v %v", writeErr, closeErr)
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("写入缓存失败: %v", err)
+	}
+}
+
+// Delete 删除缓存
+func (c *FileCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// Clear 删除目录下的所有缓存文件
+func (c *FileCache) Clear() int {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, file := range files {
+		if os.Remove(file) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// path 缓存文件路径，key 经过哈希后再作为文件名，避免非法字符
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.dir, Key(key)+".json")
+}
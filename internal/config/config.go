@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +20,18 @@ type Config struct {
 
 	// JWT配置
 	JWT JWTConfig `yaml:"jwt"`
+
+	// 管理员配置
+	Admin AdminConfig `yaml:"admin"`
+
+	// 提示词模板配置
+	Prompts PromptsConfig `yaml:"prompts"`
+
+	// 用户输入与模型输出的安全检查配置
+	Guardrails GuardrailsConfig `yaml:"guardrails"`
+
+	// 行程生成配置
+	Planner PlannerConfig `yaml:"planner"`
 }
 
 type ServerConfig struct {
@@ -41,6 +54,9 @@ type APIConfig struct {
 
 	// 高德地图API
 	Amap AmapConfig `yaml:"amap"`
+
+	// OpenStreetMap 兼容的地图服务，用于中国以外的目的地
+	OSM OSMConfig `yaml:"osm"`
 }
 
 type XunfeiConfig struct {
@@ -54,10 +70,68 @@ type OpenAIConfig struct {
 	BaseURL        string `yaml:"base_url"`
 	Model          string `yaml:"model"`
 	TimeoutSeconds int    `yaml:"timeout_seconds"`
+
+	// 模型价格表，key 为模型名，"default" 作为未匹配模型的兜底价格
+	Pricing         map[string]ModelPricing `yaml:"pricing"`
+	PricingCurrency string                  `yaml:"pricing_currency"`
+
+	// 使用服务端Key时的每用户配额
+	Quota LLMQuotaConfig `yaml:"quota"`
+
+	// 相同请求的响应缓存
+	Cache LLMCacheConfig `yaml:"cache"`
+}
+
+// LLMCacheConfig LLM响应缓存配置
+type LLMCacheConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Backend    string `yaml:"backend"`     // memory（默认）或 file
+	Dir        string `yaml:"dir"`         // file 后端的缓存目录
+	TTLSeconds int    `yaml:"ttl_seconds"` // 缓存有效期，默认 86400
+	MaxEntries int    `yaml:"max_entries"` // memory 后端的最大条目数，默认 1000
+}
+
+// LLMQuotaConfig 每用户LLM配额，0 表示不限制
+type LLMQuotaConfig struct {
+	DailyRequests   int `yaml:"daily_requests"`
+	DailyTokens     int `yaml:"daily_tokens"`
+	MonthlyRequests int `yaml:"monthly_requests"`
+	MonthlyTokens   int `yaml:"monthly_tokens"`
+}
+
+// ModelPricing 模型价格（每百万 tokens）
+type ModelPricing struct {
+	PromptPerMillion     float64 `yaml:"prompt_per_million"`
+	CompletionPerMillion float64 `yaml:"completion_per_million"`
 }
 
 type AmapConfig struct {
-	APIKey string `yaml:"api_key"`
+	APIKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"` // 默认 https://restapi.amap.com/v3
+
+	// 地理编码、POI搜索结果缓存
+	Cache MapCacheConfig `yaml:"cache"`
+}
+
+// MapCacheConfig 地图查询结果缓存配置，每类查询单独缓存
+type MapCacheConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	Backend           string `yaml:"backend"`             // memory（默认）或 file
+	Dir               string `yaml:"dir"`                 // file 后端的缓存目录，默认 data/map-cache
+	MaxEntries        int    `yaml:"max_entries"`         // memory 后端每类查询的最大条目数，默认 5000
+	GeocodeTTLSeconds int    `yaml:"geocode_ttl_seconds"` // 地理编码结果有效期，默认 30 天
+	POITTLSeconds     int    `yaml:"poi_ttl_seconds"`     // POI搜索结果有效期，默认 7 天
+}
+
+// OSMConfig Nominatim（地理编码、POI搜索）与 OSRM（路线规划、距离计算）兼容服务的配置
+type OSMConfig struct {
+	Disabled         bool   `yaml:"disabled"`           // 关闭后所有目的地都使用高德
+	NominatimBaseURL string `yaml:"nominatim_base_url"` // 默认 https://nominatim.openstreetmap.org
+	OSRMBaseURL      string `yaml:"osrm_base_url"`      // 默认 https://router.project-osrm.org
+	UserAgent        string `yaml:"user_agent"`         // Nominatim 要求标识调用方，默认 ai-travel-planner
+
+	// RequestIntervalMs 两次请求的最小间隔（毫秒）。使用公共 Nominatim 时默认 1000（其使用政策要求每秒不超过一次）
+	RequestIntervalMs int `yaml:"request_interval_ms"`
 }
 
 type JWTConfig struct {
@@ -65,6 +139,40 @@ type JWTConfig struct {
 	ExpireTime int    `yaml:"expire_time"` // 小时
 }
 
+type PromptsConfig struct {
+	Dir                   string                    `yaml:"dir"`                     // 模板目录，结构为 <dir>/<name>/<version>.tmpl
+	ReloadIntervalSeconds int                       `yaml:"reload_interval_seconds"` // 热加载检查间隔，负数表示关闭
+	Versions              map[string]string         `yaml:"versions"`                // 固定使用的模板版本
+	Experiments           map[string]map[string]int `yaml:"experiments"`             // A/B 实验：模板名 -> 版本 -> 权重
+}
+
+type GuardrailsConfig struct {
+	Mode           string   `yaml:"mode"`             // block（默认）拦截违规请求；log 只记录不拦截
+	MaxInputLength int      `yaml:"max_input_length"` // 单个用户输入字段的最大字符数，超出部分截断
+	BlockedTerms   []string `yaml:"blocked_terms"`    // 模型输出中不允许出现的词（不区分大小写）
+}
+
+type PlannerConfig struct {
+	// OfflineFallback 用户Key和服务端Key都不可用（未配置或模型服务出错）时，改用离线规则规划器生成行程
+	OfflineFallback bool `yaml:"offline_fallback"`
+
+	// OverBudget 生成的行程费用超出预算时的处理方式：warn（默认）只记录提醒；rebalance 先让模型压缩费用
+	OverBudget string `yaml:"over_budget"`
+
+	// Feasibility 行程可行性检查（相邻活动之间的路上时间）
+	Feasibility FeasibilityConfig `yaml:"feasibility"`
+}
+
+// FeasibilityConfig 行程可行性检查配置
+type FeasibilityConfig struct {
+	MaxDailyTravelMinutes int `yaml:"max_daily_travel_minutes"` // 每天路上时间的上限，超过时提醒，默认 180
+	WalkingDistanceMeters int `yaml:"walking_distance_meters"`  // 直线距离不超过该值时按步行计算，默认 1500
+}
+
+type AdminConfig struct {
+	Emails []string `yaml:"emails"` // 拥有管理员权限的用户邮箱
+}
+
 var globalConfig *Config
 
 // Load 从 YAML 文件加载配置
@@ -141,6 +249,67 @@ func setDefaults(cfg *Config) {
 	if cfg.APIs.OpenAI.TimeoutSeconds == 0 {
 		cfg.APIs.OpenAI.TimeoutSeconds = 120
 	}
+	if cfg.APIs.OpenAI.PricingCurrency == "" {
+		cfg.APIs.OpenAI.PricingCurrency = "USD"
+	}
+	if cfg.APIs.OpenAI.Cache.Backend == "" {
+		cfg.APIs.OpenAI.Cache.Backend = "memory"
+	}
+	if cfg.APIs.OpenAI.Cache.Dir == "" {
+		cfg.APIs.OpenAI.Cache.Dir = "data/llm-cache"
+	}
+	if cfg.APIs.OpenAI.Cache.TTLSeconds == 0 {
+		cfg.APIs.OpenAI.Cache.TTLSeconds = 86400
+	}
+	if cfg.APIs.OpenAI.Cache.MaxEntries == 0 {
+		cfg.APIs.OpenAI.Cache.MaxEntries = 1000
+	}
+
+	// 地图缓存默认值
+	if cfg.APIs.Amap.Cache.Backend == "" {
+		cfg.APIs.Amap.Cache.Backend = "memory"
+	}
+	if cfg.APIs.Amap.Cache.Dir == "" {
+		cfg.APIs.Amap.Cache.Dir = "data/map-cache"
+	}
+	if cfg.APIs.Amap.Cache.MaxEntries == 0 {
+		cfg.APIs.Amap.Cache.MaxEntries = 5000
+	}
+	if cfg.APIs.Amap.Cache.GeocodeTTLSeconds == 0 {
+		cfg.APIs.Amap.Cache.GeocodeTTLSeconds = 30 * 86400
+	}
+	if cfg.APIs.Amap.Cache.POITTLSeconds == 0 {
+		cfg.APIs.Amap.Cache.POITTLSeconds = 7 * 86400
+	}
+
+	// 行程可行性检查默认值
+	if cfg.Planner.Feasibility.MaxDailyTravelMinutes == 0 {
+		cfg.Planner.Feasibility.MaxDailyTravelMinutes = 180
+	}
+	if cfg.Planner.Feasibility.WalkingDistanceMeters == 0 {
+		cfg.Planner.Feasibility.WalkingDistanceMeters = 1500
+	}
+
+	// 提示词模板默认值
+	if cfg.Prompts.Dir == "" {
+		cfg.Prompts.Dir = "prompts"
+	}
+	if cfg.Prompts.ReloadIntervalSeconds == 0 {
+		cfg.Prompts.ReloadIntervalSeconds = 10
+	}
+
+	// 安全检查默认值
+	if cfg.Guardrails.Mode == "" {
+		cfg.Guardrails.Mode = "block"
+	}
+	if cfg.Guardrails.MaxInputLength == 0 {
+		cfg.Guardrails.MaxInputLength = 2000
+	}
+
+	// 行程生成默认值
+	if cfg.Planner.OverBudget == "" {
+		cfg.Planner.OverBudget = "warn"
+	}
 
 	// JWT 配置默认值
 	if cfg.JWT.Secret == "" {
@@ -162,8 +331,8 @@ func validateConfig(cfg *Config) error {
 	if cfg.Database.SupabaseSecret == "" {
 		return fmt.Errorf("数据库配置错误: supabase_secret 不能为空")
 	}
-	if cfg.APIs.OpenAI.APIKey == "" {
-		return fmt.Errorf("OpenAI API 配置错误: api_key 不能为空")
+	if cfg.APIs.OpenAI.APIKey == "" && !cfg.Planner.OfflineFallback {
+		return fmt.Errorf("OpenAI API 配置错误: api_key 不能为空（或开启 planner.offline_fallback）")
 	}
 	return nil
 }
@@ -182,3 +351,16 @@ func (c *Config) GetPort() string {
 func (c *Config) GetMode() string {
 	return c.Server.Mode
 }
+
+// IsAdmin 判断邮箱是否属于管理员
+func (c *Config) IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, adminEmail := range c.Admin.Emails {
+		if strings.EqualFold(adminEmail, email) {
+			return true
+		}
+	}
+	return false
+}
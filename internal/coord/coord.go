@@ -0,0 +1,182 @@
+// Package coord 提供 WGS-84、GCJ-02、BD-09 坐标系之间的转换。
+// GPS 设备和 OpenStreetMap 使用 WGS-84，高德等国内地图使用 GCJ-02（在中国境内有数百米偏移），百度地图使用 BD-09
+package coord
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 坐标系
+const (
+	WGS84 = "wgs84"
+	GCJ02 = "gcj02"
+	BD09  = "bd09"
+)
+
+const (
+	semiMajorAxis   = 6378245.0           // GCJ-02 使用的克拉索夫斯基椭球长半轴
+	eccentricitySq  = 0.00669342162296594 // 椭球第一偏心率的平方
+	bdFactor        = math.Pi * 3000.0 / 180.0
+	inverseAccuracy = 1e-9 // GCJ-02 反算的精度（度），约 0.1 毫米
+)
+
+// Normalize 规范化坐标系名称，接受 "WGS-84"、"gps"、"GCJ-02"、"amap"、"BD-09"、"baidu" 等写法；空字符串表示未知
+func Normalize(name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.NewReplacer("-", "", "_", "", " ", "").Replace(key)
+	switch key {
+	case "":
+		return "", nil
+	case "wgs84", "gps", "osm":
+		return WGS84, nil
+	case "gcj02", "gcj", "mars", "amap", "gaode":
+		return GCJ02, nil
+	case "bd09", "bd09ll", "baidu":
+		return BD09, nil
+	}
+	return "", fmt.Errorf("unsupported coordinate system %q, supported: %s, %s, %s", name, WGS84, GCJ02, BD09)
+}
+
+// Convert 将坐标从 from 坐标系转换到 to 坐标系。任一坐标系为空（未知）或相同时原样返回
+func Convert(lng, lat float64, from, to string) (float64, float64) {
+	if from == "" || to == "" || from == to {
+		return lng, lat
+	}
+	switch from {
+	case WGS84:
+		lng, lat = WGS84ToGCJ02(lng, lat)
+	case BD09:
+		lng, lat = BD09ToGCJ02(lng, lat)
+	}
+	switch to {
+	case WGS84:
+		return GCJ02ToWGS84(lng, lat)
+	case BD09:
+		return GCJ02ToBD09(lng, lat)
+	}
+	return lng, lat
+}
+
+// box 经纬度范围
+type box struct {
+	minLng, maxLng, minLat, maxLat float64
+}
+
+func (b box) contains(lng, lat float64) bool {
+	return lng >= b.minLng && lng <= b.maxLng && lat >= b.minLat && lat <= b.maxLat
+}
+
+// 中国境内的范围由几个矩形拼成，再去掉其中的周边国家和地区。矩形沿国界外侧划定，
+// 边境城市（如通化、东兴、仲巴）都在范围内，代价是少量紧邻国界的境外地点也会被算作境内
+var (
+	chinaRegions = []box{
+		{79.4462, 96.3300, 42.8899, 49.2204},   // 新疆北部
+		{109.6872, 135.0002, 39.3742, 54.1415}, // 东北、内蒙古东部
+		{73.1246, 124.1433, 29.5297, 42.8899},  // 西北、华北、华东北部
+		{82.9684, 97.0352, 26.7186, 29.5297},   // 西藏南部
+		{97.0253, 124.3674, 20.4141, 29.5297},  // 西南、华南、华东南部
+		{107.9758, 111.7441, 17.8715, 20.4141}, // 海南
+	}
+	chinaExcludedRegions = []box{
+		{119.9213, 122.4976, 21.7850, 25.3986}, // 台湾本岛
+		{119.3000, 119.8000, 23.1000, 23.8000}, // 澎湖
+		{101.8652, 106.6650, 20.0988, 22.2840}, // 越南北部、老挝北部
+		{106.4525, 108.0510, 20.4878, 21.5422}, // 越南东北部
+		{109.0323, 119.1270, 50.3257, 55.8175}, // 俄罗斯外贝加尔
+		{127.4568, 137.0227, 49.5574, 55.8175}, // 俄罗斯阿穆尔
+		{131.2662, 137.0227, 42.5692, 44.8922}, // 俄罗斯滨海
+	}
+)
+
+// InChina 粗略判断坐标是否在使用 GCJ-02 的中国境内（含港澳，不含台湾）；也用于选择地图服务
+func InChina(lng, lat float64) bool {
+	for _, b := range chinaExcludedRegions {
+		if b.contains(lng, lat) {
+			return false
+		}
+	}
+	for _, b := range chinaRegions {
+		if b.contains(lng, lat) {
+			return true
+		}
+	}
+	return false
+}
+
+// OutOfChina 是否在中国境外，境外的 GCJ-02 与 WGS-84 相同
+func OutOfChina(lng, lat float64) bool {
+	return !InChina(lng, lat)
+}
+
+// WGS84ToGCJ02 WGS-84 转 GCJ-02
+func WGS84ToGCJ02(lng, lat float64) (float64, float64) {
+	if OutOfChina(lng, lat) {
+		return lng, lat
+	}
+	dLng, dLat := gcjOffset(lng, lat)
+	return lng + dLng, lat + dLat
+}
+
+// GCJ02ToWGS84 GCJ-02 转 WGS-84。GCJ-02 没有解析逆变换，迭代求解到 1e-9 度
+func GCJ02ToWGS84(lng, lat float64) (float64, float64) {
+	if OutOfChina(lng, lat) {
+		return lng, lat
+	}
+	wgsLng, wgsLat := lng, lat
+	for i := 0; i < 30; i++ {
+		gcjLng, gcjLat := WGS84ToGCJ02(wgsLng, wgsLat)
+		dLng, dLat := gcjLng-lng, gcjLat-lat
+		wgsLng, wgsLat = wgsLng-dLng, wgsLat-dLat
+		if math.Abs(dLng) < inverseAccuracy && math.Abs(dLat) < inverseAccuracy {
+			break
+		}
+	}
+	return wgsLng, wgsLat
+}
+
+// GCJ02ToBD09 GCJ-02 转 BD-09
+func GCJ02ToBD09(lng, lat float64) (float64, float64) {
+	z := math.Sqrt(lng*lng+lat*lat) + 0.00002*math.Sin(lat*bdFactor)
+	theta := math.Atan2(lat, lng) + 0.000003*math.Cos(lng*bdFactor)
+	return z*math.Cos(theta) + 0.0065, z*math.Sin(theta) + 0.006
+}
+
+// BD09ToGCJ02 BD-09 转 GCJ-02
+func BD09ToGCJ02(lng, lat float64) (float64, float64) {
+	x, y := lng-0.0065, lat-0.006
+	z := math.Sqrt(x*x+y*y) - 0.00002*math.Sin(y*bdFactor)
+	theta := math.Atan2(y, x) - 0.000003*math.Cos(x*bdFactor)
+	return z * math.Cos(theta), z * math.Sin(theta)
+}
+
+// gcjOffset GCJ-02 相对 WGS-84 的经纬度偏移
+func gcjOffset(lng, lat float64) (float64, float64) {
+	x, y := lng-105.0, lat-35.0
+	dLat := transformLat(x, y)
+	dLng := transformLng(x, y)
+	radLat := lat / 180.0 * math.Pi
+	magic := math.Sin(radLat)
+	magic = 1 - eccentricitySq*magic*magic
+	sqrtMagic := math.Sqrt(magic)
+	dLat = (dLat * 180.0) / ((semiMajorAxis * (1 - eccentricitySq)) / (magic * sqrtMagic) * math.Pi)
+	dLng = (dLng * 180.0) / (semiMajorAxis / sqrtMagic * math.Cos(radLat) * math.Pi)
+	return dLng, dLat
+}
+
+func transformLat(x, y float64) float64 {
+	ret := -100.0 + 2.0*x + 3.0*y + 0.2*y*y + 0.1*x*y + 0.2*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(y*math.Pi) + 40.0*math.Sin(y/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (160.0*math.Sin(y/12.0*math.Pi) + 320*math.Sin(y*math.Pi/30.0)) * 2.0 / 3.0
+	return ret
+}
+
+func transformLng(x, y float64) float64 {
+	ret := 300.0 + x + 2.0*y + 0.1*x*x + 0.1*x*y + 0.1*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(x*math.Pi) + 40.0*math.Sin(x/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (150.0*math.Sin(x/12.0*math.Pi) + 300.0*math.Sin(x/30.0*math.Pi)) * 2.0 / 3.0
+	return ret
+}
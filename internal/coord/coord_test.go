@@ -0,0 +1,128 @@
+package coord
+
+import (
+	"math"
+	"testing"
+)
+
+// distanceMeters 两点之间的近似距离（米）
+func distanceMeters(lng1, lat1, lng2, lat2 float64) float64 {
+	x := (lng2 - lng1) * 111320 * math.Cos(lat1*math.Pi/180)
+	y := (lat2 - lat1) * 110540
+	return math.Hypot(x, y)
+}
+
+func TestWGS84AndGCJ02(t *testing.T) {
+	// 天安门（WGS-84）
+	lng, lat := 116.391275, 39.906217
+	gcjLng, gcjLat := WGS84ToGCJ02(lng, lat)
+	if offset := distanceMeters(lng, lat, gcjLng, gcjLat); offset < 400 || offset > 700 {
+		t.Errorf("Expected a GCJ-02 offset of several hundred meters in Beijing, got %.0f m", offset)
+	}
+	if gcjLng <= lng || gcjLat <= lat {
+		t.Errorf("GCJ-02 should shift Beijing to the north-east, got %f,%f", gcjLng, gcjLat)
+	}
+
+	backLng, backLat := GCJ02ToWGS84(gcjLng, gcjLat)
+	if distanceMeters(lng, lat, backLng, backLat) > 0.01 {
+		t.Errorf("Round trip drifted: %f,%f", backLng, backLat)
+	}
+
+	// 边境城市同样偏移
+	if lng, lat := WGS84ToGCJ02(125.94, 41.73); distanceMeters(125.94, 41.73, lng, lat) < 100 {
+		t.Errorf("Expected a GCJ-02 offset in Tonghua, got %f,%f", lng, lat)
+	}
+
+	// 境外坐标不偏移，包括落在中国经纬度范围内的首尔
+	for _, point := range [][2]float64{{139.767125, 35.681236}, {126.977969, 37.566535}} {
+		if lng, lat := WGS84ToGCJ02(point[0], point[1]); lng != point[0] || lat != point[1] {
+			t.Errorf("Coordinates outside China should not change, got %f,%f", lng, lat)
+		}
+		if lng, lat := GCJ02ToWGS84(point[0], point[1]); lng != point[0] || lat != point[1] {
+			t.Errorf("Coordinates outside China should not change, got %f,%f", lng, lat)
+		}
+	}
+}
+
+func TestInChina(t *testing.T) {
+	cases := []struct {
+		name     string
+		lng, lat float64
+		expected bool
+	}{
+		{"杭州", 120.15, 30.28, true},
+		{"拉萨", 91.11, 29.65, true},
+		{"哈尔滨", 126.63, 45.75, true},
+		{"三亚", 109.51, 18.25, true},
+		{"厦门", 118.09, 24.48, true},
+		{"通化", 125.94, 41.73, true},
+		{"白山", 126.42, 41.94, true},
+		{"集安", 126.19, 41.13, true},
+		{"东兴", 107.97, 21.55, true},
+		{"凭祥", 106.76, 22.09, true},
+		{"仲巴", 84.03, 29.77, true},
+		{"阿合奇", 78.45, 40.94, true},
+		{"富蕴", 89.52, 46.99, true},
+		{"珲春", 130.36, 42.87, true},
+		{"平壤", 125.75, 39.03, false},
+		{"首尔", 126.98, 37.57, false},
+		{"东京", 139.69, 35.69, false},
+		{"乌兰巴托", 106.91, 47.92, false},
+		{"曼谷", 100.50, 13.75, false},
+		{"河内", 105.85, 21.03, false},
+		{"新加坡", 103.82, 1.35, false},
+		{"马尼拉", 120.98, 14.60, false},
+		{"台北", 121.56, 25.04, false},
+		{"巴黎", 2.35, 48.86, false},
+	}
+	for _, c := range cases {
+		if got := InChina(c.lng, c.lat); got != c.expected {
+			t.Errorf("InChina(%s) = %v, expected %v", c.name, got, c.expected)
+		}
+	}
+}
+
+func TestBD09(t *testing.T) {
+	lng, lat := 116.397499, 39.908722
+	bdLng, bdLat := GCJ02ToBD09(lng, lat)
+	if offset := distanceMeters(lng, lat, bdLng, bdLat); offset < 500 || offset > 1000 {
+		t.Errorf("Unexpected BD-09 offset %.0f m", offset)
+	}
+	if backLng, backLat := BD09ToGCJ02(bdLng, bdLat); distanceMeters(lng, lat, backLng, backLat) > 0.5 {
+		t.Errorf("BD-09 round trip drifted: %f,%f", backLng, backLat)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	lng, lat := 120.153576, 30.287459
+	for _, from := range []string{WGS84, GCJ02, BD09} {
+		for _, to := range []string{WGS84, GCJ02, BD09} {
+			convertedLng, convertedLat := Convert(lng, lat, from, to)
+			backLng, backLat := Convert(convertedLng, convertedLat, to, from)
+			if distanceMeters(lng, lat, backLng, backLat) > 0.5 {
+				t.Errorf("%s -> %s -> %s drifted to %f,%f", from, to, from, backLng, backLat)
+			}
+		}
+	}
+
+	wgsLng, wgsLat := Convert(lng, lat, BD09, WGS84)
+	gcjLng, gcjLat := BD09ToGCJ02(lng, lat)
+	if expectedLng, expectedLat := GCJ02ToWGS84(gcjLng, gcjLat); wgsLng != expectedLng || wgsLat != expectedLat {
+		t.Errorf("BD-09 to WGS-84 should go through GCJ-02")
+	}
+	if convertedLng, convertedLat := Convert(lng, lat, "", WGS84); convertedLng != lng || convertedLat != lat {
+		t.Errorf("Unknown source datum should be left unchanged")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{"WGS-84": WGS84, "gps": WGS84, "GCJ_02": GCJ02, "amap": GCJ02, "BD-09": BD09, "baidu": BD09, " ": ""}
+	for name, expected := range cases {
+		if got, err := Normalize(name); err != nil || got != expected {
+			t.Errorf("Normalize(%q) = %q, %v, expected %q", name, got, err, expected)
+		}
+	}
+	if _, err := Normalize("cgcs2000"); err == nil {
+		t.Errorf("Expected an error for an unsupported coordinate system")
+	}
+}
@@ -0,0 +1,37 @@
+package fakeopenai
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+// Run 以子命令方式启动假服务，例如：
+//
+//	go run . fake-openai -addr :8081 -fixtures fixtures/fakeopenai -fault slow -delay 5s
+//
+// 然后将 apis.openai.base_url 配置为 http://localhost:8081/v1
+func Run(args []string) error {
+	flags := flag.NewFlagSet("fake-openai", flag.ContinueOnError)
+	addr := flags.String("addr", ":8081", "监听地址")
+	dir := flags.String("fixtures", "fixtures/fakeopenai", "样例回复目录")
+	faultName := flags.String("fault", "", "对所有回复注入的故障：malformed_json、code_fence、rate_limit、server_error、slow")
+	delay := flags.Duration("delay", DefaultSlowDelay, "slow 故障的延迟")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fault, err := ParseFault(*faultName)
+	if err != nil {
+		return err
+	}
+
+	server := NewServer()
+	if err := server.LoadFixtures(*dir); err != nil {
+		return err
+	}
+	server.SetFault(fault, *delay)
+
+	log.Printf("假 OpenAI 服务监听 %s（样例目录 %s，故障 %q）", *addr, *dir, fault)
+	return http.ListenAndServe(*addr, server)
+}
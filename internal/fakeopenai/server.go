@@ -0,0 +1,310 @@
+// Package fakeopenai 本地的 OpenAI 兼容 chat completions 服务，返回脚本或样例数据中的回复，
+// 并可注入故障（格式错误的JSON、代码块、429、慢响应），用于测试和离线演示
+package fakeopenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fault 注入的故障类型
+type Fault string
+
+const (
+	FaultNone          Fault = ""
+	FaultMalformedJSON Fault = "malformed_json" // 回复内容被截断，不是合法JSON
+	FaultCodeFence     Fault = "code_fence"     // 回复内容包裹在 ```json 代码块中
+	FaultRateLimit     Fault = "rate_limit"     // 返回 429 和 Retry-After
+	FaultServerError   Fault = "server_error"   // 返回 500
+	FaultSlow          Fault = "slow"           // 延迟 Delay 后再正常返回
+)
+
+// DefaultSlowDelay 慢响应故障未指定延迟时的默认延迟
+const DefaultSlowDelay = 3 * time.Second
+
+// DefaultContent 没有脚本回复也没有匹配的样例时返回的内容
+const DefaultContent = "{}"
+
+// ParseFault 解析故障名称，空字符串表示不注入故障
+func ParseFault(name string) (Fault, error) {
+	switch fault := Fault(strings.TrimSpace(name)); fault {
+	case FaultNone, FaultMalformedJSON, FaultCodeFence, FaultRateLimit, FaultServerError, FaultSlow:
+		return fault, nil
+	}
+	return FaultNone, fmt.Errorf("unknown fault %q", name)
+}
+
+// ToolCall 脚本回复中的工具调用
+type ToolCall struct {
+	Name      string
+	Arguments string // JSON字符串
+}
+
+// Response 一次脚本化的回复
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+	Fault     Fault
+	Delay     time.Duration // FaultSlow 的延迟，为 0 时使用服务的默认延迟
+}
+
+// Fixture 按提示词内容匹配的样例回复
+type Fixture struct {
+	Name    string          `json:"name"`
+	Match   []string        `json:"match"`   // 最后一条用户消息包含全部字符串时命中
+	Content json.RawMessage `json:"content"` // JSON字符串按原文返回，其余JSON值序列化后返回
+}
+
+// text 返回样例的回复内容
+func (f Fixture) text() string {
+	var content string
+	if err := json.Unmarshal(f.Content, &content); err == nil {
+		return content
+	}
+	return string(f.Content)
+}
+
+// matches 判断样例是否匹配提示词
+func (f Fixture) matches(prompt string) bool {
+	if len(f.Match) == 0 {
+		return false
+	}
+	for _, match := range f.Match {
+		if !strings.Contains(prompt, match) {
+			return false
+		}
+	}
+	return true
+}
+
+// Message 请求中的消息
+type Message struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// ChatRequest 服务收到的请求，供测试断言
+type ChatRequest struct {
+	Model         string            `json:"model"`
+	Messages      []Message         `json:"messages"`
+	Tools         []json.RawMessage `json:"tools,omitempty"`
+	Authorization string            `json:"-"`
+}
+
+// Server 假的 chat completions 服务，实现 http.Handler，可直接用于 httptest.NewServer
+type Server struct {
+	mutex    sync.Mutex
+	script   []Response
+	fixtures []Fixture
+	fault    Fault
+	delay    time.Duration
+	requests []ChatRequest
+}
+
+// NewServer 创建假服务
+func NewServer() *Server {
+	return &Server{delay: DefaultSlowDelay}
+}
+
+// Enqueue 追加脚本回复，按顺序优先于样例使用
+func (s *Server) Enqueue(responses ...Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.script = append(s.script, responses...)
+}
+
+// AddFixture 添加样例回复，先添加的优先匹配
+func (s *Server) AddFixture(fixture Fixture) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fixtures = append(s.fixtures, fixture)
+}
+
+// LoadFixtures 按文件名顺序加载目录下的 *.json 样例
+func (s *Server) LoadFixtures(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+		if fixture.Name == "" {
+			fixture.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		s.AddFixture(fixture)
+	}
+	return nil
+}
+
+// SetFault 设置默认故障，对没有指定故障的回复生效；delay 为慢响应的默认延迟
+func (s *Server) SetFault(fault Fault, delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fault = fault
+	if delay > 0 {
+		s.delay = delay
+	}
+}
+
+// Requests 返回已收到的请求
+func (s *Server) Requests() []ChatRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ChatRequest(nil), s.requests...)
+}
+
+// ServeHTTP 处理 <base_url>/chat/completions 请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "not_found", "unknown endpoint "+r.URL.Path)
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	req.Authorization = r.Header.Get("Authorization")
+	response, delay := s.next(req)
+
+	switch response.Fault {
+	case FaultSlow:
+		if response.Delay > 0 {
+			delay = response.Delay
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	case FaultRateLimit:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, "rate_limit_exceeded", "Rate limit reached, please retry later")
+		return
+	case FaultServerError:
+		writeError(w, http.StatusInternalServerError, "server_error", "The server had an error while processing your request")
+		return
+	case FaultMalformedJSON:
+		response.Content = truncate(response.Content)
+	case FaultCodeFence:
+		response.Content = "```json\n" + response.Content + "\n```"
+	}
+
+	writeCompletion(w, req, response)
+}
+
+// next 记录请求并选择回复：脚本回复 > 匹配的样例 > 默认内容
+func (s *Server) next(req ChatRequest) (Response, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req)
+
+	var response Response
+	if len(s.script) > 0 {
+		response = s.script[0]
+		s.script = s.script[1:]
+	} else {
+		response.Content = DefaultContent
+		prompt := lastUserMessage(req.Messages)
+		for _, fixture := range s.fixtures {
+			if fixture.matches(prompt) {
+				response.Content = fixture.text()
+				break
+			}
+		}
+	}
+	if response.Fault == FaultNone {
+		response.Fault = s.fault
+	}
+	return response, s.delay
+}
+
+// lastUserMessage 返回最后一条用户消息的内容
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// truncate 截掉后半部分内容，得到不完整的JSON
+func truncate(content string) string {
+	runes := []rune(content)
+	if len(runes) < 2 {
+		return `{"days": [`
+	}
+	return string(runes[:len(runes)/2])
+}
+
+// writeCompletion 按 OpenAI 格式返回回复，token 用量按字符数粗略估算
+func writeCompletion(w http.ResponseWriter, req ChatRequest, response Response) {
+	message := map[string]interface{}{"role": "assistant", "content": response.Content}
+	finishReason := "stop"
+	if len(response.ToolCalls) > 0 {
+		var calls []map[string]interface{}
+		for i, call := range response.ToolCalls {
+			calls = append(calls, map[string]interface{}{
+				"id":       fmt.Sprintf("call-%d-%s", i+1, uuid.New().String()[:8]),
+				"type":     "function",
+				"function": map[string]string{"name": call.Name, "arguments": call.Arguments},
+			})
+		}
+		message["tool_calls"] = calls
+		finishReason = "tool_calls"
+	}
+
+	var promptChars int
+	for _, m := range req.Messages {
+		promptChars += len([]rune(m.Content))
+	}
+	promptTokens := promptChars/4 + 1
+	completionTokens := len([]rune(response.Content))/4 + 1
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-" + uuid.New().String(),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       message,
+			"finish_reason": finishReason,
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	})
+}
+
+// writeError 按 OpenAI 格式返回错误
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": code, "code": code},
+	})
+}
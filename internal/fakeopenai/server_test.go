@@ -0,0 +1,93 @@
+package fakeopenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postChat(t *testing.T, url, prompt string) (*http.Response, string) {
+	t.Helper()
+	body := `{"model":"gpt-test","messages":[{"role":"system","content":"sys"},{"role":"user","content":` + mustJSON(prompt) + `}]}`
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	json.NewDecoder(resp.Body).Decode(&completion)
+	if len(completion.Choices) == 0 {
+		return resp, ""
+	}
+	return resp, completion.Choices[0].Message.Content
+}
+
+func mustJSON(v string) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestServer_FixturesAndScript(t *testing.T) {
+	fake := NewServer()
+	if err := fake.LoadFixtures("../../fixtures/fakeopenai"); err != nil {
+		t.Fatalf("LoadFixtures failed: %v", err)
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if _, content := postChat(t, server.URL, `请返回 {"budget": {"breakdown": {}}}`); !strings.Contains(content, `"days"`) {
+		t.Errorf("Expected travel plan fixture, got %q", content)
+	}
+	if _, content := postChat(t, server.URL, "unrelated"); content != DefaultContent {
+		t.Errorf("Expected default content, got %q", content)
+	}
+
+	fake.Enqueue(Response{Content: "scripted"})
+	if _, content := postChat(t, server.URL, `"breakdown"`); content != "scripted" {
+		t.Errorf("Scripted response should take precedence, got %q", content)
+	}
+	if len(fake.Requests()) != 3 {
+		t.Errorf("Expected 3 recorded requests, got %d", len(fake.Requests()))
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	fake := NewServer()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	fake.Enqueue(
+		Response{Content: `{"a":1}`, Fault: FaultCodeFence},
+		Response{Content: `{"a":1}`, Fault: FaultMalformedJSON},
+		Response{Fault: FaultRateLimit},
+		Response{Content: "late", Fault: FaultSlow, Delay: 50 * time.Millisecond},
+	)
+
+	if _, content := postChat(t, server.URL, "x"); content != "```json\n{\"a\":1}\n```" {
+		t.Errorf("Unexpected code fence content: %q", content)
+	}
+	if _, content := postChat(t, server.URL, "x"); json.Valid([]byte(content)) {
+		t.Errorf("Expected malformed JSON, got %q", content)
+	}
+	if resp, _ := postChat(t, server.URL, "x"); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d", resp.StatusCode)
+	}
+	start := time.Now()
+	if _, content := postChat(t, server.URL, "x"); content != "late" || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected delayed response, got %q after %v", content, time.Since(start))
+	}
+
+	if _, err := ParseFault("explode"); err == nil {
+		t.Errorf("Expected error for unknown fault")
+	}
+}
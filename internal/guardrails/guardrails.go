@@ -0,0 +1,130 @@
+// Package guardrails 对拼接进提示词的用户输入和模型输出做安全检查
+package guardrails
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 用户输入的分隔标签，系统提示词会说明标签内的内容只是数据
+const (
+	openTag  = "<user_input>"
+	closeTag = "</user_input>"
+)
+
+// Rule 一条检测规则
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Finding 命中的规则及命中片段
+type Finding struct {
+	Rule    string `json:"rule"`
+	Excerpt string `json:"excerpt"`
+}
+
+// injectionRules 针对模型的指令注入特征（中英文）
+var injectionRules = []Rule{
+	{"ignore_instructions", regexp.MustCompile(`(?i)(ignore|disregard|forget)\s+(all\s+|any\s+)?(the\s+)?(previous|prior|above|earlier|system)\s+(instructions|prompts?|rules|messages)`)},
+	{"ignore_instructions", regexp.MustCompile(`(忽略|无视|忘记|不要理会)(掉)?(之前|以上|上面|前面|先前|系统)?的?(所有|全部|任何)?(指令|提示词?|要求|规则|设定)`)},
+	{"role_override", regexp.MustCompile(`(?i)\byou\s+are\s+now\b|\bact\s+as\s+(an?\s+)?(different|new|unrestricted)|\bdeveloper\s+mode\b|\bjailbreak\b`)},
+	{"role_override", regexp.MustCompile(`(从现在(开始|起)|现在)你(是|扮演|不再是)|开发者模式|越狱`)},
+	{"prompt_exfiltration", regexp.MustCompile(`(?i)(reveal|print|show|repeat|output)\s+(me\s+)?(your|the)\s+(system\s+)?(prompt|instructions)`)},
+	{"prompt_exfiltration", regexp.MustCompile(`(输出|显示|告诉我|重复|泄露)(你的|一下)?(系统)?(提示词|指令|设定)`)},
+	{"delimiter_escape", regexp.MustCompile(`(?i)</?\s*user_input\s*>|<\|im_(start|end)\|>|\[/?INST\]|(?m)^\s*(system|assistant)\s*:`)},
+}
+
+// outputRules 模型输出中不应持久化的内容
+var outputRules = []Rule{
+	{"script_injection", regexp.MustCompile(`(?i)<\s*script\b|javascript\s*:|\bon(error|load|click)\s*=`)},
+	{"prompt_leak", regexp.MustCompile(`(?i)</?\s*user_input\s*>|你必须只返回有效的JSON格式响应|You must respond with valid JSON only`)},
+}
+
+// Sanitize 清理用户输入：去掉控制字符和零宽字符，并截断到 maxLength 个字符（<=0 表示不限制）
+func Sanitize(text string, maxLength int) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r == '\r':
+			return '\n'
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	if maxLength > 0 && utf8.RuneCountInString(text) > maxLength {
+		text = string([]rune(text)[:maxLength])
+	}
+	return text
+}
+
+// Delimit 用分隔标签包裹用户输入，并转义其中伪造的标签，防止提前闭合
+func Delimit(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = tagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		return strings.NewReplacer("<", "＜", ">", "＞").Replace(tag)
+	})
+	return openTag + text + closeTag
+}
+
+var tagPattern = regexp.MustCompile(`(?i)</?\s*user_input\s*>`)
+
+// DetectInjection 检测用户输入中针对模型的指令
+func DetectInjection(text string) []Finding {
+	return match(injectionRules, text)
+}
+
+// ScreenOutput 检查模型输出，blockedTerms 为额外禁止出现的词（不区分大小写）
+func ScreenOutput(text string, blockedTerms []string) []Finding {
+	findings := match(outputRules, text)
+	lower := strings.ToLower(text)
+	for _, term := range blockedTerms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 {
+			findings = append(findings, Finding{Rule: "blocked_term", Excerpt: excerpt(text, i, len(term))})
+		}
+	}
+	return findings
+}
+
+func match(rules []Rule, text string) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		if loc := rule.Pattern.FindStringIndex(text); loc != nil {
+			findings = append(findings, Finding{Rule: rule.Name, Excerpt: excerpt(text, loc[0], loc[1]-loc[0])})
+		}
+	}
+	return findings
+}
+
+// excerpt 截取命中位置前后的片段，便于人工复核
+func excerpt(text string, start, length int) string {
+	const context = 40
+	from := start - context
+	if from < 0 {
+		from = 0
+	}
+	to := start + length + context
+	if to > len(text) {
+		to = len(text)
+	}
+	// 调整到合法的 UTF-8 边界
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return text[from:to]
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GuardrailHandler struct {
+	guardrailService *services.GuardrailService
+}
+
+func NewGuardrailHandler(guardrailService *services.GuardrailService) *GuardrailHandler {
+	return &GuardrailHandler{
+		guardrailService: guardrailService,
+	}
+}
+
+// GetViolations 获取安全检查违规记录（管理员），可按 user_id、stage 过滤
+func (h *GuardrailHandler) GetViolations(c *gin.Context) {
+	violations, err := h.guardrailService.GetViolations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get guardrail violations"})
+		return
+	}
+
+	userID := c.Query("user_id")
+	stage := c.Query("stage")
+	filtered := []*models.GuardrailViolation{}
+	for _, violation := range violations {
+		if (userID == "" || violation.UserID == userID) && (stage == "" || violation.Stage == stage) {
+			filtered = append(filtered, violation)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"violations": filtered})
+}
+
+// respondLLMError 处理LLM调用的可识别错误（配额超限、安全检查拦截），已写入响应时返回true
+func respondLLMError(c *gin.Context, err error) bool {
+	if respondQuotaExceeded(c, err) {
+		return true
+	}
+
+	var guardrailErr *services.GuardrailError
+	if !errors.As(err, &guardrailErr) {
+		return false
+	}
+	if guardrailErr.Stage == models.GuardrailStageInput {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Input rejected by content guardrails",
+			"details": guardrailErr.Error(),
+			"field":   guardrailErr.Field,
+		})
+		return true
+	}
+	c.JSON(http.StatusBadGateway, gin.H{
+		"error":   "LLM response rejected by content guardrails",
+		"details": guardrailErr.Error(),
+	})
+	return true
+}
+
+// isInputRejection 判断错误是否为用户输入被安全检查拦截，这类请求不能改用离线规划绕过
+func isInputRejection(err error) bool {
+	var guardrailErr *services.GuardrailError
+	return errors.As(err, &guardrailErr) && guardrailErr.Stage == models.GuardrailStageInput
+}
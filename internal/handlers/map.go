@@ -1,25 +1,40 @@
 package handlers
 
 import (
+	"ai-travel-planner/internal/coord"
 	"ai-travel-planner/internal/services"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type MapHandler struct {
-	mapService *services.AmapService
+	maps        *services.MapRouter
+	userService *services.UserService
 }
 
-func NewMapHandler(mapService *services.AmapService) *MapHandler {
+func NewMapHandler(maps *services.MapRouter, userService *services.UserService) *MapHandler {
 	return &MapHandler{
-		mapService: mapService,
+		maps:        maps,
+		userService: userService,
 	}
 }
 
+// mapsFor 使用调用者在设置中保存的高德API Key，未保存时使用系统Key
+func (h *MapHandler) mapsFor(c *gin.Context) *services.MapRouter {
+	if h.userService == nil {
+		return h.maps
+	}
+	return h.maps.WithAmapKey(h.userService.AmapAPIKey(c.GetString("user_id")))
+}
+
 // GeocodeRequest 地理编码请求
 type GeocodeRequest struct {
 	Address string `json:"address" binding:"required"`
+	Country string `json:"country"` // 可选的国家代码，用于选择地图服务，默认按地址判断
+	DatumRequest
 }
 
 // Geocode 地理编码：地址转坐标
@@ -30,16 +45,26 @@ func (h *MapHandler) Geocode(c *gin.Context) {
 		return
 	}
 
-	result, err := h.mapService.Geocode(req.Address)
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(req.Address, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	result, err := provider.Geocode(req.Address)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
 		return
 	}
+	result.ConvertDatum(provider.Datum(), datum)
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":   result.Status,
-		"count":    result.Count,
-		"geocodes": result.Geocodes,
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"status":            result.Status,
+		"count":             result.Count,
+		"geocodes":          result.Geocodes,
+		"coordinate_system": datum,
 	})
 }
 
@@ -47,6 +72,8 @@ func (h *MapHandler) Geocode(c *gin.Context) {
 type RegeocodeRequest struct {
 	Longitude string `json:"longitude" binding:"required"`
 	Latitude  string `json:"latitude" binding:"required"`
+	Country   string `json:"country"` // 可选的国家代码，默认按坐标判断
+	DatumRequest
 }
 
 // Regeocode 逆地理编码：坐标转地址
@@ -57,15 +84,30 @@ func (h *MapHandler) Regeocode(c *gin.Context) {
 		return
 	}
 
-	result, err := h.mapService.Regeocode(req.Longitude, req.Latitude)
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(req.Longitude+","+req.Latitude, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	longitude, latitude := req.Longitude, req.Latitude
+	if lng, lat, ok := services.ParseLngLat(longitude + "," + latitude); ok {
+		lng, lat = coord.Convert(lng, lat, datum, provider.Datum())
+		longitude, latitude = strconv.FormatFloat(lng, 'f', 6, 64), strconv.FormatFloat(lat, 'f', 6, 64)
+	}
+	result, err := provider.Regeocode(longitude, latitude)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
 		return
 	}
+	result.ConvertDatum(provider.Datum(), datum)
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":    result.Status,
-		"regeocode": result.Regeocode,
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"status":            result.Status,
+		"regeocode":         result.Regeocode,
+		"coordinate_system": datum,
 	})
 }
 
@@ -73,7 +115,9 @@ func (h *MapHandler) Regeocode(c *gin.Context) {
 type SearchPOIRequest struct {
 	Keyword string `json:"keyword" binding:"required"`
 	City    string `json:"city"`
-	Types   string `json:"types"` // POI类型，如：餐饮服务|购物服务
+	Types   string `json:"types"`   // POI类型，如：餐饮服务|购物服务（仅高德支持）
+	Country string `json:"country"` // 可选的国家代码，默认按城市或关键词判断
+	DatumRequest
 }
 
 // SearchPOI POI搜索
@@ -84,16 +128,30 @@ func (h *MapHandler) SearchPOI(c *gin.Context) {
 		return
 	}
 
-	result, err := h.mapService.SearchPOI(req.Keyword, req.City, req.Types)
+	query := req.City
+	if query == "" {
+		query = req.Keyword
+	}
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(query, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	result, err := provider.SearchPOI(req.Keyword, req.City, req.Types)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
 		return
 	}
+	result.ConvertDatum(provider.Datum(), datum)
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": result.Status,
-		"count":  result.Count,
-		"pois":   result.Pois,
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"status":            result.Status,
+		"count":             result.Count,
+		"pois":              result.Pois,
+		"coordinate_system": datum,
 	})
 }
 
@@ -101,8 +159,10 @@ func (h *MapHandler) SearchPOI(c *gin.Context) {
 type RouteRequest struct {
 	Origin      string `json:"origin" binding:"required"`      // 起点坐标 "经度,纬度" 或地址
 	Destination string `json:"destination" binding:"required"` // 终点坐标 "经度,纬度" 或地址
-	Mode        string `json:"mode"`                           // driving:驾车 walking:步行 transit:公交
+	Mode        string `json:"mode"`                           // driving:驾车 walking:步行 cycling:骑行 transit:公交
 	City        string `json:"city"`                           // 城市（公交路线规划时需要）
+	Country     string `json:"country"`                        // 可选的国家代码，默认按起点判断
+	DatumRequest
 }
 
 // Route 路线规划
@@ -113,30 +173,33 @@ func (h *MapHandler) Route(c *gin.Context) {
 		return
 	}
 
-	var result *services.RouteResponse
-	var err error
-
-	switch req.Mode {
-	case "driving":
-		result, err = h.mapService.DrivingRoute(req.Origin, req.Destination)
-	case "walking":
-		result, err = h.mapService.WalkingRoute(req.Origin, req.Destination)
-	case "transit":
-		result, err = h.mapService.TransitRoute(req.Origin, req.Destination, req.City)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的路线规划模式，支持: driving, walking, transit"})
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(req.Origin, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	origin := services.ConvertLocation(req.Origin, datum, provider.Datum())
+	destination := services.ConvertLocation(req.Destination, datum, provider.Datum())
+	result, err := services.PlanRoute(provider, req.Mode, origin, destination, req.City)
+	if errors.Is(err, services.ErrUnsupportedRouteMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的路线规划模式，支持: driving, walking, cycling, transit"})
 		return
 	}
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
 		return
 	}
+	result.ConvertDatum(provider.Datum(), datum)
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": result.Status,
-		"route":  result.Route,
-		"count":  result.Count,
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"status":            result.Status,
+		"route":             result.Route,
+		"routes":            services.NormalizeRoutes(provider.Name(), req.Mode, result),
+		"count":             result.Count,
+		"coordinate_system": datum,
 	})
 }
 
@@ -145,6 +208,8 @@ type DistanceRequest struct {
 	Origins      string `json:"origins" binding:"required"`      // 起点坐标，多个用|分隔 "经度,纬度|经度,纬度"
 	Destinations string `json:"destinations" binding:"required"` // 终点坐标，多个用|分隔
 	Mode         string `json:"mode"`                            // 0:直线距离 1:驾车距离 3:步行距离
+	Country      string `json:"country"`                         // 可选的国家代码，默认按第一个起点判断
+	DatumRequest
 }
 
 // Distance 计算距离
@@ -155,30 +220,43 @@ func (h *MapHandler) Distance(c *gin.Context) {
 		return
 	}
 
-	result, err := h.mapService.CalculateDistance(req.Origins, req.Destinations, req.Mode)
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(req.Origins, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	origins := services.ConvertLocation(req.Origins, datum, provider.Datum())
+	destinations := services.ConvertLocation(req.Destinations, datum, provider.Datum())
+	result, err := provider.CalculateDistance(origins, destinations, req.Mode)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  result.Status,
-		"results": result.Results,
+		"provider":   provider.Name(),
+		"key_source": maps.KeySource(provider),
+		"status":     result.Status,
+		"results":    result.Results,
 	})
 }
 
-// GetAmapApiKey 获取高德地图API Key（用于前端加载SDK）
+// GetAmapApiKey 获取高德地图API Key（用于前端加载SDK），登录用户优先使用设置中保存的Key
 func (h *MapHandler) GetAmapApiKey(c *gin.Context) {
-	apiKey := h.mapService.GetApiKey()
+	maps := h.mapsFor(c)
+	apiKey := maps.Amap().GetApiKey()
 	if apiKey == "" {
 		c.JSON(http.StatusOK, gin.H{
-			"apiKey": "",
-			"message": "高德地图API Key未配置",
+			"apiKey":     "",
+			"message":    "高德地图API Key未配置",
+			"key_source": services.MapKeySourceNone,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"apiKey": apiKey,
+		"apiKey":     apiKey,
+		"key_source": maps.KeySource(maps.Amap()),
 	})
 }
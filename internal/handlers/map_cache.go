@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MapCacheHandler struct {
+	mapCache *services.MapCache // 为 nil 时表示未启用缓存
+}
+
+func NewMapCacheHandler(mapCache *services.MapCache) *MapCacheHandler {
+	return &MapCacheHandler{
+		mapCache: mapCache,
+	}
+}
+
+// GetStats 获取地图查询缓存的命中统计（管理员）
+func (h *MapCacheHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.mapCache != nil,
+		"stats":   h.mapCache.Stats(),
+	})
+}
+
+// Purge 清除地图查询缓存（管理员）。带 query 时只删除一条查询（需同时指定 endpoint，POI搜索可带 city、types），
+// 否则清空 endpoint 对应的缓存，endpoint 为空时清空全部
+func (h *MapCacheHandler) Purge(c *gin.Context) {
+	if h.mapCache == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Map cache is not enabled"})
+		return
+	}
+
+	endpoint := c.Query("endpoint")
+	query := c.Query("query")
+	var purged int
+	var err error
+	if query != "" {
+		if endpoint == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint is required when purging a query"})
+			return
+		}
+		purged, err = h.mapCache.PurgeQuery(endpoint, query, c.Query("city"), c.Query("types"))
+	} else {
+		purged, err = h.mapCache.Purge(endpoint)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cache endpoint", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/coord"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestDatum 解析请求指定的坐标系（wgs84、gcj02、bd09），未指定时使用 fallback，无效时已写入响应
+func requestDatum(c *gin.Context, name, fallback string) (string, bool) {
+	datum, err := coord.Normalize(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if datum == "" {
+		datum = fallback
+	}
+	return datum, true
+}
+
+// DatumRequest 请求可选的坐标系，请求和响应中的坐标都使用该坐标系，默认为所用地图服务的坐标系（高德为 gcj02，OpenStreetMap 为 wgs84）
+type DatumRequest struct {
+	CoordinateSystem string `json:"coordinate_system"`
+}
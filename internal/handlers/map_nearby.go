@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NearbyRequest 周边搜索请求
+type NearbyRequest struct {
+	services.NearbySearch
+	Country string `json:"country"` // 可选的国家代码，默认按坐标判断
+	DatumRequest
+}
+
+// Nearby 周边搜索：按坐标和半径搜索关键词或类别（restaurant、coffee、toilet 等），结果按距离排序并分页
+func (h *MapHandler) Nearby(c *gin.Context) {
+	var req NearbyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	search, err := req.NearbySearch.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(search.Location, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	search.Location = services.ConvertLocation(search.Location, datum, provider.Datum())
+	result, err := provider.SearchNearby(search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
+		return
+	}
+	result.ConvertDatum(provider.Datum(), datum)
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"count":             result.Count,
+		"page":              search.Page,
+		"page_size":         search.PageSize,
+		"pois":              result.Pois,
+		"coordinate_system": datum,
+	})
+}
+
+// AlongRouteRequest 沿途搜索请求
+type AlongRouteRequest struct {
+	services.AlongRouteSearch
+	Origin      string `json:"origin" binding:"required"`      // 起点坐标 "经度,纬度"
+	Destination string `json:"destination" binding:"required"` // 终点坐标 "经度,纬度"
+	Mode        string `json:"mode"`                           // 路线方式，默认 walking
+	City        string `json:"city"`                           // 城市（公交路线需要）
+	Country     string `json:"country"`
+	DatumRequest
+}
+
+// AlongRoute 沿途搜索：规划起终点之间的路线，查找路线附近的地点（如两个景点之间的午餐）
+func (h *MapHandler) AlongRoute(c *gin.Context) {
+	var req AlongRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "起点和终点不能为空"})
+		return
+	}
+
+	maps := h.mapsFor(c)
+	provider := maps.ForQuery(req.Origin, req.Country)
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+	origin := services.ConvertLocation(req.Origin, datum, provider.Datum())
+	destination := services.ConvertLocation(req.Destination, datum, provider.Datum())
+	result, status, err := searchAlongRoute(provider, req.Mode, origin, destination, req.City, req.AlongRouteSearch)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error(), "provider": provider.Name(), "key_source": maps.KeySource(provider)})
+		return
+	}
+	result.ConvertDatum(provider.Datum(), datum)
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider":          provider.Name(),
+		"key_source":        maps.KeySource(provider),
+		"result":            result,
+		"coordinate_system": datum,
+	})
+}
+
+// searchAlongRoute 规划路线并沿途搜索，失败时返回对应的 HTTP 状态码
+func searchAlongRoute(provider services.MapProvider, mode, origin, destination, city string, search services.AlongRouteSearch) (*services.AlongRouteResult, int, error) {
+	if mode == "" {
+		mode = services.RouteModeWalking
+	}
+	probe, err := services.NearbySearch{Location: origin, Keyword: search.Keyword, Category: search.Category, Types: search.Types}.Normalize()
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	search.Category = probe.Category
+
+	response, err := services.PlanRoute(provider, mode, origin, destination, city)
+	if errors.Is(err, services.ErrUnsupportedRouteMode) {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	var route services.Route
+	if routes := services.NormalizeRoutes(provider.Name(), mode, response); len(routes) > 0 {
+		route = routes[0]
+	}
+
+	result, err := services.SearchAlongRoute(provider, route, origin, destination, search)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return result, http.StatusOK, nil
+}
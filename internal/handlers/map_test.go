@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/services"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newMapTestRouter 搭建地图和设置接口，高德请求发往记录 key 参数的本地替身服务
+func newMapTestRouter(t *testing.T, systemKey string) (*gin.Engine, func() []string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var mutex sync.Mutex
+	var keys []string
+	amap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		keys = append(keys, r.URL.Query().Get("key"))
+		mutex.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "1", "count": "1", "info": "OK",
+			"geocodes": []map[string]string{{"formatted_address": "浙江省杭州市西湖区", "location": "120.130663,30.240018"}},
+		})
+	}))
+	t.Cleanup(amap.Close)
+
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = systemKey
+	cfg.APIs.Amap.BaseURL = amap.URL
+	cfg.APIs.OSM.Disabled = true
+	userService := services.NewUserService(cfg)
+	maps := services.NewMapRouter(services.NewAmapService(cfg), services.NewOSMMapService(cfg))
+	mapHandler := NewMapHandler(maps, userService)
+	settingsHandler := NewSettingsHandler(userService, nil)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", c.GetHeader("X-User")) })
+	router.GET("/settings", settingsHandler.GetSettings)
+	router.PUT("/settings", settingsHandler.UpdateSettings)
+	router.POST("/map/geocode", mapHandler.Geocode)
+	router.GET("/map/api-key", mapHandler.GetAmapApiKey)
+	return router, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func serveJSON(router *gin.Engine, method, path, user string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestMapHandler_UserAmapKey(t *testing.T) {
+	router, keys := newMapTestRouter(t, "system-key")
+
+	// 新用户没有资料时读取设置不应出错
+	if w, _ := serveJSON(router, http.MethodGet, "/settings", "user-1", nil); w.Code != http.StatusOK {
+		t.Fatalf("GetSettings without a profile returned %d", w.Code)
+	}
+	if w, _ := serveJSON(router, http.MethodPut, "/settings", "user-1", map[string]string{"amap_api_key": "user-key"}); w.Code != http.StatusOK {
+		t.Fatalf("UpdateSettings returned %d: %s", w.Code, w.Body.String())
+	}
+	if _, response := serveJSON(router, http.MethodGet, "/settings", "user-1", nil); response["settings"].(map[string]interface{})["amap_api_key"] != "user-key" {
+		t.Errorf("Saved Amap key not returned: %v", response)
+	}
+
+	w, response := serveJSON(router, http.MethodPost, "/map/geocode", "user-1", map[string]string{"address": "西湖"})
+	if w.Code != http.StatusOK || response["key_source"] != services.MapKeySourceUser || response["provider"] != services.MapProviderAmap {
+		t.Errorf("Expected the user key to be used, got %d: %v", w.Code, response)
+	}
+	_, response = serveJSON(router, http.MethodPost, "/map/geocode", "user-2", map[string]string{"address": "西湖"})
+	if response["key_source"] != services.MapKeySourceSystem {
+		t.Errorf("Expected the system key for a user without settings, got %v", response)
+	}
+
+	// 前端加载SDK时同样使用用户的Key，匿名请求使用系统Key
+	if _, response := serveJSON(router, http.MethodGet, "/map/api-key", "user-1", nil); response["apiKey"] != "user-key" || response["key_source"] != services.MapKeySourceUser {
+		t.Errorf("Expected the user key for the SDK, got %v", response)
+	}
+	if _, response := serveJSON(router, http.MethodGet, "/map/api-key", "", nil); response["apiKey"] != "system-key" || response["key_source"] != services.MapKeySourceSystem {
+		t.Errorf("Expected the system key for anonymous requests, got %v", response)
+	}
+
+	if got := keys(); len(got) != 2 || got[0] != "user-key" || got[1] != "system-key" {
+		t.Errorf("Unexpected keys sent to Amap: %v", got)
+	}
+}
+
+func TestMapHandler_NoAmapKey(t *testing.T) {
+	router, keys := newMapTestRouter(t, "")
+
+	w, response := serveJSON(router, http.MethodPost, "/map/geocode", "user-1", map[string]string{"address": "西湖"})
+	if w.Code != http.StatusInternalServerError || response["key_source"] != services.MapKeySourceNone {
+		t.Errorf("Expected an error without any key, got %d: %v", w.Code, response)
+	}
+
+	if _, response := serveJSON(router, http.MethodGet, "/map/api-key", "user-1", nil); response["apiKey"] != "" || response["key_source"] != services.MapKeySourceNone {
+		t.Errorf("Expected no key, got %v", response)
+	}
+
+	serveJSON(router, http.MethodPut, "/settings", "user-1", map[string]string{"amap_api_key": "user-key"})
+	if _, response := serveJSON(router, http.MethodPost, "/map/geocode", "user-1", map[string]string{"address": "西湖"}); response["key_source"] != services.MapKeySourceUser {
+		t.Errorf("A user key should work without a system key, got %v", response)
+	}
+	if got := keys(); len(got) != 1 || got[0] != "user-key" {
+		t.Errorf("Unexpected keys sent to Amap: %v", got)
+	}
+}
+
+func TestMapCacheHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.APIs.Amap.Cache = config.MapCacheConfig{Enabled: true, MaxEntries: 10}
+	mapCache, _ := services.NewMapCache(cfg)
+
+	router := gin.New()
+	handler := NewMapCacheHandler(mapCache)
+	router.GET("/admin/map-cache", handler.GetStats)
+	router.DELETE("/admin/map-cache", handler.Purge)
+
+	w, response := serveJSON(router, http.MethodGet, "/admin/map-cache", "admin", nil)
+	if stats, _ := response["stats"].([]interface{}); w.Code != http.StatusOK || response["enabled"] != true || len(stats) != 2 {
+		t.Errorf("Unexpected stats response %d: %v", w.Code, response)
+	}
+	if w, _ := serveJSON(router, http.MethodDelete, "/admin/map-cache?endpoint=route", "admin", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown endpoint, got %d", w.Code)
+	}
+	if w, _ := serveJSON(router, http.MethodDelete, "/admin/map-cache?query=西湖", "admin", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a query without endpoint, got %d", w.Code)
+	}
+	if w, response := serveJSON(router, http.MethodDelete, "/admin/map-cache?endpoint=geocode", "admin", nil); w.Code != http.StatusOK || response["purged"] != float64(0) {
+		t.Errorf("Unexpected purge response %d: %v", w.Code, response)
+	}
+
+	disabled := gin.New()
+	disabled.DELETE("/admin/map-cache", NewMapCacheHandler(nil).Purge)
+	if w, _ := serveJSON(disabled, http.MethodDelete, "/admin/map-cache", "admin", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when the cache is disabled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyzePlanBudget 结合行程费用、原始预算分配和已过天数进行AI预算分析
+func (h *TravelHandler) AnalyzePlanBudget(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	var req struct {
+		OpenAIApiKey  string `json:"openai_api_key"`
+		OpenAIBaseURL string `json:"openai_base_url"`
+		OpenAIModel   string `json:"openai_model"`
+	}
+	// 请求体可选，凭据也可以通过请求头传入
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := h.travelService.GetTravelPlan(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	expenses, err := h.travelService.GetExpenses(planID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get expenses"})
+		return
+	}
+
+	apiKey, baseURL, model := llmCredentials(c, req.OpenAIApiKey, req.OpenAIBaseURL, req.OpenAIModel)
+	meta := services.LLMCallMeta{UserID: userID, PlanID: planID, Locale: plan.Locale, BypassCache: bypassLLMCache(c)}
+	analysis, err := h.llmService.AnalyzeBudgetWithKey(meta, plan, expenses, time.Now(), apiKey, baseURL, model)
+	if err != nil {
+		if respondLLMError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze budget", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"analysis": analysis})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatWithPlan 用自然语言修改行程，返回待确认的修改建议和对比
+func (h *TravelHandler) ChatWithPlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	var req struct {
+		Message       string `json:"message" binding:"required"`
+		OpenAIApiKey  string `json:"openai_api_key"`
+		OpenAIBaseURL string `json:"openai_base_url"`
+		OpenAIModel   string `json:"openai_model"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tree, err := h.travelService.GetPlanTree(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if tree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	history, err := h.travelService.GetPlanChatMessages(planID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat history"})
+		return
+	}
+
+	apiKey, baseURL, model := llmCredentials(c, req.OpenAIApiKey, req.OpenAIBaseURL, req.OpenAIModel)
+	meta := services.LLMCallMeta{UserID: userID, PlanID: planID, Locale: tree.Plan.Locale, BypassCache: bypassLLMCache(c)}
+	patch, err := h.llmService.RefinePlanWithKey(meta, tree, history, req.Message, apiKey, baseURL, model)
+	if err != nil {
+		if respondLLMError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refine travel plan", "details": err.Error()})
+		return
+	}
+
+	diff, err := h.travelService.DiffPlanPatch(tree, patch)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "LLM returned an invalid patch", "details": err.Error()})
+		return
+	}
+
+	userMessage := services.NewPlanChatMessage(planID, userID, "user", req.Message)
+	if err := h.travelService.AddPlanChatMessage(userMessage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chat message"})
+		return
+	}
+	assistantMessage := services.NewPlanChatMessage(planID, userID, "assistant", patch.Summary)
+	assistantMessage.Patch = patch
+	assistantMessage.PatchStatus = models.PatchStatusPending
+	if err := h.travelService.AddPlanChatMessage(assistantMessage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chat message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": assistantMessage,
+		"diff":    diff,
+	})
+}
+
+// GetPlanChat 获取行程的对话历史
+func (h *TravelHandler) GetPlanChat(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	plan, err := h.travelService.GetTravelPlan(planID, userID)
+	if err != nil || plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	messages, err := h.travelService.GetPlanChatMessages(planID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// ApplyPlanChatPatch 确认并应用对话中的修改建议
+func (h *TravelHandler) ApplyPlanChatPatch(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+	messageID := c.Param("message_id")
+
+	tree, err := h.travelService.GetPlanTree(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if tree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	messages, err := h.travelService.GetPlanChatMessages(planID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat history"})
+		return
+	}
+	var message *models.PlanChatMessage
+	for _, m := range messages {
+		if m.ID == messageID {
+			message = m
+			break
+		}
+	}
+	if message == nil || message.Patch == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patch not found"})
+		return
+	}
+	// 先把建议标记为已应用，并发的确认请求只有一个能标记成功，避免重复应用
+	applied, ok := h.travelService.SetPlanChatPatchStatus(message.ID, models.PatchStatusPending, models.PatchStatusApplied)
+	if !ok {
+		status := ""
+		if applied != nil {
+			status = applied.PatchStatus
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Patch is no longer pending", "patch_status": status})
+		return
+	}
+
+	diff, err := h.travelService.ApplyPlanPatch(tree, message.Patch)
+	if err != nil {
+		h.travelService.SetPlanChatPatchStatus(message.ID, models.PatchStatusApplied, models.PatchStatusPending)
+		c.JSON(http.StatusConflict, gin.H{"error": "Patch no longer matches the plan", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": applied,
+		"diff":    diff,
+	})
+}
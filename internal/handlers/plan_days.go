@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadPlanDay 加载行程树及指定的某一天，失败时已写入响应
+func (h *TravelHandler) loadPlanDay(c *gin.Context) (*services.PlanTree, *services.PlanTreeDay, bool) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	dayNumber, err := strconv.Atoi(c.Param("n"))
+	if err != nil || dayNumber < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid day number"})
+		return nil, nil, false
+	}
+
+	tree, err := h.travelService.GetPlanTree(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return nil, nil, false
+	}
+	if tree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return nil, nil, false
+	}
+
+	day := tree.FindDay(dayNumber)
+	if day == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel day not found"})
+		return nil, nil, false
+	}
+	return tree, day, true
+}
+
+// RegenerateDay 重新生成某一天（或某个时间段）的活动
+func (h *TravelHandler) RegenerateDay(c *gin.Context) {
+	var req struct {
+		Constraints   []string           `json:"constraints"` // 如 "只安排室内活动"、"人均不超过300元"
+		TimeSlot      *services.TimeSlot `json:"time_slot"`   // 可选，只替换该时间段
+		OpenAIApiKey  string             `json:"openai_api_key"`
+		OpenAIBaseURL string             `json:"openai_base_url"`
+		OpenAIModel   string             `json:"openai_model"`
+	}
+	// 请求体可选，不带约束时按原目的地和偏好重新生成
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.TimeSlot.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tree, day, ok := h.loadPlanDay(c)
+	if !ok {
+		return
+	}
+
+	apiKey, baseURL, model := llmCredentials(c, req.OpenAIApiKey, req.OpenAIBaseURL, req.OpenAIModel)
+	meta := services.LLMCallMeta{UserID: tree.Plan.UserID, PlanID: tree.Plan.ID, Locale: tree.Plan.Locale, BypassCache: bypassLLMCache(c)}
+	activities, err := h.llmService.RegenerateDayWithKey(meta, tree, day.Day.DayNumber, req.TimeSlot, req.Constraints, apiKey, baseURL, model)
+	if err != nil {
+		if respondLLMError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate travel day", "details": err.Error()})
+		return
+	}
+
+	replaced, created, err := h.travelService.ReplaceDayActivities(tree.Plan, day, req.TimeSlot, activities)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save regenerated activities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"day":        day.Day,
+		"activities": created,
+		"replaced":   replaced,
+	})
+}
+
+// OptimizeDay 按活动间的出行时间优化某一天的游览顺序，用餐、住宿和交通活动保持原时间。
+// 默认只返回优化结果供确认；apply 为 true 时保存请求中 activities 给出的（即预览确认的）顺序和时间，不重新优化
+func (h *TravelHandler) OptimizeDay(c *gin.Context) {
+	var req struct {
+		services.DayOptimizeOptions
+		Apply      bool                         `json:"apply"`
+		Activities []services.OptimizedActivity `json:"activities"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Mode {
+	case "", services.RouteModeDriving, services.RouteModeWalking:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be driving or walking"})
+		return
+	}
+	if err := req.DayOptimizeOptions.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Apply && len(req.Activities) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "activities from the optimization preview are required to apply"})
+		return
+	}
+
+	tree, day, ok := h.loadPlanDay(c)
+	if !ok {
+		return
+	}
+
+	if req.Apply {
+		if err := services.ValidateDayOptimization(day, req.DayOptimizeOptions, req.Activities); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Optimized activities no longer match the day", "details": err.Error()})
+			return
+		}
+		updated, err := h.travelService.ApplyDayOptimization(tree.Plan, day, req.Activities)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save optimized activities"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"applied": true, "updated": updated})
+		return
+	}
+
+	provider := h.planMapProvider(tree.Plan.UserID, tree.Plan.Destination)
+	if provider == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Map provider is not configured"})
+		return
+	}
+
+	optimization, err := h.travelService.OptimizeDay(day, provider, req.DayOptimizeOptions)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to optimize route", "details": err.Error(), "provider": provider.Name()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"optimization": optimization, "provider": provider.Name(), "applied": false})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/services"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPlan 导出行程为 GeoJSON、KML 或 GPX（?format=geojson|kml|gpx），包含已定位的活动及相邻活动之间的路线。
+// routes=false 时不查询路线，只导出活动。坐标默认为 GPS 设备和大多数地图软件使用的 WGS-84，可用 coordinate_system 指定
+func (h *TravelHandler) ExportPlan(c *gin.Context) {
+	format := c.DefaultQuery("format", services.ExportFormatGeoJSON)
+	switch format {
+	case services.ExportFormatGeoJSON, services.ExportFormatKML, services.ExportFormatGPX:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be geojson, kml or gpx"})
+		return
+	}
+
+	datum, ok := requestDatum(c, c.Query("coordinate_system"), coord.WGS84)
+	if !ok {
+		return
+	}
+
+	tree, ok := h.loadPlanTree(c)
+	if !ok {
+		return
+	}
+	var provider services.MapProvider
+	if c.Query("routes") != "false" {
+		provider = h.planMapProvider(tree.Plan.UserID, tree.Plan.Destination)
+	}
+
+	export := h.travelService.BuildPlanExport(tree, provider, datum)
+	data, contentType, err := services.EncodePlanExport(export, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export travel plan", "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="plan-%s.%s"`, tree.Plan.ID, format))
+	c.Header("X-Missing-Routes", strconv.Itoa(export.MissingRoutes))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeocodePlanActivities 重新解析行程活动的坐标，force=true 时已有坐标的活动也重新解析
+func (h *TravelHandler) GeocodePlanActivities(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	plan, err := h.travelService.GetTravelPlan(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	provider := h.planMapProvider(userID, plan.Destination)
+	if provider == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Map provider is not configured"})
+		return
+	}
+
+	summary, err := h.travelService.GeocodePlanActivities(planID, userID, provider, c.Query("force") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to geocode activities", "details": err.Error(), "summary": summary})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+// planMapProvider 行程使用的地图服务（优先使用用户的高德Key），没有可用的服务时返回 nil
+func (h *TravelHandler) planMapProvider(userID, destination string) services.MapProvider {
+	if h.maps == nil {
+		return nil
+	}
+	var amapKey string
+	if h.userService != nil {
+		amapKey = h.userService.AmapAPIKey(userID)
+	}
+	provider := h.maps.WithAmapKey(amapKey).ForDestination(destination)
+	if provider == nil || !provider.Available() {
+		return nil
+	}
+	return provider
+}
+
+// geocodePlanInBackground 在后台解析新行程的活动坐标，进度记录在计划的 geocode_status 中
+func (h *TravelHandler) geocodePlanInBackground(planID, userID string, provider services.MapProvider) {
+	go func() {
+		summary, err := h.travelService.GeocodePlanActivities(planID, userID, provider, false)
+		if err != nil {
+			log.Printf("解析行程 %s 的活动坐标失败: %v", planID, err)
+			return
+		}
+		if summary != nil && summary.Unresolved > 0 {
+			log.Printf("行程 %s 有 %d 个活动未能解析坐标", planID, summary.Unresolved)
+		}
+	}()
+}
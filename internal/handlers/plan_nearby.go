@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadPlanActivity 查找行程中的活动，活动必须已有坐标，失败时已写入响应
+func (h *TravelHandler) loadPlanActivity(c *gin.Context, tree *services.PlanTree, activityID string) (*models.Activity, bool) {
+	_, activity := tree.FindActivity(activityID)
+	if activity == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
+		return nil, false
+	}
+	if activity.Longitude == 0 && activity.Latitude == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Activity has no coordinates, geocode the plan first", "activity_id": activity.ID})
+		return nil, false
+	}
+	return activity, true
+}
+
+// loadPlanTree 加载当前用户的行程树，失败时已写入响应
+func (h *TravelHandler) loadPlanTree(c *gin.Context) (*services.PlanTree, bool) {
+	tree, err := h.travelService.GetPlanTree(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return nil, false
+	}
+	if tree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return nil, false
+	}
+	return tree, true
+}
+
+// ActivityNearby 查找行程中某个活动附近的地点，如 ?category=coffee&radius=500。
+// coordinate_system 指定返回坐标的坐标系，默认为地图服务的坐标系
+func (h *TravelHandler) ActivityNearby(c *gin.Context) {
+	tree, ok := h.loadPlanTree(c)
+	if !ok {
+		return
+	}
+	activity, ok := h.loadPlanActivity(c, tree, c.Param("activity_id"))
+	if !ok {
+		return
+	}
+	provider := h.planMapProvider(tree.Plan.UserID, tree.Plan.Destination)
+	if provider == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Map provider is not configured"})
+		return
+	}
+	datum, ok := requestDatum(c, c.Query("coordinate_system"), provider.Datum())
+	if !ok {
+		return
+	}
+
+	radius, _ := strconv.Atoi(c.Query("radius"))
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	search, err := services.NearbySearch{
+		Location: services.ActivityLocation(activity, provider.Datum()),
+		Keyword:  c.Query("keyword"),
+		Category: c.Query("category"),
+		Types:    c.Query("types"),
+		Radius:   radius,
+		Page:     page,
+		PageSize: pageSize,
+	}.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := provider.SearchNearby(search)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to search nearby places", "details": err.Error(), "provider": provider.Name()})
+		return
+	}
+	result.ConvertDatum(provider.Datum(), datum)
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider":          provider.Name(),
+		"activity":          services.ActivityInDatum(activity, datum),
+		"count":             result.Count,
+		"page":              search.Page,
+		"page_size":         search.PageSize,
+		"pois":              result.Pois,
+		"coordinate_system": datum,
+	})
+}
+
+// SearchBetweenActivities 沿两个活动之间的路线查找地点，如两个景点之间的午餐
+func (h *TravelHandler) SearchBetweenActivities(c *gin.Context) {
+	var req struct {
+		services.AlongRouteSearch
+		FromActivityID string `json:"from_activity_id" binding:"required"`
+		ToActivityID   string `json:"to_activity_id" binding:"required"`
+		Mode           string `json:"mode"` // 路线方式，默认 walking
+		DatumRequest
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tree, ok := h.loadPlanTree(c)
+	if !ok {
+		return
+	}
+	from, ok := h.loadPlanActivity(c, tree, req.FromActivityID)
+	if !ok {
+		return
+	}
+	to, ok := h.loadPlanActivity(c, tree, req.ToActivityID)
+	if !ok {
+		return
+	}
+	provider := h.planMapProvider(tree.Plan.UserID, tree.Plan.Destination)
+	if provider == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Map provider is not configured"})
+		return
+	}
+	datum, ok := requestDatum(c, req.CoordinateSystem, provider.Datum())
+	if !ok {
+		return
+	}
+
+	origin, destination := services.ActivityLocation(from, provider.Datum()), services.ActivityLocation(to, provider.Datum())
+	result, status, err := searchAlongRoute(provider, req.Mode, origin, destination, tree.Plan.Destination, req.AlongRouteSearch)
+	if err != nil {
+		c.JSON(status, gin.H{"error": "Failed to search along the route", "details": err.Error(), "provider": provider.Name()})
+		return
+	}
+	result.ConvertDatum(provider.Datum(), datum)
+	c.JSON(http.StatusOK, gin.H{
+		"from":              services.ActivityInDatum(from, datum),
+		"to":                services.ActivityInDatum(to, datum),
+		"result":            result,
+		"coordinate_system": datum,
+	})
+}
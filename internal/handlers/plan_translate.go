@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TranslatePlan 将行程翻译为指定语言，生成一份新的行程副本
+func (h *TravelHandler) TranslatePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	var req struct {
+		Locale        string `json:"locale" binding:"required"`
+		OpenAIApiKey  string `json:"openai_api_key"`
+		OpenAIBaseURL string `json:"openai_base_url"`
+		OpenAIModel   string `json:"openai_model"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale, ok := services.NormalizeLocale(req.Locale)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+		return
+	}
+
+	tree, err := h.travelService.GetPlanTree(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if tree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+	if services.ResolveLocale(tree.Plan.Locale) == locale {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Travel plan is already in the requested locale"})
+		return
+	}
+
+	apiKey, baseURL, model := llmCredentials(c, req.OpenAIApiKey, req.OpenAIBaseURL, req.OpenAIModel)
+	meta := services.LLMCallMeta{UserID: userID, PlanID: planID, BypassCache: bypassLLMCache(c)}
+	translation, err := h.llmService.TranslatePlanWithKey(meta, tree, locale, apiKey, baseURL, model)
+	if err != nil {
+		if respondLLMError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to translate travel plan", "details": err.Error()})
+		return
+	}
+
+	copied, err := h.travelService.CopyTranslatedPlan(tree, locale, translation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save translated travel plan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"plan":        copied.Plan,
+		"days":        copied.Days,
+		"source_plan": tree.Plan.ID,
+	})
+}
@@ -28,7 +28,7 @@ func (h *SettingsHandler) GetSettings(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	profile, err := h.userService.GetUserProfile(userID)
-	if err != nil {
+	if err != nil || profile == nil {
 		// 如果没有资料，返回空设置
 		c.JSON(http.StatusOK, gin.H{
 			"settings": map[string]interface{}{
@@ -95,7 +95,7 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 
 	// 获取或创建用户资料
 	profile, err := h.userService.GetUserProfile(userID)
-	if err != nil {
+	if err != nil || profile == nil {
 		// 创建新资料
 		newProfile := &models.UserProfile{
 			ID:        uuid.New().String(),
@@ -4,7 +4,9 @@ import (
 	"ai-travel-planner/internal/models"
 	"ai-travel-planner/internal/services"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,14 +14,20 @@ import (
 )
 
 type TravelHandler struct {
-	travelService *services.TravelService
-	llmService    *services.LLMService
+	travelService  *services.TravelService
+	llmService     *services.LLMService
+	userService    *services.UserService
+	maps           *services.MapRouter
+	offlinePlanner *services.OfflinePlanner
 }
 
-func NewTravelHandler(travelService *services.TravelService, llmService *services.LLMService) *TravelHandler {
+func NewTravelHandler(travelService *services.TravelService, llmService *services.LLMService, userService *services.UserService, maps *services.MapRouter, offlinePlanner *services.OfflinePlanner) *TravelHandler {
 	return &TravelHandler{
-		travelService: travelService,
-		llmService:    llmService,
+		travelService:  travelService,
+		llmService:     llmService,
+		userService:    userService,
+		maps:           maps,
+		offlinePlanner: offlinePlanner,
 	}
 }
 
@@ -40,19 +48,13 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 	}
 	fmt.Println("req", req)
 	// 使用LLM生成旅行计划（优先使用用户配置的API Key），并从请求头兜底
-	apiKey := req.OpenAIApiKey
-	baseURL := req.OpenAIBaseURL
-	if apiKey == "" {
-		apiKey = c.GetHeader("X-OpenAI-API-Key")
-	}
-	if baseURL == "" {
-		baseURL = c.GetHeader("X-OpenAI-Base-URL")
-	}
-	if req.OpenAIModel == "" {
-		req.OpenAIModel = c.GetHeader("X-OpenAI-Model")
-	}
+	apiKey, baseURL, model := llmCredentials(c, req.OpenAIApiKey, req.OpenAIBaseURL, req.OpenAIModel)
+	req.OpenAIModel = model
 
-	if apiKey == "" && h.llmService != nil {
+	// 用户Key优先，其次服务端Key；都没有时只能使用离线规划
+	llmAvailable := apiKey != "" || (h.llmService != nil && h.llmService.HasServerKey())
+	offlineFallback := h.offlinePlanner != nil && h.offlinePlanner.FallbackEnabled() && req.Mode != models.PlanModeOffline
+	if !llmAvailable && !offlineFallback && req.Mode != models.PlanModeOffline {
 		// 明确返回可读错误，避免误导性"未配置环境变量"信息
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "OpenAI API key missing",
@@ -61,8 +63,65 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 		return
 	}
 
-	planResult, err := h.llmService.GenerateTravelPlanWithKey(&req, apiKey, baseURL)
+	locale, ok := h.planLocale(userID, req.Locale)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+		return
+	}
+	if err := services.NormalizeTripPreferences(req.Preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preferences", "details": err.Error()})
+		return
+	}
+	req.Preferences = h.planPreferences(userID, req.Preferences)
+
+	// 地图服务（grounded 模式、离线规划的POI搜索）优先使用用户在设置中保存的高德Key
+	var amapKey string
+	if h.userService != nil {
+		amapKey = h.userService.AmapAPIKey(userID)
+	}
+
+	planID := uuid.New().String()
+	meta := services.LLMCallMeta{UserID: userID, PlanID: planID, Locale: locale, BypassCache: bypassLLMCache(c)}
+	var planResult *services.TravelPlanResult
+	var err error
+	switch req.Mode {
+	case "", models.PlanModeStandard:
+		if llmAvailable {
+			planResult, err = h.llmService.GenerateTravelPlanWithKey(meta, &req, apiKey, baseURL)
+		}
+	case models.PlanModeGrounded:
+		var provider services.MapProvider
+		if h.maps != nil {
+			provider = h.maps.WithAmapKey(amapKey).ForDestination(req.Destination)
+		}
+		if provider == nil || !provider.Available() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Map provider is not configured", "details": "grounded 模式需要可用的地图服务（高德API Key或OpenStreetMap）"})
+			return
+		}
+		if llmAvailable {
+			planResult, err = h.llmService.GenerateGroundedTravelPlanWithKey(meta, &req, provider, apiKey, baseURL)
+		}
+	case models.PlanModeOffline:
+		if h.offlinePlanner == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Offline planner is not available"})
+			return
+		}
+		planResult, err = h.offlinePlanner.GeneratePlanWithKey(&req, locale, amapKey)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid generation mode"})
+		return
+	}
+	// 模型不可用（没有Key、服务出错或配额用尽）时改用离线规划兜底，被输入安全检查拦截的请求除外
+	if planResult == nil && offlineFallback && !isInputRejection(err) {
+		if err != nil {
+			log.Printf("LLM生成行程失败，改用离线规划: %v", err)
+		}
+		planResult, err = h.offlinePlanner.GeneratePlanWithKey(&req, locale, amapKey)
+	}
 	if err != nil {
+		if respondLLMError(c, err) {
+			return
+		}
 		// 返回具体的错误信息以便调试
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate travel plan",
@@ -71,9 +130,25 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 		return
 	}
 
+	// 按活动重新核算费用；超出预算时按配置先让模型压缩费用，仍超支则在计划中记录提醒
+	currency := services.CurrencyForLocale(locale)
+	budgetCheck := services.ValidatePlanBudget(planResult, req.Budget, req.People, currency, locale)
+	if budgetCheck.OverBudget && planResult.Generator == services.PlanGeneratorLLM && h.llmService.RebalanceEnabled() {
+		rebalanced, err := h.llmService.RebalancePlanBudgetWithKey(meta, &req, planResult, budgetCheck, apiKey, baseURL)
+		if err != nil {
+			log.Printf("压缩行程费用失败，保留原行程: %v", err)
+		} else {
+			planResult = rebalanced
+			budgetCheck = services.ValidatePlanBudget(planResult, req.Budget, req.People, currency, locale)
+		}
+	}
+
+	// 有可用的地图服务时，保存后在后台解析活动坐标
+	geocodeProvider := h.planMapProvider(userID, req.Destination)
+
 	// 创建旅行计划记录
 	plan := &models.TravelPlan{
-		ID:          uuid.New().String(),
+		ID:          planID,
 		UserID:      userID,
 		Title:       req.Title,
 		Destination: req.Destination,
@@ -81,9 +156,22 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 		EndDate:     req.EndDate.Time,
 		Budget:      req.Budget,
 		People:      req.People,
+		Preferences: req.Preferences,
 		Status:      "planned",
+		Locale:      locale,
+		Currency:    currency,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+
+		BudgetBreakdown: planResult.Budget.Breakdown,
+		BudgetWarnings:  budgetCheck.Warnings,
+		Recommendations: planResult.Recommendations,
+		Provider:        planResult.Provider,
+		Model:           planResult.Model,
+		PromptVersions:  planResult.PromptVersions,
+	}
+	if geocodeProvider != nil {
+		plan.GeocodeStatus = models.PlanGeocodePending
 	}
 
 	if err := h.travelService.CreateTravelPlan(plan); err != nil {
@@ -109,17 +197,7 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 
 		// 创建活动
 		for _, activity := range dayPlan.Activities {
-			activityRecord := &models.Activity{
-				ID:          uuid.New().String(),
-				DayID:       travelDay.ID,
-				Type:        activity.Type,
-				Title:       activity.Title,
-				Description: activity.Description,
-				Location:    activity.Location,
-				Cost:        activity.Cost,
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-			}
+			activityRecord := activity.ToRecord(travelDay.ID, travelDay.Date)
 
 			if err := h.travelService.CreateActivity(activityRecord); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create activity"})
@@ -132,6 +210,55 @@ func (h *TravelHandler) CreateTravelPlan(c *gin.Context) {
 		"plan":   plan,
 		"result": planResult,
 	})
+
+	if geocodeProvider != nil {
+		h.geocodePlanInBackground(plan.ID, userID, geocodeProvider)
+	}
+}
+
+// planLocale 确定行程语言：请求中的 locale 优先，其次用户资料，最后使用默认语言。
+// 请求中给出了不支持的语言时返回 false
+func (h *TravelHandler) planLocale(userID, requested string) (string, bool) {
+	if requested != "" {
+		return services.NormalizeLocale(requested)
+	}
+	var profileLocale string
+	if h.userService != nil {
+		if profile, err := h.userService.GetUserProfile(userID); err == nil && profile != nil {
+			profileLocale = profile.Locale
+		}
+	}
+	return services.ResolveLocale(profileLocale), true
+}
+
+// planPreferences 确定行程偏好：请求中没有填写时使用用户资料中的默认偏好
+func (h *TravelHandler) planPreferences(userID string, requested *models.TripPreferences) *models.TripPreferences {
+	if !requested.IsZero() || h.userService == nil {
+		return requested
+	}
+	if profile, err := h.userService.GetUserProfile(userID); err == nil && profile != nil && !profile.TripPreferences.IsZero() {
+		return profile.TripPreferences
+	}
+	return requested
+}
+
+// bypassLLMCache 请求头带有 Cache-Control: no-cache 时跳过LLM响应缓存
+func bypassLLMCache(c *gin.Context) bool {
+	return strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache")
+}
+
+// llmCredentials 解析用户的LLM配置，请求体优先，其次请求头
+func llmCredentials(c *gin.Context, apiKey, baseURL, model string) (string, string, string) {
+	if apiKey == "" {
+		apiKey = c.GetHeader("X-OpenAI-API-Key")
+	}
+	if baseURL == "" {
+		baseURL = c.GetHeader("X-OpenAI-Base-URL")
+	}
+	if model == "" {
+		model = c.GetHeader("X-OpenAI-Model")
+	}
+	return apiKey, baseURL, model
 }
 
 // GetTravelPlans 获取旅行计划列表
@@ -147,13 +274,17 @@ func (h *TravelHandler) GetTravelPlans(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"plans": plans})
 }
 
-// GetTravelPlan 获取单个旅行计划
+// GetTravelPlan 获取单个旅行计划，coordinate_system 指定活动坐标的坐标系，默认为保存时的坐标系
 func (h *TravelHandler) GetTravelPlan(c *gin.Context) {
 	userID := c.GetString("user_id")
 	planID := c.Param("id")
+	datum, ok := requestDatum(c, c.Query("coordinate_system"), "")
+	if !ok {
+		return
+	}
 
 	plan, err := h.travelService.GetTravelPlan(planID, userID)
-	if err != nil {
+	if err != nil || plan == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
 		return
 	}
@@ -173,6 +304,9 @@ func (h *TravelHandler) GetTravelPlan(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get activities"})
 			return
 		}
+		for i, act := range acts {
+			acts[i] = services.ActivityInDatum(act, datum)
+		}
 		activitiesByDay[day.ID] = acts
 	}
 
@@ -183,11 +317,24 @@ func (h *TravelHandler) GetTravelPlan(c *gin.Context) {
 		return
 	}
 
+	// 行程可行性：相邻活动之间的路上时间是否来得及，没有可用的地图服务时不检查。
+	// 路线按两端坐标缓存，重复查看只在活动被修改或重新解析坐标后查询变化的路段
+	warnings := []models.FeasibilityWarning{}
+	var feasibility *services.FeasibilityReport
+	if provider := h.planMapProvider(userID, plan.Destination); provider != nil {
+		if tree, err := h.travelService.GetPlanTree(planID, userID); err == nil && tree != nil {
+			feasibility = h.travelService.CheckPlanFeasibility(tree, provider)
+			warnings = feasibility.Warnings
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"plan":              plan,
 		"days":              days,
 		"activities_by_day": activitiesByDay,
 		"expense_summary":   expenseSummary,
+		"warnings":          warnings,
+		"feasibility":       feasibility,
 	})
 }
 
@@ -210,6 +357,40 @@ func (h *TravelHandler) UpdateTravelPlan(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Travel plan updated successfully"})
 }
 
+// UpdatePlanRecommendations 编辑旅行计划的建议列表（整体替换）
+func (h *TravelHandler) UpdatePlanRecommendations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	planID := c.Param("id")
+
+	var req models.UpdateRecommendationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Recommendations) > services.MaxRecommendations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d recommendations are allowed", services.MaxRecommendations)})
+		return
+	}
+
+	plan, err := h.travelService.GetTravelPlan(planID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel plan"})
+		return
+	}
+	if plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel plan not found"})
+		return
+	}
+
+	recommendations, err := h.travelService.UpdatePlanRecommendations(planID, userID, req.Recommendations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recommendations": recommendations})
+}
+
 // DeleteTravelPlan 删除旅行计划
 func (h *TravelHandler) DeleteTravelPlan(c *gin.Context) {
 	userID := c.GetString("user_id")
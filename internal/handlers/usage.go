@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageHandler struct {
+	usageService *services.UsageService
+	quotaService *services.QuotaService
+}
+
+func NewUsageHandler(usageService *services.UsageService, quotaService *services.QuotaService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+		quotaService: quotaService,
+	}
+}
+
+// GetUsage 获取当前用户的LLM用量
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	report, err := h.usageService.GetUserUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": report})
+}
+
+// GetUsageReport 获取全站LLM用量报表（管理员）
+func (h *UsageHandler) GetUsageReport(c *gin.Context) {
+	report, err := h.usageService.GetUsageReport()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": report})
+}
+
+// GetQuota 获取当前用户的配额使用情况
+func (h *UsageHandler) GetQuota(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	status, err := h.quotaService.GetQuotaStatus(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quota": status})
+}
+
+// GetUserQuota 获取指定用户的配额使用情况（管理员）
+func (h *UsageHandler) GetUserQuota(c *gin.Context) {
+	status, err := h.quotaService.GetQuotaStatus(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quota": status})
+}
+
+// SetUserQuota 覆盖指定用户的配额（管理员）
+func (h *UsageHandler) SetUserQuota(c *gin.Context) {
+	var req models.LLMQuota
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DailyRequests < 0 || req.DailyTokens < 0 || req.MonthlyRequests < 0 || req.MonthlyTokens < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quota values must not be negative"})
+		return
+	}
+
+	override, err := h.quotaService.SetQuotaOverride(c.Param("user_id"), req, c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"override": override})
+}
+
+// DeleteUserQuota 删除指定用户的配额覆盖（管理员）
+func (h *UsageHandler) DeleteUserQuota(c *gin.Context) {
+	if err := h.quotaService.DeleteQuotaOverride(c.Param("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quota override"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota override deleted"})
+}
+
+// respondQuotaExceeded 如果错误是配额超限则返回429并返回true
+func respondQuotaExceeded(c *gin.Context, err error) bool {
+	var quotaErr *services.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	retryAfter := int(time.Until(quotaErr.ResetAt).Seconds())
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":    "LLM quota exceeded",
+		"details":  quotaErr.Error(),
+		"period":   quotaErr.Period,
+		"metric":   quotaErr.Metric,
+		"limit":    quotaErr.Limit,
+		"used":     quotaErr.Used,
+		"reset_at": quotaErr.ResetAt,
+	})
+	return true
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"ai-travel-planner/internal/models"
 	"ai-travel-planner/internal/services"
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -180,6 +181,48 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// 行程语言保存在用户资料中
+	if rawLocale, exists := updates["locale"]; exists {
+		localeStr, _ := rawLocale.(string)
+		locale, ok := services.NormalizeLocale(localeStr)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+			return
+		}
+		if _, err := h.userService.EnsureUserProfile(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		if err := h.userService.UpdateUserProfile(userID, map[string]interface{}{"locale": locale}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		delete(updates, "locale")
+	}
+
+	// 默认行程偏好保存在用户资料中，null 表示清除
+	if rawPreferences, exists := updates["trip_preferences"]; exists {
+		var preferences *models.TripPreferences
+		data, _ := json.Marshal(rawPreferences)
+		if err := json.Unmarshal(data, &preferences); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trip preferences"})
+			return
+		}
+		if err := services.NormalizeTripPreferences(preferences); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := h.userService.EnsureUserProfile(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		if err := h.userService.UpdateUserProfile(userID, map[string]interface{}{"trip_preferences": preferences}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		delete(updates, "trip_preferences")
+	}
+
 	// 更新用户信息
 	if err := h.userService.UpdateUser(userID, updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
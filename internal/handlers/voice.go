@@ -10,11 +10,13 @@ import (
 
 type VoiceHandler struct {
 	voiceService *services.VoiceService
+	llmService   *services.LLMService
 }
 
-func NewVoiceHandler(voiceService *services.VoiceService) *VoiceHandler {
+func NewVoiceHandler(voiceService *services.VoiceService, llmService *services.LLMService) *VoiceHandler {
 	return &VoiceHandler{
 		voiceService: voiceService,
+		llmService:   llmService,
 	}
 }
 
@@ -42,14 +44,12 @@ func (h *VoiceHandler) UnderstandSpeech(c *gin.Context) {
         req.Model = c.GetHeader("X-OpenAI-Model")
     }
 
-    // 需要LLMService，当前通过voiceService无法直接访问，改为从全局服务获取不便。
-    // 简化实现：复用注入到TravelHandler的 LLMService 更合理，但此处快速接入，
-    // 先构造一个临时 LLMService 使用同一配置。
-    cfg := h.voiceService.Config()
-    llm := services.NewLLMService(cfg)
-
-    fields, err := llm.ParseVoiceToPlanFieldsWithKey(req.Transcript, req.OpenAIApiKey, req.BaseURL, req.Model)
+    meta := services.LLMCallMeta{UserID: c.GetString("user_id"), BypassCache: bypassLLMCache(c)}
+    fields, err := h.llmService.ParseVoiceToPlanFieldsWithKey(meta, req.Transcript, req.OpenAIApiKey, req.BaseURL, req.Model)
     if err != nil {
+        if respondLLMError(c, err) {
+            return
+        }
         c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to understand speech", "details": err.Error()})
         return
     }
@@ -79,11 +79,12 @@ func (h *VoiceHandler) UnderstandExpense(c *gin.Context) {
         req.Model = c.GetHeader("X-OpenAI-Model")
     }
 
-    cfg := h.voiceService.Config()
-    llm := services.NewLLMService(cfg)
-
-    fields, err := llm.ParseVoiceToExpenseFieldsWithKey(req.Transcript, req.OpenAIApiKey, req.BaseURL, req.Model)
+    meta := services.LLMCallMeta{UserID: c.GetString("user_id"), BypassCache: bypassLLMCache(c)}
+    fields, err := h.llmService.ParseVoiceToExpenseFieldsWithKey(meta, req.Transcript, req.OpenAIApiKey, req.BaseURL, req.Model)
     if err != nil {
+        if respondLLMError(c, err) {
+            return
+        }
         c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to understand expense speech", "details": err.Error()})
         return
     }
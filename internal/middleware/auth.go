@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"ai-travel-planner/internal/config"
 	"ai-travel-planner/internal/services"
 	"net/http"
 	"strings"
@@ -37,8 +38,33 @@ func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
 
 		// 将用户ID存储到上下文中
 		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
 		c.Next()
 	}
 }
 
+// AuthOptional 可选认证中间件：带有效token时记录用户，否则按匿名请求继续处理
+func AuthOptional(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString != "" {
+			if claims, err := authService.ValidateToken(tokenString); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("email", claims.Email)
+			}
+		}
+		c.Next()
+	}
+}
 
+// AdminRequired 管理员权限中间件，需在 AuthRequired 之后使用
+func AdminRequired(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.IsAdmin(c.GetString("email")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
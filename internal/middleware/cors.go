@@ -12,7 +12,7 @@ func CORS() gin.HandlerFunc {
     config := cors.Config{
         AllowOrigins:     []string{"*"}, // 开发环境允许所有来源
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-        AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-OpenAI-API-Key", "X-OpenAI-Base-URL"},
+        AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-OpenAI-API-Key", "X-OpenAI-Base-URL", "Cache-Control"},
         ExposeHeaders:    []string{"Content-Length"},
         AllowCredentials: false, // 当AllowOrigins为*时，必须设为false
         MaxAge:           12 * time.Hour,
@@ -0,0 +1,49 @@
+package models
+
+// 预算提醒级别
+const (
+	BudgetSeverityInfo     = "info"
+	BudgetSeverityWarning  = "warning"
+	BudgetSeverityCritical = "critical"
+)
+
+// BudgetOverallCategory 针对整体预算（而非某个分类）的提醒或建议
+const BudgetOverallCategory = "overall"
+
+// BudgetAnalysis 行程预算分析结果
+type BudgetAnalysis struct {
+	PlanID      string                 `json:"plan_id"`
+	Currency    string                 `json:"currency"`
+	TotalBudget float64                `json:"total_budget"`
+	TotalSpent  float64                `json:"total_spent"`
+	DaysElapsed int                    `json:"days_elapsed"`
+	TotalDays   int                    `json:"total_days"`
+	Categories  []CategoryBudgetStatus `json:"categories"`
+	Summary     string                 `json:"summary"`
+	Warnings    []BudgetWarning        `json:"warnings"`
+	Suggestions []SavingSuggestion     `json:"suggestions"`
+}
+
+// CategoryBudgetStatus 某个费用分类的预算执行情况（由服务端计算）
+type CategoryBudgetStatus struct {
+	Category       string  `json:"category"`
+	Planned        float64 `json:"planned"`          // 生成行程时的预算分配
+	Spent          float64 `json:"spent"`            // 已记录的实际费用
+	ExpectedToDate float64 `json:"expected_to_date"` // 按已过天数折算的预期花费
+	Overspent      bool    `json:"overspent"`
+	OverspendBy    float64 `json:"overspend_by"` // 超出预期的金额
+}
+
+// BudgetWarning 超支提醒
+type BudgetWarning struct {
+	Category string `json:"category"` // 费用分类或 overall
+	Severity string `json:"severity"` // info, warning, critical
+	Message  string `json:"message"`
+}
+
+// SavingSuggestion 省钱建议
+type SavingSuggestion struct {
+	Category        string  `json:"category"`
+	Suggestion      string  `json:"suggestion"`
+	EstimatedSaving float64 `json:"estimated_saving"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// 行程修改补丁状态
+const (
+	PatchStatusPending    = "pending"
+	PatchStatusApplied    = "applied"
+	PatchStatusSuperseded = "superseded"
+)
+
+// 行程修改操作类型
+const (
+	PatchOpAdd    = "add"
+	PatchOpRemove = "remove"
+	PatchOpModify = "modify"
+)
+
+// PlanChatMessage 行程对话消息
+type PlanChatMessage struct {
+	ID          string     `json:"id" db:"id"`
+	PlanID      string     `json:"plan_id" db:"plan_id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Role        string     `json:"role" db:"role"` // user, assistant
+	Content     string     `json:"content" db:"content"`
+	Patch       *PlanPatch `json:"patch,omitempty" db:"patch"`               // assistant 消息携带的修改建议
+	PatchStatus string     `json:"patch_status,omitempty" db:"patch_status"` // pending, applied, superseded
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PlanPatch LLM返回的结构化行程修改
+type PlanPatch struct {
+	Summary    string               `json:"summary"`
+	Operations []PlanPatchOperation `json:"operations"`
+}
+
+// PlanPatchOperation 单个修改操作
+type PlanPatchOperation struct {
+	Op         string         `json:"op"`  // add, remove, modify
+	Day        int            `json:"day"` // 目标日程（add 必填，modify 时可用于移动到其他天）
+	ActivityID string         `json:"activity_id,omitempty"`
+	Activity   *PatchActivity `json:"activity,omitempty"`
+}
+
+// PatchActivity 补丁中的活动内容
+type PatchActivity struct {
+	Time        string   `json:"time"` // HH:MM
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Location    string   `json:"location"`
+	Cost        *float64 `json:"cost,omitempty"` // 修改时为空表示费用不变
+	Type        string   `json:"type"`
+}
+
+// PlanDiffEntry 补丁应用前后的对比
+type PlanDiffEntry struct {
+	Op         string    `json:"op"`
+	Day        int       `json:"day"`
+	ActivityID string    `json:"activity_id,omitempty"`
+	Before     *Activity `json:"before,omitempty"`
+	After      *Activity `json:"after,omitempty"`
+}
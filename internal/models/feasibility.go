@@ -0,0 +1,22 @@
+package models
+
+// 行程可行性问题类型
+const (
+	FeasibilityTravelConflict  = "travel_conflict"        // 两个活动之间的时间不够路上用
+	FeasibilityExcessiveTravel = "excessive_daily_travel" // 一天的路上时间超过上限
+)
+
+// FeasibilityWarning 行程可行性问题，Severity 取值与预算提醒相同
+type FeasibilityWarning struct {
+	Type           string `json:"type"`
+	Severity       string `json:"severity"`
+	DayNumber      int    `json:"day_number"`
+	FromActivityID string `json:"from_activity_id,omitempty"`
+	ToActivityID   string `json:"to_activity_id,omitempty"`
+	Mode           string `json:"mode,omitempty"` // walking, driving, transit
+
+	// TravelMinutes 路上时间；AvailableMinutes 两个活动开始时间的间隔，或每日路上时间上限
+	TravelMinutes    int    `json:"travel_minutes"`
+	AvailableMinutes int    `json:"available_minutes"`
+	Message          string `json:"message"`
+}
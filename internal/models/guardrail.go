@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// 安全检查阶段
+const (
+	GuardrailStageInput  = "input"  // 拼接进提示词之前的用户输入
+	GuardrailStageOutput = "output" // 持久化之前的模型输出
+)
+
+// GuardrailViolation 一次安全检查违规记录，供管理员复核
+type GuardrailViolation struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	PlanID    string    `json:"plan_id,omitempty"`
+	Endpoint  string    `json:"endpoint"`
+	Stage     string    `json:"stage"` // input, output
+	Field     string    `json:"field"` // 触发检查的字段，如 preferences、transcript
+	Rule      string    `json:"rule"`
+	Excerpt   string    `json:"excerpt"`
+	Blocked   bool      `json:"blocked"` // 是否拦截了请求
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// TripPreferences 行程偏好。除 Description 外均为固定取值，生成行程时转换为结构化约束
+type TripPreferences struct {
+	Description         string   `json:"description,omitempty"`          // 自由描述（兼容旧版前端的 {description: ...}）
+	Pace                string   `json:"pace,omitempty"`                 // relaxed, moderate, packed
+	Interests           []string `json:"interests,omitempty"`            // food, history, culture, nature, art, museums, shopping, nightlife, outdoor, photography, architecture, beach
+	DietaryRestrictions []string `json:"dietary_restrictions,omitempty"` // vegetarian, vegan, halal, kosher, gluten_free, no_pork, no_beef, no_spicy, nut_allergy, seafood_allergy
+	Mobility            string   `json:"mobility,omitempty"`             // full, limited, wheelchair
+	AccessibilityNeeds  []string `json:"accessibility_needs,omitempty"`  // step_free_access, elevator, accessible_restroom, frequent_rest, visual_assistance, hearing_assistance
+	LodgingTier         string   `json:"lodging_tier,omitempty"`         // budget, midrange, upscale, luxury
+	TransportModes      []string `json:"transport_modes,omitempty"`      // walking, public_transit, taxi, car, bicycle, train, flight
+	ChildrenAges        []int    `json:"children_ages,omitempty"`        // 同行儿童的年龄（0-17）
+}
+
+// IsZero 是否没有填写任何偏好
+func (p *TripPreferences) IsZero() bool {
+	return p == nil || (p.Description == "" && p.Pace == "" && len(p.Interests) == 0 &&
+		len(p.DietaryRestrictions) == 0 && p.Mobility == "" && len(p.AccessibilityNeeds) == 0 &&
+		p.LodgingTier == "" && len(p.TransportModes) == 0 && len(p.ChildrenAges) == 0)
+}
+
+// Clone 深拷贝偏好，副本的切片与原偏好互不影响
+func (p *TripPreferences) Clone() *TripPreferences {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.Interests = append([]string(nil), p.Interests...)
+	clone.DietaryRestrictions = append([]string(nil), p.DietaryRestrictions...)
+	clone.AccessibilityNeeds = append([]string(nil), p.AccessibilityNeeds...)
+	clone.TransportModes = append([]string(nil), p.TransportModes...)
+	clone.ChildrenAges = append([]int(nil), p.ChildrenAges...)
+	return &clone
+}
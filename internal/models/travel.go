@@ -59,18 +59,51 @@ func (d DateOnly) MarshalJSON() ([]byte, error) {
 
 // TravelPlan 旅行计划
 type TravelPlan struct {
-	ID          string    `json:"id" db:"id"`
-	UserID      string    `json:"user_id" db:"user_id"`
-	Title       string    `json:"title" db:"title"`
-	Destination string    `json:"destination" db:"destination"`
-	StartDate   time.Time `json:"start_date" db:"start_date"`
-	EndDate     time.Time `json:"end_date" db:"end_date"`
-	Budget      float64   `json:"budget" db:"budget"`
-	People      int       `json:"people" db:"people"`
-	Preferences string    `json:"preferences" db:"preferences"` // JSON字符串存储偏好
-	Status      string    `json:"status" db:"status"`           // draft, planned, active, completed
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          string           `json:"id" db:"id"`
+	UserID      string           `json:"user_id" db:"user_id"`
+	Title       string           `json:"title" db:"title"`
+	Destination string           `json:"destination" db:"destination"`
+	StartDate   time.Time        `json:"start_date" db:"start_date"`
+	EndDate     time.Time        `json:"end_date" db:"end_date"`
+	Budget      float64          `json:"budget" db:"budget"`
+	People      int              `json:"people" db:"people"`
+	Preferences *TripPreferences `json:"preferences,omitempty" db:"preferences"` // 生成时使用的行程偏好
+	Status      string           `json:"status" db:"status"`                     // draft, planned, active, completed
+	Locale      string           `json:"locale" db:"locale"`                     // 行程内容的语言，如 zh-CN、en-US
+	Currency    string           `json:"currency" db:"currency"`                 // 预算与费用的货币，如 CNY、USD
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
+
+	// BudgetBreakdown 生成行程时模型给出的预算分配（分类 -> 金额）
+	BudgetBreakdown map[string]float64 `json:"budget_breakdown,omitempty" db:"budget_breakdown"`
+
+	// BudgetWarnings 生成后核算费用发现的问题（超出预算、分配与活动费用不符等）
+	BudgetWarnings []BudgetWarning `json:"budget_warnings,omitempty" db:"budget_warnings"`
+
+	// PromptVersions 生成该计划时使用的提示词模板版本（模板名 -> 版本），用于 A/B 对比
+	PromptVersions map[string]string `json:"prompt_versions,omitempty" db:"prompt_versions"`
+
+	// Recommendations 生成时给出的旅行建议，用户可以编辑
+	Recommendations []string `json:"recommendations" db:"recommendations"`
+
+	// Provider、Model 生成该计划的模型服务商和模型名，离线规划的 provider 为 offline
+	Provider string `json:"provider,omitempty" db:"provider"`
+	Model    string `json:"model,omitempty" db:"model"`
+
+	// GeocodeStatus 活动坐标的后台解析进度：pending、completed、failed，未解析时为空
+	GeocodeStatus string `json:"geocode_status,omitempty" db:"geocode_status"`
+}
+
+// 行程坐标解析进度
+const (
+	PlanGeocodePending   = "pending"
+	PlanGeocodeCompleted = "completed"
+	PlanGeocodeFailed    = "failed"
+)
+
+// UpdateRecommendationsRequest 编辑旅行建议请求
+type UpdateRecommendationsRequest struct {
+	Recommendations []string `json:"recommendations" binding:"required"`
 }
 
 // TravelDay 旅行日程
@@ -91,7 +124,9 @@ type Activity struct {
 	Type        string    `json:"type" db:"type"` // attraction, restaurant, hotel, transport
 	Title       string    `json:"title" db:"title"`
 	Description string    `json:"description" db:"description"`
-	Location    string    `json:"location" db:"location"` // 地址
+	Location    string    `json:"location" db:"location"`         // 地址
+	POIID       string    `json:"poi_id,omitempty" db:"poi_id"`   // 高德POI ID，经校验的活动才有
+	Address     string    `json:"address,omitempty" db:"address"` // POI详细地址
 	Latitude    float64   `json:"latitude" db:"latitude"`
 	Longitude   float64   `json:"longitude" db:"longitude"`
 	StartTime   time.Time `json:"start_time" db:"start_time"`
@@ -100,8 +135,23 @@ type Activity struct {
 	Notes       string    `json:"notes" db:"notes"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// GeocodeStatus 坐标解析状态：pending、resolved、approximate、unresolved
+	GeocodeStatus string `json:"geocode_status,omitempty" db:"geocode_status"`
+	// GeocodeConfidence 坐标与地点匹配的置信度（0-1）
+	GeocodeConfidence float64 `json:"geocode_confidence,omitempty" db:"geocode_confidence"`
+	// CoordinateSystem 坐标所在的坐标系：wgs84、gcj02、bd09，为空表示未知（按原样使用）
+	CoordinateSystem string `json:"coordinate_system,omitempty" db:"coordinate_system"`
 }
 
+// 活动坐标解析状态
+const (
+	GeocodeStatusPending     = "pending"     // 等待解析
+	GeocodeStatusResolved    = "resolved"    // 已匹配到具体地点
+	GeocodeStatusApproximate = "approximate" // 只匹配到道路、区县等粗略位置
+	GeocodeStatusUnresolved  = "unresolved"  // 没有找到匹配的地点
+)
+
 // Expense 费用记录
 type Expense struct {
 	ID          string    `json:"id" db:"id"`
@@ -117,18 +167,27 @@ type Expense struct {
 
 // CreateTravelPlanRequest 创建旅行计划请求
 type CreateTravelPlanRequest struct {
-	Title         string                 `json:"title" binding:"required"`
-	Destination   string                 `json:"destination" binding:"required"`
-	StartDate     DateOnly               `json:"start_date" binding:"required"`
-	EndDate       DateOnly               `json:"end_date" binding:"required"`
-	Budget        float64                `json:"budget" binding:"required,min=0"`
-	People        int                    `json:"people" binding:"required,min=1"`
-	Preferences   map[string]interface{} `json:"preferences"`
-	OpenAIApiKey  string                 `json:"openai_api_key"`  // 可选的用户API Key
-	OpenAIBaseURL string                 `json:"openai_base_url"` // 可选的用户Base URL
-    OpenAIModel   string                 `json:"openai_model"`    // 可选的模型名
+	Title         string           `json:"title" binding:"required"`
+	Destination   string           `json:"destination" binding:"required"`
+	StartDate     DateOnly         `json:"start_date" binding:"required"`
+	EndDate       DateOnly         `json:"end_date" binding:"required"`
+	Budget        float64          `json:"budget" binding:"required,min=0"`
+	People        int              `json:"people" binding:"required,min=1"`
+	Preferences   *TripPreferences `json:"preferences"`     // 可选的行程偏好，默认使用用户资料中的偏好
+	OpenAIApiKey  string           `json:"openai_api_key"`  // 可选的用户API Key
+	OpenAIBaseURL string           `json:"openai_base_url"` // 可选的用户Base URL
+	OpenAIModel   string           `json:"openai_model"`    // 可选的模型名
+	Locale        string           `json:"locale"`          // 可选的输出语言，默认使用用户资料中的语言
+	Mode          string           `json:"mode"`            // 生成模式：standard（默认）、grounded 或 offline
 }
 
+// 行程生成模式
+const (
+	PlanModeStandard = "standard" // 模型直接生成行程
+	PlanModeGrounded = "grounded" // 模型调用高德POI工具，活动对应真实地点
+	PlanModeOffline  = "offline"  // 不调用模型，由规则规划器生成
+)
+
 // VoiceInputRequest 语音输入请求
 type VoiceInputRequest struct {
 	AudioData string `json:"audio_data" binding:"required"` // base64编码的音频数据
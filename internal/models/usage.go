@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+)
+
+// LLM调用状态：调用前预留为 pending，返回后结算为 succeeded 或 failed
+const (
+	UsageStatusPending   = "pending"
+	UsageStatusSucceeded = "succeeded"
+	UsageStatusFailed    = "failed"
+)
+
+// LLMUsage 单次LLM调用的用量记录
+type LLMUsage struct {
+	ID               string    `json:"id" db:"id"`
+	UserID           string    `json:"user_id" db:"user_id"`
+	PlanID           string    `json:"plan_id" db:"plan_id"`
+	Endpoint         string    `json:"endpoint" db:"endpoint"` // plan_generation, voice_understanding, budget_analysis
+	Model            string    `json:"model" db:"model"`
+	KeySource        string    `json:"key_source" db:"key_source"` // server, user
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens" db:"total_tokens"`
+	Cost             float64   `json:"cost" db:"cost"`     // 按价格表估算的费用
+	Status           string    `json:"status" db:"status"` // pending, succeeded, failed
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// UsageTotals 用量汇总
+type UsageTotals struct {
+	Requests         int     `json:"requests"`
+	FailedRequests   int     `json:"failed_requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add 累加一条用量记录
+func (t *UsageTotals) Add(usage *LLMUsage) {
+	t.Requests++
+	if usage.Status == UsageStatusFailed {
+		t.FailedRequests++
+	}
+	t.PromptTokens += usage.PromptTokens
+	t.CompletionTokens += usage.CompletionTokens
+	t.TotalTokens += usage.TotalTokens
+	t.Cost += usage.Cost
+}
+
+// UsageReport 用量报表
+type UsageReport struct {
+	Currency   string                  `json:"currency"`
+	Total      UsageTotals             `json:"total"`
+	ByEndpoint map[string]*UsageTotals `json:"by_endpoint"`
+	ByPlan     map[string]*UsageTotals `json:"by_plan,omitempty"`
+	ByUser     map[string]*UsageTotals `json:"by_user,omitempty"`
+}
+
+// LLMQuota 每用户LLM配额（仅在使用服务端Key时生效），0 表示不限制
+type LLMQuota struct {
+	DailyRequests   int `json:"daily_requests" db:"daily_requests"`
+	DailyTokens     int `json:"daily_tokens" db:"daily_tokens"`
+	MonthlyRequests int `json:"monthly_requests" db:"monthly_requests"`
+	MonthlyTokens   int `json:"monthly_tokens" db:"monthly_tokens"`
+}
+
+// UserQuotaOverride 管理员为单个用户设置的配额
+type UserQuotaOverride struct {
+	UserID    string    `json:"user_id" db:"user_id"`
+	Quota     LLMQuota  `json:"quota" db:"quota"`
+	UpdatedBy string    `json:"updated_by" db:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// QuotaPeriodStatus 单个周期内的配额使用情况
+type QuotaPeriodStatus struct {
+	RequestLimit int       `json:"request_limit"`
+	RequestsUsed int       `json:"requests_used"`
+	TokenLimit   int       `json:"token_limit"`
+	TokensUsed   int       `json:"tokens_used"`
+	ResetAt      time.Time `json:"reset_at"`
+}
+
+// QuotaStatus 用户配额状态
+type QuotaStatus struct {
+	UserID     string            `json:"user_id"`
+	Overridden bool              `json:"overridden"`
+	Daily      QuotaPeriodStatus `json:"daily"`
+	Monthly    QuotaPeriodStatus `json:"monthly"`
+}
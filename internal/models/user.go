@@ -23,6 +23,9 @@ type UserProfile struct {
 	LastName    string    `json:"last_name" db:"last_name"`
 	Phone       string    `json:"phone" db:"phone"`
 	Preferences string    `json:"preferences" db:"preferences"` // JSON字符串存储偏好设置
+	Locale      string    `json:"locale" db:"locale"`           // 偏好的行程语言，如 zh-CN、en-US
+	// TripPreferences 默认的行程偏好，创建行程未填写偏好时使用
+	TripPreferences *TripPreferences `json:"trip_preferences,omitempty" db:"trip_preferences"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,276 @@
+package prompts
+
+import (
+	"ai-travel-planner/internal/config"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Store 从目录加载的版本化提示词模板，支持热加载
+//
+// 目录结构：<dir>/<name>/<version>.tmpl，例如 prompts/travel_plan/v2.tmpl；
+// 本地化模板为 <dir>/<name>/<version>.<locale>.tmpl，例如 prompts/travel_plan/v2.en-US.tmpl。
+// 计划记录的版本号用于对比实验，已经上线的版本不要再修改，调整提示词时新增版本
+type Store struct {
+	config      config.PromptsConfig
+	mutex       sync.RWMutex
+	templates   map[string]map[string]map[string]*template.Template // name -> version -> locale -> template，默认语言的 locale 为空
+	fingerprint string
+}
+
+// Rendered 渲染结果
+type Rendered struct {
+	Name    string
+	Version string
+	Locale  string // 实际使用的模板语言，空表示默认模板
+	Text    string
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	},
+}
+
+// NewStore 创建模板仓库并立即加载一次
+func NewStore(cfg config.PromptsConfig) (*Store, error) {
+	s := &Store{config: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload 重新加载模板目录，失败时保留之前的模板
+func (s *Store) Reload() error {
+	templates := make(map[string]map[string]map[string]*template.Template)
+
+	files, err := filepath.Glob(filepath.Join(s.config.Dir, "*", "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no prompt templates found in %s", s.config.Dir)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(filepath.Dir(file))
+		version := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		locale := ""
+		if i := strings.Index(version, "."); i >= 0 {
+			version, locale = version[:i], version[i+1:]
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("读取提示词模板失败 %s: %w", file, err)
+		}
+		tmpl, err := template.New(filepath.Base(file)).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("解析提示词模板失败 %s: %w", file, err)
+		}
+
+		if templates[name] == nil {
+			templates[name] = make(map[string]map[string]*template.Template)
+		}
+		if templates[name][version] == nil {
+			templates[name][version] = make(map[string]*template.Template)
+		}
+		templates[name][version][locale] = tmpl
+	}
+
+	fingerprint, _ := s.computeFingerprint()
+
+	s.mutex.Lock()
+	s.templates = templates
+	s.fingerprint = fingerprint
+	s.mutex.Unlock()
+	return nil
+}
+
+// Watch 定期检查模板目录，文件变化时自动重新加载
+func (s *Store) Watch() {
+	if s.config.ReloadIntervalSeconds <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(s.config.ReloadIntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			fingerprint, err := s.computeFingerprint()
+			if err != nil {
+				continue
+			}
+			s.mutex.RLock()
+			changed := fingerprint != s.fingerprint
+			s.mutex.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := s.Reload(); err != nil {
+				log.Printf("提示词模板热加载失败，继续使用旧模板: %v", err)
+				continue
+			}
+			log.Printf("提示词模板已重新加载: %s", s.config.Dir)
+		}
+	}()
+}
+
+// computeFingerprint 根据模板文件的路径、大小和修改时间计算指纹
+func (s *Store) computeFingerprint() (string, error) {
+	files, err := filepath.Glob(filepath.Join(s.config.Dir, "*", "*.tmpl"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s|%d|%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Versions 返回某个模板的所有版本（从旧到新）
+func (s *Store) Versions(name string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var versions []string
+	for version := range s.templates[name] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	return versions
+}
+
+// Render 选择版本并渲染模板。subject 用于 A/B 实验分组（通常为用户ID），同一 subject 始终命中同一版本；
+// locale 为期望的语言，找不到时依次回退到同语种模板和默认模板
+func (s *Store) Render(name, subject, locale string, data interface{}) (*Rendered, error) {
+	version, err := s.selectVersion(name, subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.RenderVersion(name, version, locale, data)
+}
+
+// RenderVersion 渲染指定版本的模板
+func (s *Store) RenderVersion(name, version, locale string, data interface{}) (*Rendered, error) {
+	s.mutex.RLock()
+	tmpl, matched := pickLocale(s.templates[name][version], locale)
+	s.mutex.RUnlock()
+	if tmpl == nil {
+		return nil, fmt.Errorf("prompt template %s version %s not found", name, version)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render prompt template %s/%s: %w", name, version, err)
+	}
+	return &Rendered{Name: name, Version: version, Locale: matched, Text: strings.TrimSpace(buf.String())}, nil
+}
+
+// pickLocale 按 完全匹配 > 同语种 > 默认模板 的顺序选择本地化模板
+func pickLocale(localized map[string]*template.Template, locale string) (*template.Template, string) {
+	if locale != "" {
+		for candidate, tmpl := range localized {
+			if candidate != "" && strings.EqualFold(candidate, locale) {
+				return tmpl, candidate
+			}
+		}
+		language := strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+		var candidates []string
+		for candidate := range localized {
+			if candidate != "" && strings.ToLower(strings.SplitN(candidate, "-", 2)[0]) == language {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) > 0 {
+			sort.Strings(candidates)
+			return localized[candidates[0]], candidates[0]
+		}
+	}
+	return localized[""], ""
+}
+
+// selectVersion 按 固定版本 > A/B 实验 > 最新版本 的顺序选择模板版本
+func (s *Store) selectVersion(name, subject string) (string, error) {
+	versions := s.Versions(name)
+	if len(versions) == 0 {
+		return "", fmt.Errorf("prompt template %s not found", name)
+	}
+
+	if pinned := s.config.Versions[name]; pinned != "" {
+		return pinned, nil
+	}
+
+	if weights := s.config.Experiments[name]; len(weights) > 0 {
+		if version := pickWeighted(weights, name, subject); version != "" {
+			return version, nil
+		}
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// pickWeighted 按权重选择实验版本，subject 非空时按哈希稳定分组
+func pickWeighted(weights map[string]int, name, subject string) string {
+	var candidates []string
+	total := 0
+	for version, weight := range weights {
+		if weight > 0 {
+			candidates = append(candidates, version)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+
+	var point int
+	if subject != "" {
+		h := fnv.New32a()
+		h.Write([]byte(name + ":" + subject))
+		point = int(h.Sum32() % uint32(total))
+	} else {
+		point = rand.Intn(total)
+	}
+
+	for _, version := range candidates {
+		point -= weights[version]
+		if point < 0 {
+			return version
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// versionLess 比较版本号，v2 < v10
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
@@ -0,0 +1,137 @@
+package prompts
+
+import (
+	"ai-travel-planner/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, version, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name, version+".tmpl"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_RenderLatestAndPinned(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "greeting", "v2", "hello {{.Name}}")
+	writeTemplate(t, dir, "greeting", "v10", "hi {{.Name}}")
+
+	store, err := NewStore(config.PromptsConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	rendered, err := store.Render("greeting", "", "", map[string]string{"Name": "Tom"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Version != "v10" || rendered.Text != "hi Tom" {
+		t.Errorf("Expected latest version v10, got %s: %q", rendered.Version, rendered.Text)
+	}
+
+	pinned, err := NewStore(config.PromptsConfig{Dir: dir, Versions: map[string]string{"greeting": "v2"}})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	rendered, err = pinned.Render("greeting", "", "", map[string]string{"Name": "Tom"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Version != "v2" || rendered.Text != "hello Tom" {
+		t.Errorf("Expected pinned version v2, got %s: %q", rendered.Version, rendered.Text)
+	}
+}
+
+func TestStore_ExperimentIsStickyPerSubject(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "greeting", "v1", "a")
+	writeTemplate(t, dir, "greeting", "v2", "b")
+
+	store, err := NewStore(config.PromptsConfig{
+		Dir:         dir,
+		Experiments: map[string]map[string]int{"greeting": {"v1": 50, "v2": 50}},
+	})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"} {
+		first, _ := store.Render("greeting", user, "", nil)
+		second, _ := store.Render("greeting", user, "", nil)
+		if first.Version != second.Version {
+			t.Errorf("Expected sticky version for %s, got %s and %s", user, first.Version, second.Version)
+		}
+		seen[first.Version] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected both experiment arms to be used, got %v", seen)
+	}
+}
+
+func TestStore_ReloadKeepsOldTemplatesOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "greeting", "v1", "hello")
+
+	store, err := NewStore(config.PromptsConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	writeTemplate(t, dir, "greeting", "v2", "{{.Broken")
+	if err := store.Reload(); err == nil {
+		t.Fatal("Expected reload error for broken template")
+	}
+	rendered, err := store.Render("greeting", "", "", nil)
+	if err != nil || rendered.Version != "v1" {
+		t.Errorf("Expected old template to remain active, got %+v, %v", rendered, err)
+	}
+}
+
+func TestStore_RenderLocalized(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "greeting", "v1", "你好")
+	writeTemplate(t, dir, "greeting", "v1.en-US", "hello")
+
+	store, err := NewStore(config.PromptsConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	cases := map[string]string{
+		"en-US": "hello",
+		"en-GB": "hello", // 同语种回退
+		"ja-JP": "你好",    // 默认模板
+		"":      "你好",
+	}
+	for locale, expected := range cases {
+		rendered, err := store.Render("greeting", "", locale, nil)
+		if err != nil {
+			t.Fatalf("Render(%q) failed: %v", locale, err)
+		}
+		if rendered.Text != expected {
+			t.Errorf("Render(%q) = %q, expected %q", locale, rendered.Text, expected)
+		}
+		if rendered.Version != "v1" {
+			t.Errorf("Render(%q) version = %q, expected v1", locale, rendered.Version)
+		}
+	}
+}
+
+func TestStore_RepositoryTemplatesParse(t *testing.T) {
+	store, err := NewStore(config.PromptsConfig{Dir: "../../prompts"})
+	if err != nil {
+		t.Fatalf("Failed to load repository prompts: %v", err)
+	}
+	for _, name := range []string{"system", "travel_plan", "voice_plan_fields", "voice_expense_fields", "budget_analysis", "plan_translate", "plan_rebalance"} {
+		if len(store.Versions(name)) == 0 {
+			t.Errorf("Missing prompt template %s", name)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// 坐标匹配置信度的阈值：低于 geocodeApproximateConfidence 的结果（如只匹配到城市）不保存坐标
+const (
+	geocodeResolvedConfidence    = 0.5
+	geocodeApproximateConfidence = 0.2
+	geocodeMaxPOICandidates      = 5
+)
+
+// ActivityGeocodeSummary 一次活动坐标解析的统计
+type ActivityGeocodeSummary struct {
+	PlanID      string `json:"plan_id"`
+	Provider    string `json:"provider"`
+	Total       int    `json:"total"`
+	Resolved    int    `json:"resolved"`
+	Approximate int    `json:"approximate"`
+	Unresolved  int    `json:"unresolved"`
+	Skipped     int    `json:"skipped"` // 已有坐标、未重新解析的活动
+
+	// UnresolvedActivities 没有找到地点的活动ID，需要用户手动补充地址
+	UnresolvedActivities []string `json:"unresolved_activities"`
+}
+
+// geocodeMatch 一个地点的匹配结果
+type geocodeMatch struct {
+	longitude, latitude float64
+	address             string
+	confidence          float64
+}
+
+// GeocodePlanActivities 按活动的地点（为空时用标题）在目的地城市范围内解析坐标，保存坐标和匹配置信度，
+// 找不到的活动标记为 unresolved。force 为 false 时跳过已有坐标的活动。计划不存在时返回 nil
+func (s *TravelService) GeocodePlanActivities(planID, userID string, provider MapProvider, force bool) (*ActivityGeocodeSummary, error) {
+	tree, err := s.GetPlanTree(planID, userID)
+	if err != nil || tree == nil {
+		return nil, err
+	}
+	s.db.SetPlanGeocodeStatus(planID, userID, models.PlanGeocodePending)
+
+	city := strings.TrimSpace(tree.Plan.Destination)
+	summary := &ActivityGeocodeSummary{PlanID: planID, Provider: provider.Name(), UnresolvedActivities: []string{}}
+	matches := make(map[string]*geocodeMatch) // 同一行程中重复的地点只查询一次
+	var lookups, failures int
+	var lastErr error
+
+	for _, day := range tree.Days {
+		for _, activity := range day.Activities {
+			if !force && hasCoordinates(activity) {
+				// 由 grounded 模式或用户填写的坐标
+				if s.db.ModifyActivity(activity.ID, func(current *models.Activity) {
+					if current.GeocodeStatus == "" {
+						current.GeocodeStatus, current.GeocodeConfidence = models.GeocodeStatusResolved, 1
+					}
+				}) {
+					summary.Total++
+					summary.Skipped++
+				}
+				continue
+			}
+
+			query := activityGeocodeQuery(activity)
+			match, cached := matches[query]
+			if !cached && query != "" {
+				lookups++
+				match, err = geocodeActivity(provider, city, query, activity.Title)
+				if err != nil {
+					failures++
+					lastErr = err
+				}
+				matches[query] = match
+			}
+
+			status := models.GeocodeStatusUnresolved
+			switch {
+			case match != nil && match.confidence >= geocodeResolvedConfidence:
+				status = models.GeocodeStatusResolved
+			case match != nil && match.confidence >= geocodeApproximateConfidence:
+				status = models.GeocodeStatusApproximate
+			default:
+				match = nil
+			}
+
+			// 解析期间活动可能被用户修改或删除：只更新当前记录的坐标和解析状态，已删除的活动跳过
+			if !s.db.ModifyActivity(activity.ID, func(current *models.Activity) {
+				current.GeocodeStatus = status
+				current.Longitude, current.Latitude, current.GeocodeConfidence, current.CoordinateSystem = 0, 0, 0, ""
+				if match != nil {
+					current.Longitude, current.Latitude = match.longitude, match.latitude
+					current.CoordinateSystem = provider.Datum()
+					current.GeocodeConfidence = match.confidence
+					if current.Address == "" {
+						current.Address = match.address
+					}
+				}
+			}) {
+				continue
+			}
+
+			summary.Total++
+			switch status {
+			case models.GeocodeStatusResolved:
+				summary.Resolved++
+			case models.GeocodeStatusApproximate:
+				summary.Approximate++
+			default:
+				summary.Unresolved++
+				summary.UnresolvedActivities = append(summary.UnresolvedActivities, activity.ID)
+			}
+		}
+	}
+
+	// 所有查询都失败（如Key无效、服务不可用）时视为解析失败
+	if lookups > 0 && failures == lookups {
+		s.db.SetPlanGeocodeStatus(planID, userID, models.PlanGeocodeFailed)
+		return summary, fmt.Errorf("geocoding failed: %v", lastErr)
+	}
+	s.db.SetPlanGeocodeStatus(planID, userID, models.PlanGeocodeCompleted)
+	return summary, nil
+}
+
+// activityGeocodeQuery 用于解析坐标的地点文字
+func activityGeocodeQuery(activity *models.Activity) string {
+	if location := strings.TrimSpace(activity.Location); location != "" {
+		return location
+	}
+	return strings.TrimSpace(activity.Title)
+}
+
+// geocodeActivity 先在城市范围内搜索POI，名称匹配度不够时再用地理编码，取置信度较高的结果；
+// 两种方式都出错时返回错误，没有结果时返回 nil
+func geocodeActivity(provider MapProvider, city, query, title string) (*geocodeMatch, error) {
+	var best *geocodeMatch
+	pois, poiErr := provider.SearchPOI(query, city, "")
+	if poiErr == nil {
+		for i, poi := range pois.Pois {
+			if i >= geocodeMaxPOICandidates {
+				break
+			}
+			lng, lat, ok := poi.Coordinates()
+			if !ok {
+				continue
+			}
+			confidence := math.Max(nameConfidence(query, poi.Name), nameConfidence(title, poi.Name))
+			if best == nil || confidence > best.confidence {
+				best = &geocodeMatch{longitude: lng, latitude: lat, address: poi.Address, confidence: confidence}
+			}
+		}
+	}
+	if best != nil && best.confidence >= 0.85 {
+		return best, nil
+	}
+
+	geocodes, geoErr := provider.Geocode(scopedAddress(city, query))
+	if geoErr == nil && len(geocodes.Geocodes) > 0 {
+		geocode := geocodes.Geocodes[0]
+		if lng, lat, ok := ParseLngLat(geocode.Location); ok {
+			confidence := geocodeLevelConfidence(geocode.Level)
+			if best == nil || confidence > best.confidence {
+				best = &geocodeMatch{longitude: lng, latitude: lat, address: geocode.FormattedAddress, confidence: confidence}
+			}
+		}
+	}
+	if best == nil && poiErr != nil && geoErr != nil {
+		return nil, poiErr
+	}
+	return best, nil
+}
+
+// scopedAddress 给地址加上城市，避免匹配到其他城市的同名地点
+func scopedAddress(city, query string) string {
+	if city == "" || strings.Contains(query, city) {
+		return query
+	}
+	for _, r := range city {
+		if unicode.Is(unicode.Han, r) {
+			return city + query
+		}
+	}
+	return query + ", " + city
+}
+
+// nameConfidence 比较活动地点与POI名称的相似度：相同为 1，互相包含为 0.85，否则为字符二元组的 Dice 系数（最高 0.8）
+func nameConfidence(query, name string) float64 {
+	a, b := normalizePlaceName(query), normalizePlaceName(name)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 0.85
+	}
+	return math.Min(0.8, diceCoefficient(a, b))
+}
+
+func normalizePlaceName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// diceCoefficient 两个字符串的字符二元组 Dice 系数
+func diceCoefficient(a, b string) float64 {
+	bigrams := func(s string) map[string]int {
+		runes := []rune(s)
+		counts := make(map[string]int)
+		for i := 0; i+1 < len(runes); i++ {
+			counts[string(runes[i:i+2])]++
+		}
+		return counts
+	}
+	aBigrams, bBigrams := bigrams(a), bigrams(b)
+	var aTotal, bTotal, common int
+	for bigram, count := range aBigrams {
+		aTotal += count
+		if other := bBigrams[bigram]; other > 0 {
+			common += int(math.Min(float64(count), float64(other)))
+		}
+	}
+	for _, count := range bBigrams {
+		bTotal += count
+	}
+	if aTotal+bTotal == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(aTotal+bTotal)
+}
+
+// geocodeLevelConfidence 按地理编码的匹配级别估计置信度。
+// 高德返回中文级别，OpenStreetMap 返回地点类型，未知类型按具体地点处理
+func geocodeLevelConfidence(level string) float64 {
+	switch level {
+	case "兴趣点", "门牌号", "单元号", "楼栋", "公交站台、地铁站", "公交站台", "地铁站":
+		return 0.75
+	case "道路", "道路交叉路口", "村庄", "热点商圈", "乡镇", "road", "residential", "neighbourhood", "suburb", "quarter", "village":
+		return 0.45
+	case "区县", "开发区", "county", "city_district", "district":
+		return 0.25
+	case "市", "省", "国家", "city", "town", "state", "country", "administrative", "region", "province", "municipality":
+		return 0.1
+	}
+	return 0.6
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stubMapProvider 按关键字返回固定结果的地图服务
+type stubMapProvider struct {
+	pois     map[string][]POI
+	geocodes map[string]Geocode
+	err      error
+	queries  []string
+
+	// minutes 两点之间的出行时间，为 nil 时不支持距离计算
+	minutes func(from, to string) float64
+	// nearby 周边搜索返回其中在半径内的地点
+	nearby []POI
+	// onSearch 搜索POI时调用，用于模拟查询期间的其他修改
+	onSearch func(keyword string)
+}
+
+func (p *stubMapProvider) Name() string    { return "stub" }
+func (p *stubMapProvider) Available() bool { return true }
+func (p *stubMapProvider) Datum() string   { return coord.GCJ02 }
+
+func (p *stubMapProvider) Geocode(address string) (*GeocodeResponse, error) {
+	p.queries = append(p.queries, "geocode:"+address)
+	if p.err != nil {
+		return nil, p.err
+	}
+	response := &GeocodeResponse{Status: "1"}
+	if geocode, ok := p.geocodes[address]; ok {
+		response.Geocodes = []Geocode{geocode}
+	}
+	return response, nil
+}
+
+func (p *stubMapProvider) SearchPOI(keyword string, city string, types string) (*POIResponse, error) {
+	p.queries = append(p.queries, "poi:"+keyword)
+	if p.onSearch != nil {
+		p.onSearch(keyword)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &POIResponse{Status: "1", Pois: p.pois[keyword]}, nil
+}
+
+func (p *stubMapProvider) SearchNearby(search NearbySearch) (*POIResponse, error) {
+	p.queries = append(p.queries, "nearby:"+search.Location)
+	if p.err != nil {
+		return nil, p.err
+	}
+	lng, lat, _ := ParseLngLat(search.Location)
+	response := &POIResponse{Status: "1", Pois: []POI{}}
+	for _, poi := range p.nearby {
+		poiLng, poiLat, _ := ParseLngLat(poi.Location)
+		if HaversineMeters(lng, lat, poiLng, poiLat) <= float64(search.Radius) {
+			response.Pois = append(response.Pois, poi)
+		}
+	}
+	return response, nil
+}
+
+func (p *stubMapProvider) Regeocode(longitude, latitude string) (*RegeocodeResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+// 路线时间：驾车为 minutes，步行为其 4 倍，骑行和公交为其 2 倍
+func (p *stubMapProvider) DrivingRoute(origin, destination string) (*RouteResponse, error) {
+	return p.route("driving", origin, destination, 1)
+}
+func (p *stubMapProvider) WalkingRoute(origin, destination string) (*RouteResponse, error) {
+	return p.route("walking", origin, destination, 4)
+}
+func (p *stubMapProvider) CyclingRoute(origin, destination string) (*RouteResponse, error) {
+	return p.route("cycling", origin, destination, 2)
+}
+func (p *stubMapProvider) TransitRoute(origin, destination string, city string) (*RouteResponse, error) {
+	response, err := p.route("transit", origin, destination, 2)
+	if err == nil {
+		response.Route.Transits = []Transit{{Distance: AmapString(response.Route.Paths[0].Distance), Duration: AmapString(response.Route.Paths[0].Duration)}}
+		response.Route.Paths = nil
+	}
+	return response, err
+}
+
+func (p *stubMapProvider) route(mode, origin, destination string, factor float64) (*RouteResponse, error) {
+	p.queries = append(p.queries, "route:"+mode)
+	if p.minutes == nil {
+		return nil, errors.New("not implemented")
+	}
+	seconds := p.minutes(origin, destination) * factor * 60
+	response := &RouteResponse{Status: "1"}
+	duration := strconv.FormatFloat(seconds, 'f', 0, 64)
+	response.Route.Paths = []Path{{Distance: "1000", Duration: duration, Steps: []Step{
+		{Instruction: "直行", Distance: "1000", Duration: duration, Polyline: origin + ";" + destination},
+	}}}
+	return response, nil
+}
+func (p *stubMapProvider) CalculateDistance(origins, destinations string, mode string) (*DistanceResponse, error) {
+	if p.minutes == nil {
+		return nil, errors.New("not implemented")
+	}
+	response := &DistanceResponse{Status: "1"}
+	for i, origin := range strings.Split(origins, "|") {
+		response.Results = append(response.Results, DistanceResult{
+			OriginID: strconv.Itoa(i + 1),
+			DestID:   "1",
+			Duration: strconv.FormatFloat(p.minutes(origin, destinations)*60, 'f', 0, 64),
+		})
+	}
+	return response, nil
+}
+
+func TestTravelService_GeocodePlanActivities(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	newTestTree(t, travelService, geocodeTestPlan)
+	provider := &stubMapProvider{
+		pois: map[string][]POI{
+			"西湖": {{Name: "西湖风景名胜区", Location: "120.14,30.24", Address: "龙井路1号"}},
+		},
+		geocodes: map[string]Geocode{
+			"杭州河坊街": {Location: "120.17,30.24", Level: "道路", FormattedAddress: "浙江省杭州市上城区河坊街"},
+		},
+	}
+
+	summary, err := travelService.GeocodePlanActivities("plan-1", "user-1", provider, false)
+	if err != nil {
+		t.Fatalf("GeocodePlanActivities failed: %v", err)
+	}
+	if summary.Total != 4 || summary.Resolved != 1 || summary.Approximate != 1 || summary.Unresolved != 1 || summary.Skipped != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.UnresolvedActivities) != 1 || summary.UnresolvedActivities[0] != "act-3" {
+		t.Errorf("Expected act-3 to be unresolved, got %v", summary.UnresolvedActivities)
+	}
+
+	lake, _ := travelService.GetActivity("act-1")
+	if lake.GeocodeStatus != models.GeocodeStatusResolved || lake.Longitude != 120.14 || lake.GeocodeConfidence != 0.85 || lake.Address != "龙井路1号" || lake.CoordinateSystem != coord.GCJ02 {
+		t.Errorf("Unexpected resolved activity: %+v", lake)
+	}
+	street, _ := travelService.GetActivity("act-2")
+	if street.GeocodeStatus != models.GeocodeStatusApproximate || street.Latitude != 30.24 {
+		t.Errorf("Unexpected approximate activity: %+v", street)
+	}
+	unknown, _ := travelService.GetActivity("act-3")
+	if unknown.GeocodeStatus != models.GeocodeStatusUnresolved || unknown.Longitude != 0 || unknown.Latitude != 0 || unknown.CoordinateSystem != "" {
+		t.Errorf("Unexpected unresolved activity: %+v", unknown)
+	}
+	lunch, _ := travelService.GetActivity("act-4")
+	if lunch.GeocodeStatus != models.GeocodeStatusResolved || lunch.Longitude != 120.15 {
+		t.Errorf("Existing coordinates should be kept, got %+v", lunch)
+	}
+	for _, query := range provider.queries {
+		if query == "poi:楼外楼" {
+			t.Errorf("Activities with coordinates should not be looked up")
+		}
+	}
+
+	plan, _ := travelService.GetTravelPlan("plan-1", "user-1")
+	if plan.GeocodeStatus != models.PlanGeocodeCompleted {
+		t.Errorf("Expected plan geocode status completed, got %q", plan.GeocodeStatus)
+	}
+
+	// force 时重新解析已有坐标的活动
+	summary, _ = travelService.GeocodePlanActivities("plan-1", "user-1", provider, true)
+	if summary.Skipped != 0 || summary.Unresolved != 2 {
+		t.Errorf("Unexpected forced summary: %+v", summary)
+	}
+}
+
+func TestTravelService_GeocodePlanActivitiesConcurrentEdits(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	newTestTree(t, travelService, geocodeTestPlan)
+	provider := &stubMapProvider{
+		pois: map[string][]POI{
+			"西湖": {{Name: "西湖风景名胜区", Location: "120.14,30.24", Address: "龙井路1号"}},
+		},
+	}
+	// 解析期间用户修改了 act-1 的标题，删除了 act-3
+	provider.onSearch = func(keyword string) {
+		if keyword != "西湖" {
+			return
+		}
+		edited, _ := travelService.GetActivity("act-1")
+		copied := *edited
+		copied.Title = "夜游西湖"
+		travelService.UpdateActivity(&copied)
+		travelService.DeleteActivity("act-3")
+	}
+
+	summary, err := travelService.GeocodePlanActivities("plan-1", "user-1", provider, false)
+	if err != nil {
+		t.Fatalf("GeocodePlanActivities failed: %v", err)
+	}
+	if summary.Total != 3 || summary.Unresolved != 1 || len(summary.UnresolvedActivities) != 1 || summary.UnresolvedActivities[0] != "act-2" {
+		t.Errorf("Deleted activities should be skipped, got %+v", summary)
+	}
+	lake, _ := travelService.GetActivity("act-1")
+	if lake.Title != "夜游西湖" || lake.GeocodeStatus != models.GeocodeStatusResolved || lake.Longitude != 120.14 {
+		t.Errorf("Expected the edit to be kept along with the coordinates, got %+v", lake)
+	}
+	if deleted, _ := travelService.GetActivity("act-3"); deleted != nil {
+		t.Errorf("Deleted activity should not be recreated, got %+v", deleted)
+	}
+	plan, _ := travelService.GetTravelPlan("plan-1", "user-1")
+	if plan.GeocodeStatus != models.PlanGeocodeCompleted {
+		t.Errorf("Expected plan geocode status completed, got %q", plan.GeocodeStatus)
+	}
+}
+
+func TestTravelService_GeocodePlanActivitiesFailure(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	newTestTree(t, travelService, geocodeTestPlan)
+
+	summary, err := travelService.GeocodePlanActivities("plan-1", "user-1", &stubMapProvider{err: errors.New("invalid key")}, false)
+	if err == nil || summary == nil || summary.Unresolved != 3 {
+		t.Fatalf("Expected an error when every lookup fails, got %+v, %v", summary, err)
+	}
+	plan, _ := travelService.GetTravelPlan("plan-1", "user-1")
+	if plan.GeocodeStatus != models.PlanGeocodeFailed {
+		t.Errorf("Expected plan geocode status failed, got %q", plan.GeocodeStatus)
+	}
+
+	if summary, err := travelService.GeocodePlanActivities("missing", "user-1", &stubMapProvider{}, false); summary != nil || err != nil {
+		t.Errorf("Expected nil for a missing plan, got %+v, %v", summary, err)
+	}
+
+	// 解析进度只由服务内部更新，普通的计划更新不能修改
+	travelService.UpdateTravelPlan("plan-1", "user-1", map[string]interface{}{"geocode_status": models.PlanGeocodeCompleted, "title": "杭州"})
+	if plan, _ := travelService.GetTravelPlan("plan-1", "user-1"); plan.GeocodeStatus != models.PlanGeocodeFailed || plan.Title != "杭州" {
+		t.Errorf("Expected geocode status to be ignored by plan updates, got %q", plan.GeocodeStatus)
+	}
+}
+
+func TestNameConfidence(t *testing.T) {
+	cases := []struct {
+		query, name string
+		min, max    float64
+	}{
+		{"西湖", "西湖", 1, 1},
+		{"灵隐寺", "灵隐寺(北门)", 0.85, 0.85},
+		{"Tour Eiffel", "tour-eiffel", 1, 1},
+		{"雷峰塔景区", "雷峰夕照", 0.2, 0.5},
+		{"西湖", "故宫博物院", 0, 0},
+	}
+	for _, c := range cases {
+		if got := nameConfidence(c.query, c.name); got < c.min || got > c.max {
+			t.Errorf("nameConfidence(%q, %q) = %.2f, expected between %.2f and %.2f", c.query, c.name, got, c.min, c.max)
+		}
+	}
+}
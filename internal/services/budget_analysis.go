@@ -0,0 +1,204 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ComputeBudgetStatus 根据计划的预算分配、已记录费用和已过天数计算各分类的预算执行情况
+func ComputeBudgetStatus(plan *models.TravelPlan, expenses []*models.Expense, now time.Time) *models.BudgetAnalysis {
+	totalDays := int(plan.EndDate.Sub(plan.StartDate).Hours()/24) + 1
+	if totalDays < 1 {
+		totalDays = 1
+	}
+	daysElapsed := int(math.Floor(now.Sub(plan.StartDate).Hours()/24)) + 1
+	if daysElapsed < 0 {
+		daysElapsed = 0
+	}
+	if daysElapsed > totalDays {
+		daysElapsed = totalDays
+	}
+	progress := float64(daysElapsed) / float64(totalDays)
+
+	spent := make(map[string]float64)
+	total := 0.0
+	for _, expense := range expenses {
+		category := strings.ToLower(strings.TrimSpace(expense.Category))
+		spent[category] += expense.Amount
+		total += expense.Amount
+	}
+
+	planned := make(map[string]float64)
+	categories := make(map[string]bool)
+	for category, amount := range plan.BudgetBreakdown {
+		category = strings.ToLower(strings.TrimSpace(category))
+		planned[category] += amount
+		categories[category] = true
+	}
+	for category := range spent {
+		categories[category] = true
+	}
+	var names []string
+	for category := range categories {
+		names = append(names, category)
+	}
+	sort.Strings(names)
+
+	analysis := &models.BudgetAnalysis{
+		PlanID:      plan.ID,
+		Currency:    planCurrency(plan),
+		TotalBudget: plan.Budget,
+		TotalSpent:  roundMoney(total),
+		DaysElapsed: daysElapsed,
+		TotalDays:   totalDays,
+	}
+	for _, category := range names {
+		// 行程开始前已产生的费用（如预订住宿）按整段预算比较
+		expected := planned[category] * progress
+		if daysElapsed == 0 {
+			expected = planned[category]
+		}
+		status := models.CategoryBudgetStatus{
+			Category:       category,
+			Planned:        roundMoney(planned[category]),
+			Spent:          roundMoney(spent[category]),
+			ExpectedToDate: roundMoney(expected),
+		}
+		if status.Spent > status.ExpectedToDate {
+			status.Overspent = true
+			status.OverspendBy = roundMoney(status.Spent - status.ExpectedToDate)
+		}
+		analysis.Categories = append(analysis.Categories, status)
+	}
+	return analysis
+}
+
+// AnalyzeBudgetWithKey 结合预算执行情况让模型给出超支提醒和省钱建议，返回经过校验的结构化结果
+func (s *LLMService) AnalyzeBudgetWithKey(meta LLMCallMeta, plan *models.TravelPlan, expenses []*models.Expense, now time.Time, apiKey, baseURL, model string) (*models.BudgetAnalysis, error) {
+	analysis := ComputeBudgetStatus(plan, expenses, now)
+
+	meta.Endpoint = UsageEndpointBudgetAnalysis
+	formatted, err := s.userInput(meta, "expenses", s.formatExpenses(expenses))
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.userInput(meta, "destination", plan.Destination)
+	if err != nil {
+		return nil, err
+	}
+	status, _ := json.MarshalIndent(analysis.Categories, "", "  ")
+	prompt, err := s.renderPrompt(meta, "budget_analysis", map[string]interface{}{
+		"Destination": destination,
+		"Budget":      plan.Budget,
+		"Currency":    analysis.Currency,
+		"People":      plan.People,
+		"DaysElapsed": analysis.DaysElapsed,
+		"TotalDays":   analysis.TotalDays,
+		"TotalSpent":  analysis.TotalSpent,
+		"Categories":  string(status),
+		"Expenses":    formatted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	response = extractJSON(response)
+	var result struct {
+		Summary     string                    `json:"summary"`
+		Warnings    []models.BudgetWarning    `json:"warnings"`
+		Suggestions []models.SavingSuggestion `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+
+	analysis.Summary = strings.TrimSpace(result.Summary)
+	analysis.Warnings = validBudgetWarnings(analysis, result.Warnings, plan.Locale)
+	analysis.Suggestions = validSavingSuggestions(analysis, result.Suggestions)
+	return analysis, nil
+}
+
+// validBudgetWarnings 过滤模型返回的提醒，并保证每个超支分类至少有一条提醒（按计划语言生成）
+func validBudgetWarnings(analysis *models.BudgetAnalysis, warnings []models.BudgetWarning, locale string) []models.BudgetWarning {
+	known := knownBudgetCategories(analysis)
+	valid := []models.BudgetWarning{}
+	covered := make(map[string]bool)
+	for _, warning := range warnings {
+		warning.Category = strings.ToLower(strings.TrimSpace(warning.Category))
+		warning.Message = strings.TrimSpace(warning.Message)
+		if warning.Message == "" || !known[warning.Category] {
+			continue
+		}
+		switch warning.Severity {
+		case models.BudgetSeverityInfo, models.BudgetSeverityWarning, models.BudgetSeverityCritical:
+		default:
+			warning.Severity = models.BudgetSeverityWarning
+		}
+		covered[warning.Category] = true
+		valid = append(valid, warning)
+	}
+
+	for _, status := range analysis.Categories {
+		if status.Overspent && !covered[status.Category] {
+			valid = append(valid, models.BudgetWarning{
+				Category: status.Category,
+				Severity: models.BudgetSeverityWarning,
+				Message: fmt.Sprintf(localizedText(locale,
+					"%s已支出 %.2f %s，超过截至目前应支出的 %.2f %s",
+					"%s spending %.2f %s exceeds the expected %.2f %s to date"),
+					budgetCategoryLabel(status.Category, locale), status.Spent, analysis.Currency, status.ExpectedToDate, analysis.Currency),
+			})
+		}
+	}
+	return valid
+}
+
+// budgetCategoryLabel 费用分类在提醒中的名称
+func budgetCategoryLabel(category, locale string) string {
+	labels := map[string]string{"food": "餐饮", "accommodation": "住宿", "attractions": "景点", "transport": "交通", "shopping": "购物", "other": "其他"}
+	if label, ok := labels[category]; ok {
+		return localizedText(locale, label, category)
+	}
+	return category
+}
+
+// validSavingSuggestions 过滤分类未知或内容为空的建议
+func validSavingSuggestions(analysis *models.BudgetAnalysis, suggestions []models.SavingSuggestion) []models.SavingSuggestion {
+	known := knownBudgetCategories(analysis)
+	valid := []models.SavingSuggestion{}
+	for _, suggestion := range suggestions {
+		suggestion.Category = strings.ToLower(strings.TrimSpace(suggestion.Category))
+		suggestion.Suggestion = strings.TrimSpace(suggestion.Suggestion)
+		if suggestion.Suggestion == "" || !known[suggestion.Category] {
+			continue
+		}
+		if suggestion.EstimatedSaving < 0 {
+			suggestion.EstimatedSaving = 0
+		}
+		valid = append(valid, suggestion)
+	}
+	return valid
+}
+
+func knownBudgetCategories(analysis *models.BudgetAnalysis) map[string]bool {
+	known := map[string]bool{models.BudgetOverallCategory: true}
+	for _, status := range analysis.Categories {
+		known[status.Category] = true
+	}
+	return known
+}
+
+// roundMoney 金额保留两位小数
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
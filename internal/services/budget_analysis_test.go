@@ -0,0 +1,103 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/prompts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBudgetTestPlan() (*models.TravelPlan, []*models.Expense) {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	plan := &models.TravelPlan{
+		ID: "plan-1", UserID: "user-1", Destination: "杭州", Budget: 4000, People: 2, Currency: "CNY",
+		StartDate: start, EndDate: start.AddDate(0, 0, 3),
+		BudgetBreakdown: map[string]float64{"Food": 800, "accommodation": 2000},
+	}
+	expenses := []*models.Expense{
+		{PlanID: "plan-1", Category: "food", Amount: 500, Currency: "CNY", Date: start},
+		{PlanID: "plan-1", Category: "accommodation", Amount: 900, Currency: "CNY", Date: start},
+		{PlanID: "plan-1", Category: "shopping", Amount: 120.5, Currency: "CNY", Date: start.AddDate(0, 0, 1)},
+	}
+	return plan, expenses
+}
+
+func TestComputeBudgetStatus(t *testing.T) {
+	plan, expenses := newBudgetTestPlan()
+	analysis := ComputeBudgetStatus(plan, expenses, plan.StartDate.Add(36*time.Hour)) // 第2天
+
+	if analysis.TotalDays != 4 || analysis.DaysElapsed != 2 || analysis.TotalSpent != 1520.5 {
+		t.Fatalf("Unexpected totals: %+v", analysis)
+	}
+	byCategory := make(map[string]models.CategoryBudgetStatus)
+	for _, status := range analysis.Categories {
+		byCategory[status.Category] = status
+	}
+	if food := byCategory["food"]; food.ExpectedToDate != 400 || !food.Overspent || food.OverspendBy != 100 {
+		t.Errorf("Unexpected food status: %+v", food)
+	}
+	if hotel := byCategory["accommodation"]; hotel.Overspent {
+		t.Errorf("Accommodation should be within budget: %+v", hotel)
+	}
+	if shopping := byCategory["shopping"]; shopping.Planned != 0 || !shopping.Overspent {
+		t.Errorf("Unplanned category should be overspent: %+v", shopping)
+	}
+
+	before := ComputeBudgetStatus(plan, expenses, plan.StartDate.AddDate(0, 0, -5))
+	if before.DaysElapsed != 0 {
+		t.Errorf("Expected 0 days elapsed before the trip, got %d", before.DaysElapsed)
+	}
+}
+
+func TestLLMService_AnalyzeBudgetWithKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := `{"summary":"餐饮偏高","warnings":[` +
+			`{"category":"food","severity":"urgent","message":"餐饮超支"},` +
+			`{"category":"nightlife","severity":"info","message":"不存在的分类"}],` +
+			`"suggestions":[{"category":"food","suggestion":"午餐选择河坊街小吃","estimated_saving":-10},` +
+			`{"category":"overall","suggestion":"","estimated_saving":50}]}`
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	store, err := prompts.NewStore(config.PromptsConfig{Dir: "../../prompts"})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	llmService := NewLLMService(&config.Config{}, store, nil, nil, nil, nil)
+
+	plan, expenses := newBudgetTestPlan()
+	analysis, err := llmService.AnalyzeBudgetWithKey(LLMCallMeta{UserID: "user-1"}, plan, expenses, plan.StartDate.Add(36*time.Hour), "sk-test", server.URL, "")
+	if err != nil {
+		t.Fatalf("AnalyzeBudgetWithKey failed: %v", err)
+	}
+
+	// food 保留并修正级别；未知分类被丢弃；shopping 超支但模型未提醒，由服务端补充
+	if len(analysis.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %+v", analysis.Warnings)
+	}
+	if analysis.Warnings[0].Category != "food" || analysis.Warnings[0].Severity != models.BudgetSeverityWarning {
+		t.Errorf("Unexpected food warning: %+v", analysis.Warnings[0])
+	}
+	if analysis.Warnings[1].Category != "shopping" || analysis.Warnings[1].Message != "购物已支出 120.50 CNY，超过截至目前应支出的 0.00 CNY" {
+		t.Errorf("Expected generated shopping warning in Chinese, got %+v", analysis.Warnings[1])
+	}
+	if len(analysis.Suggestions) != 1 || analysis.Suggestions[0].EstimatedSaving != 0 {
+		t.Errorf("Unexpected suggestions: %+v", analysis.Suggestions)
+	}
+
+	plan.Locale = "en-US"
+	analysis, err = llmService.AnalyzeBudgetWithKey(LLMCallMeta{UserID: "user-1"}, plan, expenses, plan.StartDate.Add(36*time.Hour), "sk-test", server.URL, "")
+	if err != nil {
+		t.Fatalf("AnalyzeBudgetWithKey failed: %v", err)
+	}
+	if len(analysis.Warnings) != 2 || analysis.Warnings[1].Message != "shopping spending 120.50 CNY exceeds the expected 0.00 CNY to date" {
+		t.Errorf("Expected generated shopping warning in English, got %+v", analysis.Warnings)
+	}
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultActivityDuration = 90 * time.Minute // 没有结束时间的活动按 90 分钟安排
+	optimizeLatePenalty     = 10               // 每迟到 1 分钟折算的路程分钟数
+	optimizeSearchBudget    = 200000           // 搜索的最大节点数，超出后使用已找到的最优顺序
+)
+
+// DayOptimizeOptions 单日路线优化选项
+type DayOptimizeOptions struct {
+	Mode        string              `json:"mode"`         // driving（默认）或 walking
+	Fixed       []string            `json:"fixed"`        // 额外固定位置和时间的活动ID
+	TimeWindows map[string]TimeSlot `json:"time_windows"` // 活动ID -> 可开始游览的时间段（如开放时间）
+}
+
+// Validate 检查各活动的时间段
+func (o DayOptimizeOptions) Validate() error {
+	for id, window := range o.TimeWindows {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("time window of activity %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// DayOptimization 单日路线优化结果
+type DayOptimization struct {
+	DayNumber  int                `json:"day_number"`
+	Mode       string             `json:"mode"`
+	Activities []*models.Activity `json:"activities"` // 按新顺序排列、已更新时间的活动
+
+	OriginalTravelMinutes  int `json:"original_travel_minutes"`
+	OptimizedTravelMinutes int `json:"optimized_travel_minutes"`
+	MinutesSaved           int `json:"minutes_saved"`
+	LateMinutes            int `json:"late_minutes"` // 新顺序中晚于固定时间或时间段的分钟数
+
+	Changed   bool     `json:"changed"`
+	Fixed     []string `json:"fixed"`     // 保持原位置的活动：用餐、住宿、交通、指定固定及没有坐标的活动
+	Unlocated []string `json:"unlocated"` // 没有坐标、不参与排序的活动
+}
+
+// routeStop 参与排序的一个活动
+type routeStop struct {
+	activity *models.Activity
+	point    int // 在时间矩阵中的下标，没有坐标时为 -1
+	fixed    bool
+	duration time.Duration
+	earliest time.Time // 可开始的时间段，零值表示不限制
+	latest   time.Time
+}
+
+// routeState 按顺序安排活动时的状态
+type routeState struct {
+	now     time.Time
+	point   int
+	travel  float64 // 路程分钟数
+	late    float64 // 迟到分钟数
+	started bool
+}
+
+func (s routeState) cost() float64 {
+	return s.travel + optimizeLatePenalty*s.late
+}
+
+// OptimizeDay 按活动间的出行时间重新安排一天中可移动活动的顺序（带时间窗的旅行商问题），
+// 用餐、住宿、交通活动保持原来的时间。只返回优化结果，不保存
+func (s *TravelService) OptimizeDay(day *PlanTreeDay, provider MapProvider, options DayOptimizeOptions) (*DayOptimization, error) {
+	mode := options.Mode
+	if mode == "" {
+		mode = RouteModeDriving
+	}
+	distanceType := "1"
+	switch mode {
+	case RouteModeDriving:
+	case RouteModeWalking:
+		distanceType = "3"
+	default:
+		return nil, fmt.Errorf("unsupported route mode %q", mode)
+	}
+
+	result := &DayOptimization{DayNumber: day.Day.DayNumber, Mode: mode, Fixed: []string{}, Unlocated: []string{}}
+	fixed := make(map[string]bool)
+	for _, id := range options.Fixed {
+		fixed[id] = true
+	}
+
+	var stops []*routeStop
+	var points []string
+	for _, activity := range day.Activities {
+		stop := &routeStop{activity: activity, point: -1, duration: defaultActivityDuration}
+		if activity.EndTime.After(activity.StartTime) {
+			stop.duration = activity.EndTime.Sub(activity.StartTime)
+		}
+		if hasCoordinates(activity) {
+			stop.point = len(points)
+			points = append(points, ActivityLocation(activity, provider.Datum()))
+		} else {
+			result.Unlocated = append(result.Unlocated, activity.ID)
+		}
+		stop.fixed = isFixedStop(activity, fixed)
+		if window, ok := options.TimeWindows[activity.ID]; ok {
+			stop.earliest, stop.latest = window.bounds(day.Day.Date)
+		}
+		if stop.fixed {
+			result.Fixed = append(result.Fixed, activity.ID)
+		}
+		stops = append(stops, stop)
+	}
+
+	var movable int
+	for _, stop := range stops {
+		if !stop.fixed {
+			movable++
+		}
+	}
+	if movable < 2 {
+		// 没有可以调整顺序的活动
+		result.Activities = day.Activities
+		return result, nil
+	}
+
+	matrix, err := travelTimeMatrix(provider, points, distanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	originalState := measureRoute(stops, matrix)
+	result.OriginalTravelMinutes = int(math.Round(originalState.travel))
+	result.OptimizedTravelMinutes = result.OriginalTravelMinutes
+	result.LateMinutes = int(math.Round(originalState.late))
+	result.Activities = day.Activities
+
+	// 按原顺序重新排时间作为比较基准，新顺序至少节省半分钟才采用
+	dayStart := stops[0].activity.StartTime
+	_, baseline := scheduleRoute(stops, matrix, dayStart)
+	best, bestState := searchRoute(stops, matrix, dayStart)
+	if best == nil || bestState.cost() >= baseline.cost()-0.5 {
+		return result, nil
+	}
+
+	result.OptimizedTravelMinutes = int(math.Round(bestState.travel))
+	result.MinutesSaved = result.OriginalTravelMinutes - result.OptimizedTravelMinutes
+	result.LateMinutes = int(math.Round(bestState.late))
+	result.Activities = best
+	result.Changed = true
+	return result, nil
+}
+
+// isFixedStop 活动是否保持原位置和时间：用餐、住宿、交通、指定固定及没有坐标的活动
+func isFixedStop(activity *models.Activity, fixed map[string]bool) bool {
+	switch activity.Type {
+	case "restaurant", "hotel", "transport":
+		return true
+	}
+	return fixed[activity.ID] || !hasCoordinates(activity)
+}
+
+// OptimizedActivity 确认保存的活动时间，取自优化预览返回的活动
+type OptimizedActivity struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// ValidateDayOptimization 检查确认的活动顺序和时间：必须与当天的活动一一对应、按开始时间排列且都在当天，
+// 固定的活动不能改变时间
+func ValidateDayOptimization(day *PlanTreeDay, options DayOptimizeOptions, activities []OptimizedActivity) error {
+	current := make(map[string]*models.Activity)
+	for _, activity := range day.Activities {
+		current[activity.ID] = activity
+	}
+	if len(activities) != len(current) {
+		return fmt.Errorf("expected %d activities for day %d, got %d", len(current), day.Day.DayNumber, len(activities))
+	}
+	fixed := make(map[string]bool)
+	for _, id := range options.Fixed {
+		fixed[id] = true
+	}
+
+	seen := make(map[string]bool)
+	date := day.Day.Date.Format("2006-01-02")
+	for i, activity := range activities {
+		original, ok := current[activity.ID]
+		if !ok || seen[activity.ID] {
+			return fmt.Errorf("activity %s is not part of day %d or is listed twice", activity.ID, day.Day.DayNumber)
+		}
+		seen[activity.ID] = true
+
+		if activity.StartTime.IsZero() || activity.StartTime.In(day.Day.Date.Location()).Format("2006-01-02") != date {
+			return fmt.Errorf("activity %s must start on %s", activity.ID, date)
+		}
+		if !activity.EndTime.IsZero() && activity.EndTime.Before(activity.StartTime) {
+			return fmt.Errorf("activity %s ends before it starts", activity.ID)
+		}
+		if i > 0 && activity.StartTime.Before(activities[i-1].StartTime) {
+			return fmt.Errorf("activities must be listed in order of start time")
+		}
+		if isFixedStop(original, fixed) && !(original.StartTime.Equal(activity.StartTime) && original.EndTime.Equal(activity.EndTime)) {
+			return fmt.Errorf("activity %s is fixed and cannot be moved", activity.ID)
+		}
+	}
+	return nil
+}
+
+// ApplyDayOptimization 按确认的顺序和时间保存活动（需先通过 ValidateDayOptimization 检查），只更新活动的时间，
+// 返回时间有变化的活动
+func (s *TravelService) ApplyDayOptimization(plan *models.TravelPlan, day *PlanTreeDay, activities []OptimizedActivity) ([]*models.Activity, error) {
+	current := make(map[string]*models.Activity)
+	for _, activity := range day.Activities {
+		current[activity.ID] = activity
+	}
+
+	updated := []*models.Activity{}
+	for _, activity := range activities {
+		original, ok := current[activity.ID]
+		if !ok {
+			return nil, fmt.Errorf("activity %s is not part of day %d", activity.ID, day.Day.DayNumber)
+		}
+		if original.StartTime.Equal(activity.StartTime) && original.EndTime.Equal(activity.EndTime) {
+			continue
+		}
+		if !s.db.ModifyActivity(activity.ID, func(stored *models.Activity) {
+			stored.StartTime, stored.EndTime = activity.StartTime, activity.EndTime
+		}) {
+			continue
+		}
+		saved, err := s.GetActivity(activity.ID)
+		if err != nil {
+			return nil, err
+		}
+		updated = append(updated, saved)
+	}
+	if len(updated) > 0 {
+		s.touchPlan(plan)
+	}
+	return updated, nil
+}
+
+// travelTimeMatrix 计算各地点之间的出行时间（分钟），matrix[i][j] 为 i 到 j 的时间。
+// 距离接口每次只接受一个终点，因此按终点逐个查询
+func travelTimeMatrix(provider MapProvider, points []string, distanceType string) ([][]float64, error) {
+	matrix := make([][]float64, len(points))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(points))
+	}
+	origins := strings.Join(points, "|")
+	for j, destination := range points {
+		response, err := provider.CalculateDistance(origins, destination, distanceType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build travel time matrix: %v", err)
+		}
+		for _, result := range response.Results {
+			i, err := strconv.Atoi(result.OriginID)
+			if err != nil || i < 1 || i > len(points) || i-1 == j {
+				continue
+			}
+			seconds, _ := strconv.ParseFloat(result.Duration, 64)
+			matrix[i-1][j] = seconds / 60
+		}
+	}
+	return matrix, nil
+}
+
+// visit 安排下一个活动：固定活动保持原时间，可移动活动在到达后（按 5 分钟取整）开始
+func (s routeState) visit(stop *routeStop, matrix [][]float64) (routeState, *models.Activity) {
+	next := s
+	arrival := s.now
+	if s.started && s.point >= 0 && stop.point >= 0 {
+		minutes := matrix[s.point][stop.point]
+		next.travel += minutes
+		arrival = arrival.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+
+	scheduled := *stop.activity
+	if stop.fixed {
+		if arrival.After(stop.activity.StartTime) {
+			next.late += arrival.Sub(stop.activity.StartTime).Minutes()
+			next.now = arrival.Add(stop.duration)
+		} else {
+			next.now = stop.activity.StartTime.Add(stop.duration)
+		}
+	} else {
+		start := roundUpToFiveMinutes(arrival)
+		if !stop.earliest.IsZero() && start.Before(stop.earliest) {
+			start = stop.earliest
+		}
+		if !stop.latest.IsZero() && start.After(stop.latest) {
+			next.late += start.Sub(stop.latest).Minutes()
+		}
+		scheduled.StartTime = start
+		if !stop.activity.EndTime.IsZero() {
+			scheduled.EndTime = start.Add(stop.duration)
+		}
+		next.now = start.Add(stop.duration)
+	}
+	if stop.point >= 0 {
+		next.point = stop.point
+	}
+	next.started = true
+	return next, &scheduled
+}
+
+// scheduleRoute 按给定顺序安排活动
+func scheduleRoute(stops []*routeStop, matrix [][]float64, dayStart time.Time) ([]*models.Activity, routeState) {
+	state := routeState{now: dayStart, point: -1}
+	var activities []*models.Activity
+	for _, stop := range stops {
+		var activity *models.Activity
+		state, activity = state.visit(stop, matrix)
+		activities = append(activities, activity)
+	}
+	return activities, state
+}
+
+// measureRoute 计算当前时间安排的出行时间和迟到时间（不调整时间）
+func measureRoute(stops []*routeStop, matrix [][]float64) routeState {
+	state := routeState{point: -1}
+	for _, stop := range stops {
+		minutes := travelMinutes(state, stop, matrix)
+		state.travel += minutes
+		if arrival := state.now.Add(time.Duration(minutes * float64(time.Minute))); state.started && arrival.After(stop.activity.StartTime) {
+			state.late += arrival.Sub(stop.activity.StartTime).Minutes()
+		}
+		state.now = stop.activity.StartTime.Add(stop.duration)
+		if stop.point >= 0 {
+			state.point = stop.point
+		}
+		state.started = true
+	}
+	return state
+}
+
+// searchRoute 分支定界搜索总出行时间（含迟到惩罚）最短的顺序：固定活动保持相对顺序，
+// 可移动活动可以安排在任意位置。优先尝试最近的活动，超出搜索预算时返回已找到的最优顺序
+func searchRoute(stops []*routeStop, matrix [][]float64, dayStart time.Time) ([]*models.Activity, routeState) {
+	var anchors, movable []*routeStop
+	for _, stop := range stops {
+		if stop.fixed {
+			anchors = append(anchors, stop)
+		} else {
+			movable = append(movable, stop)
+		}
+	}
+
+	var best []*models.Activity
+	var bestState routeState
+	bestCost := math.Inf(1)
+	used := make([]bool, len(movable))
+	path := make([]*models.Activity, 0, len(stops))
+	nodes := 0
+
+	var search func(state routeState, nextAnchor, remaining int)
+	search = func(state routeState, nextAnchor, remaining int) {
+		nodes++
+		if state.cost() >= bestCost || nodes > optimizeSearchBudget {
+			return
+		}
+		if remaining == 0 && nextAnchor == len(anchors) {
+			best, bestState, bestCost = append([]*models.Activity(nil), path...), state, state.cost()
+			return
+		}
+
+		// 候选：下一个固定活动和所有未安排的可移动活动，按出行时间由近到远尝试
+		type candidate struct {
+			stop    *routeStop
+			movable int // 可移动活动的下标，固定活动为 -1
+			minutes float64
+		}
+		var candidates []candidate
+		if nextAnchor < len(anchors) {
+			candidates = append(candidates, candidate{anchors[nextAnchor], -1, travelMinutes(state, anchors[nextAnchor], matrix)})
+		}
+		for i, stop := range movable {
+			if !used[i] {
+				candidates = append(candidates, candidate{stop, i, travelMinutes(state, stop, matrix)})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].minutes < candidates[j].minutes })
+
+		for _, c := range candidates {
+			next, activity := state.visit(c.stop, matrix)
+			path = append(path, activity)
+			if c.movable < 0 {
+				search(next, nextAnchor+1, remaining)
+			} else {
+				used[c.movable] = true
+				search(next, nextAnchor, remaining-1)
+				used[c.movable] = false
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	search(routeState{now: dayStart, point: -1}, 0, len(movable))
+	return best, bestState
+}
+
+func travelMinutes(state routeState, stop *routeStop, matrix [][]float64) float64 {
+	if !state.started || state.point < 0 || stop.point < 0 {
+		return 0
+	}
+	return matrix[state.point][stop.point]
+}
+
+// roundUpToFiveMinutes 向上取整到 5 分钟
+func roundUpToFiveMinutes(t time.Time) time.Time {
+	rounded := t.Truncate(5 * time.Minute)
+	if rounded.Before(t) {
+		rounded = rounded.Add(5 * time.Minute)
+	}
+	return rounded
+}
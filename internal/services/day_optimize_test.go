@@ -0,0 +1,135 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lineProvider 地点都在同一纬度上，经度每相差 0.1 度出行 10 分钟
+func lineProvider() *stubMapProvider {
+	return &stubMapProvider{minutes: func(from, to string) float64 {
+		fromLng, _, _ := ParseLngLat(from)
+		toLng, _, _ := ParseLngLat(to)
+		return math.Round(math.Abs(fromLng-toLng) * 100)
+	}}
+}
+
+func TestTravelService_OptimizeDay(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, optimizeTestPlan)
+	day := tree.FindDay(1)
+
+	optimization, err := travelService.OptimizeDay(day, lineProvider(), DayOptimizeOptions{})
+	if err != nil {
+		t.Fatalf("OptimizeDay failed: %v", err)
+	}
+	if !optimization.Changed || optimization.OriginalTravelMinutes != 50 || optimization.OptimizedTravelMinutes != 30 || optimization.MinutesSaved != 20 {
+		t.Errorf("Unexpected optimization: %+v", optimization)
+	}
+	expected := []struct{ id, time string }{{"act-a", "09:00"}, {"act-b", "10:40"}, {"lunch", "12:30"}, {"act-c", "14:10"}}
+	for i, e := range expected {
+		activity := optimization.Activities[i]
+		if activity.ID != e.id || activity.StartTime.Format("15:04") != e.time {
+			t.Errorf("Position %d: expected %s at %s, got %s at %s", i, e.id, e.time, activity.ID, activity.StartTime.Format("15:04"))
+		}
+	}
+	if len(optimization.Fixed) != 1 || optimization.Fixed[0] != "lunch" {
+		t.Errorf("Expected lunch to be fixed, got %v", optimization.Fixed)
+	}
+
+	// 预览不修改行程
+	if stored, _ := travelService.GetActivity("act-b"); stored.StartTime.Format("15:04") != "14:00" {
+		t.Errorf("Preview should not change activities")
+	}
+	// 按预览的顺序和时间确认保存
+	var confirmed []OptimizedActivity
+	for _, activity := range optimization.Activities {
+		confirmed = append(confirmed, OptimizedActivity{ID: activity.ID, StartTime: activity.StartTime, EndTime: activity.EndTime})
+	}
+	if err := ValidateDayOptimization(day, DayOptimizeOptions{}, confirmed); err != nil {
+		t.Fatalf("ValidateDayOptimization failed: %v", err)
+	}
+	updated, err := travelService.ApplyDayOptimization(tree.Plan, day, confirmed)
+	if err != nil || len(updated) != 2 {
+		t.Fatalf("Expected 2 updated activities, got %d, %v", len(updated), err)
+	}
+	applied, _ := travelService.GetPlanTree("plan-1", "user-1")
+	if order := applied.FindDay(1).Activities; order[1].ID != "act-b" || order[3].ID != "act-c" {
+		t.Errorf("Applied order not saved: %s, %s", order[1].ID, order[3].ID)
+	}
+
+	// 已是最优顺序时不再调整
+	again, _ := travelService.OptimizeDay(applied.FindDay(1), lineProvider(), DayOptimizeOptions{})
+	if again.Changed || again.MinutesSaved != 0 {
+		t.Errorf("Expected no change for an optimized day, got %+v", again)
+	}
+}
+
+func TestValidateDayOptimization(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	day := newTestTree(t, travelService, optimizeTestPlan).FindDay(1)
+	at := func(clock string) time.Time { return ParseTimeOfDay(day.Day.Date, clock) }
+	confirmed := func(times map[string]string, order ...string) []OptimizedActivity {
+		var activities []OptimizedActivity
+		for _, id := range order {
+			activities = append(activities, OptimizedActivity{ID: id, StartTime: at(times[id])})
+		}
+		return activities
+	}
+	times := map[string]string{"act-a": "09:00", "act-b": "10:40", "lunch": "12:30", "act-c": "14:10"}
+	if err := ValidateDayOptimization(day, DayOptimizeOptions{}, confirmed(times, "act-a", "act-b", "lunch", "act-c")); err != nil {
+		t.Fatalf("Expected the previewed order to be valid, got %v", err)
+	}
+
+	cases := map[string][]OptimizedActivity{
+		"missing activity":  confirmed(times, "act-a", "act-b", "lunch"),
+		"duplicate":         confirmed(times, "act-a", "act-b", "act-b", "act-c"),
+		"out of order":      confirmed(times, "act-b", "act-a", "lunch", "act-c"),
+		"moved fixed lunch": confirmed(map[string]string{"act-a": "09:00", "act-b": "10:40", "lunch": "13:00", "act-c": "14:10"}, "act-a", "act-b", "lunch", "act-c"),
+	}
+	otherDay := confirmed(times, "act-a", "act-b", "lunch", "act-c")
+	otherDay[3].StartTime = otherDay[3].StartTime.AddDate(0, 0, 1)
+	cases["other day"] = otherDay
+	for name, activities := range cases {
+		if err := ValidateDayOptimization(day, DayOptimizeOptions{}, activities); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	// 指定固定的活动也不能移动
+	if err := ValidateDayOptimization(day, DayOptimizeOptions{Fixed: []string{"act-c"}}, confirmed(times, "act-a", "act-b", "lunch", "act-c")); err == nil {
+		t.Error("Expected a pinned activity to stay in place")
+	}
+}
+
+func TestTravelService_OptimizeDayConstraints(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	day := newTestTree(t, travelService, optimizeTestPlan).FindDay(1)
+
+	// 固定 act-c 后只剩两个可移动活动，act-b 只能在下午开放
+	optimization, err := travelService.OptimizeDay(day, lineProvider(), DayOptimizeOptions{
+		Fixed:       []string{"act-c"},
+		TimeWindows: map[string]TimeSlot{"act-b": {Start: "14:00"}},
+	})
+	if err != nil {
+		t.Fatalf("OptimizeDay failed: %v", err)
+	}
+	// act-a 移到下午最省时间：act-c(10:40) → 午餐 → act-b(14:10) → act-a
+	var order []string
+	for _, activity := range optimization.Activities {
+		order = append(order, activity.ID+"@"+activity.StartTime.Format("15:04"))
+	}
+	if got := strings.Join(order, ","); got != "act-c@10:40,lunch@12:30,act-b@14:10,act-a@15:50" || optimization.LateMinutes != 0 {
+		t.Errorf("Unexpected constrained order %s (late %d)", got, optimization.LateMinutes)
+	}
+
+	if _, err := travelService.OptimizeDay(day, lineProvider(), DayOptimizeOptions{Mode: "flying"}); err == nil {
+		t.Errorf("Expected an error for an unsupported mode")
+	}
+	if _, err := travelService.OptimizeDay(day, &stubMapProvider{}, DayOptimizeOptions{}); err == nil {
+		t.Errorf("Expected an error when the travel time matrix fails")
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UsageEndpointDayRegeneration 重新生成单日行程
+const UsageEndpointDayRegeneration = "day_regeneration"
+
+// TimeSlot 时间段（HH:MM），为空表示整天，End 可以为 "24:00"（当天结束）
+type TimeSlot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// IsZero 是否未指定时间段
+func (t *TimeSlot) IsZero() bool {
+	return t == nil || (t.Start == "" && t.End == "")
+}
+
+// Validate 检查时间格式，开始和结束都给出时开始必须早于结束
+func (t *TimeSlot) Validate() error {
+	if t.IsZero() {
+		return nil
+	}
+	start, ok := clockMinutes(t.Start, false)
+	if t.Start != "" && !ok {
+		return fmt.Errorf("invalid start time %q, expected HH:MM", t.Start)
+	}
+	end, ok := clockMinutes(t.End, true)
+	if t.End != "" && !ok {
+		return fmt.Errorf("invalid end time %q, expected HH:MM", t.End)
+	}
+	if t.Start != "" && t.End != "" && start >= end {
+		return fmt.Errorf("start time %s must be before end time %s", t.Start, t.End)
+	}
+	return nil
+}
+
+// bounds 时间段在某一天的起止时间，未指定的一端为零值
+func (t *TimeSlot) bounds(date time.Time) (start, end time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if t.Start != "" {
+		start = ParseTimeOfDay(date, t.Start)
+	}
+	if t.End == "24:00" {
+		end = ParseTimeOfDay(date, "00:00").AddDate(0, 0, 1)
+	} else if t.End != "" {
+		end = ParseTimeOfDay(date, t.End)
+	}
+	return
+}
+
+// Contains 判断某个时间是否落在时间段内（左闭右开）
+func (t *TimeSlot) Contains(date, at time.Time) bool {
+	start, end := t.bounds(date)
+	if !start.IsZero() && at.Before(start) {
+		return false
+	}
+	if !end.IsZero() && !at.Before(end) {
+		return false
+	}
+	return true
+}
+
+// clockMinutes 解析 "HH:MM" 为当天的分钟数，allowEndOfDay 时接受 "24:00"
+func clockMinutes(clock string, allowEndOfDay bool) (int, bool) {
+	parsed, err := time.Parse("15:04", clock)
+	if err == nil {
+		return parsed.Hour()*60 + parsed.Minute(), true
+	}
+	if allowEndOfDay && clock == "24:00" {
+		return 24 * 60, true
+	}
+	return 0, false
+}
+
+// RegenerateDayWithKey 结合前后日程重新生成某一天（或某个时间段）的活动
+func (s *LLMService) RegenerateDayWithKey(meta LLMCallMeta, tree *PlanTree, dayNumber int, slot *TimeSlot, constraints []string, apiKey, baseURL, model string) ([]Activity, error) {
+	target := tree.FindDay(dayNumber)
+	if target == nil {
+		return nil, fmt.Errorf("day %d not found", dayNumber)
+	}
+
+	// 前后各一天作为上下文，避免与相邻日程重复
+	var neighbours []*PlanTreeDay
+	for _, day := range tree.Days {
+		if day.Day.DayNumber == dayNumber-1 || day.Day.DayNumber == dayNumber+1 {
+			neighbours = append(neighbours, day)
+		}
+	}
+
+	scope := "整天"
+	slotStart, slotEnd := "", ""
+	if !slot.IsZero() {
+		slotStart, slotEnd = orDefault(slot.Start, "00:00"), orDefault(slot.End, "24:00")
+		scope = fmt.Sprintf("%s 至 %s 之间的时间段（该时间段以外的活动保持不变）", slotStart, slotEnd)
+	}
+	plan := tree.Plan
+	meta.Endpoint = UsageEndpointDayRegeneration
+	var checked []string
+	for _, constraint := range constraints {
+		constraint, err := s.userInput(meta, "constraints", constraint)
+		if err != nil {
+			return nil, err
+		}
+		if constraint != "" {
+			checked = append(checked, constraint)
+		}
+	}
+	// 生成行程时的偏好同样适用于重新生成
+	checked = append(checked, PreferenceConstraints(plan.Preferences, plan.Locale)...)
+	destination, err := s.userInput(meta, "destination", plan.Destination)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.renderPrompt(meta, "day_regenerate", map[string]interface{}{
+		"DayNumber":     dayNumber,
+		"Date":          target.Day.Date.Format("2006-01-02"),
+		"Scope":         scope,
+		"SlotStart":     slotStart,
+		"SlotEnd":       slotEnd,
+		"Destination":   destination,
+		"Budget":        plan.Budget,
+		"People":        plan.People,
+		"Currency":      planCurrency(plan),
+		"NeighbourDays": formatPlanDays(neighbours),
+		"CurrentDay":    formatPlanDays([]*PlanTreeDay{target}),
+		"Constraints":   checked,
+		"Language":      LocaleName(ResolveLocale(plan.Locale)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	response = extractJSON(response)
+	var result struct {
+		Activities []Activity `json:"activities"`
+	}
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+	if len(result.Activities) == 0 {
+		return nil, fmt.Errorf("LLM returned no activities")
+	}
+
+	// 丢弃落在时间段以外的活动，保证只替换指定范围
+	var activities []Activity
+	for _, activity := range result.Activities {
+		if slot.Contains(target.Day.Date, ParseTimeOfDay(target.Day.Date, activity.Time)) {
+			activities = append(activities, activity)
+		}
+	}
+	if len(activities) == 0 {
+		return nil, fmt.Errorf("LLM returned no activities inside the requested time slot")
+	}
+	return activities, nil
+}
+
+// ReplaceDayActivities 用新活动替换某天（或某个时间段）的活动，返回被替换的旧活动和新活动
+func (s *TravelService) ReplaceDayActivities(plan *models.TravelPlan, day *PlanTreeDay, slot *TimeSlot, activities []Activity) ([]*models.Activity, []*models.Activity, error) {
+	var replaced []*models.Activity
+	for _, activity := range day.Activities {
+		if slot.Contains(day.Day.Date, activity.StartTime) {
+			replaced = append(replaced, activity)
+		}
+	}
+
+	var created []*models.Activity
+	for _, activity := range activities {
+		created = append(created, activity.ToRecord(day.Day.ID, day.Day.Date))
+	}
+
+	for _, activity := range replaced {
+		if err := s.DeleteActivity(activity.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, activity := range created {
+		if err := s.CreateActivity(activity); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s.touchPlan(plan)
+	return replaced, created, nil
+}
+
+// orDefault 字符串为空时返回默认值
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
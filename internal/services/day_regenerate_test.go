@@ -0,0 +1,140 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/prompts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeSlot_Contains(t *testing.T) {
+	date := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	var whole *TimeSlot
+	if !whole.IsZero() || !whole.Contains(date, date.Add(23*time.Hour)) {
+		t.Errorf("A nil slot should cover the whole day")
+	}
+	afternoon := &TimeSlot{Start: "13:00", End: "18:00"}
+	for at, expected := range map[string]bool{"12:59": false, "13:00": true, "17:59": true, "18:00": false} {
+		if got := afternoon.Contains(date, ParseTimeOfDay(date, at)); got != expected {
+			t.Errorf("Contains(%s) = %v, expected %v", at, got, expected)
+		}
+	}
+	if evening := (&TimeSlot{Start: "18:00"}); !evening.Contains(date, ParseTimeOfDay(date, "23:30")) || evening.Contains(date, ParseTimeOfDay(date, "09:00")) {
+		t.Errorf("A slot without an end should run to the end of the day")
+	}
+	if late := (&TimeSlot{Start: "20:00", End: "24:00"}); !late.Contains(date, ParseTimeOfDay(date, "23:30")) || late.Contains(date, ParseTimeOfDay(date, "19:00")) {
+		t.Errorf("A slot ending at 24:00 should run to the end of the day")
+	}
+}
+
+func TestTimeSlot_Validate(t *testing.T) {
+	for _, slot := range []*TimeSlot{nil, {Start: "9:00"}, {End: "24:00"}, {Start: "13:00", End: "18:00"}} {
+		if err := slot.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", slot, err)
+		}
+	}
+	for _, slot := range []*TimeSlot{{Start: "afternoon"}, {Start: "25:00"}, {End: "18:60"}, {Start: "24:00"}, {Start: "18:00", End: "13:00"}, {Start: "13:00", End: "13:00"}} {
+		if err := slot.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", slot)
+		}
+	}
+}
+
+func TestLLMService_RegenerateDayWithKey(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		prompt = request.Messages[len(request.Messages)-1].Content
+		content := `{"activities":[` +
+			`{"time":"09:30","title":"早餐","type":"meal"},` +
+			`{"time":"14:00","title":"中国茶叶博物馆","type":"attraction","location":"龙井路88号"},` +
+			`{"time":"19:00","title":"夜游","type":"attraction"}]}`
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	store, err := prompts.NewStore(config.PromptsConfig{Dir: "../../prompts"})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	llmService := NewLLMService(&config.Config{}, store, nil, nil, nil, nil)
+	tree := newTestTree(t, NewTravelService(&config.Config{}), regenerateTestPlan)
+
+	// 只保留时间段内的活动
+	slot := &TimeSlot{Start: "13:00", End: "18:00"}
+	activities, err := llmService.RegenerateDayWithKey(LLMCallMeta{UserID: "user-1"}, tree, 2, slot, []string{"只安排室内活动", " "}, "sk-test", server.URL, "")
+	if err != nil {
+		t.Fatalf("RegenerateDayWithKey failed: %v", err)
+	}
+	if len(activities) != 1 || activities[0].Title != "中国茶叶博物馆" {
+		t.Errorf("Expected only the activity inside the slot, got %+v", activities)
+	}
+	for _, expected := range []string{"只安排室内活动", "13:00", "18:00", "游览西湖", "西溪湿地", "龙井村喝茶"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("Expected the prompt to contain %q", expected)
+		}
+	}
+
+	if activities, err := llmService.RegenerateDayWithKey(LLMCallMeta{UserID: "user-1"}, tree, 2, nil, nil, "sk-test", server.URL, ""); err != nil || len(activities) != 3 {
+		t.Errorf("Expected all activities without a slot, got %d (%v)", len(activities), err)
+	}
+	if !strings.Contains(prompt, "使用 Simplified Chinese 书写") {
+		t.Errorf("Expected the default output language in the prompt: %s", prompt)
+	}
+
+	// 韩语行程没有专门的模板，提示词中要求用韩语输出
+	korean := *tree
+	korean.Plan = &models.TravelPlan{ID: "plan-1", UserID: "user-1", Destination: "杭州", Budget: 3000, People: 2, Locale: "ko-KR", Currency: "KRW"}
+	if _, err := llmService.RegenerateDayWithKey(LLMCallMeta{UserID: "user-1", Locale: "ko-KR"}, &korean, 2, nil, nil, "sk-test", server.URL, ""); err != nil {
+		t.Fatalf("RegenerateDayWithKey failed: %v", err)
+	}
+	if !strings.Contains(prompt, "使用 Korean 书写") || !strings.Contains(prompt, "KRW") {
+		t.Errorf("Expected Korean output in KRW: %s", prompt)
+	}
+	if _, err := llmService.RegenerateDayWithKey(LLMCallMeta{UserID: "user-1"}, tree, 2, &TimeSlot{Start: "21:00"}, nil, "sk-test", server.URL, ""); err == nil {
+		t.Errorf("Expected an error when no activity falls inside the slot")
+	}
+	if _, err := llmService.RegenerateDayWithKey(LLMCallMeta{UserID: "user-1"}, tree, 9, nil, nil, "sk-test", server.URL, ""); err == nil {
+		t.Errorf("Expected an error for a missing day")
+	}
+}
+
+func TestTravelService_ReplaceDayActivities(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, regenerateTestPlan)
+	day := tree.FindDay(2)
+
+	replaced, created, err := travelService.ReplaceDayActivities(tree.Plan, day, &TimeSlot{Start: "12:00"}, []Activity{
+		{Time: "14:30", Title: "中国茶叶博物馆", Type: "attraction", Cost: 0},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDayActivities failed: %v", err)
+	}
+	if len(replaced) != 1 || replaced[0].ID != "day-2-b" || len(created) != 1 || created[0].DayID != "day-2" {
+		t.Fatalf("Unexpected replacement: replaced=%+v created=%+v", replaced, created)
+	}
+
+	activities, _ := travelService.GetActivities("day-2")
+	titles := map[string]bool{}
+	for _, activity := range activities {
+		titles[activity.Title] = true
+	}
+	if len(activities) != 2 || !titles["灵隐寺"] || !titles["中国茶叶博物馆"] {
+		t.Errorf("Expected the morning activity to be kept, got %v", titles)
+	}
+	if other, _ := travelService.GetActivities("day-1"); len(other) != 1 {
+		t.Errorf("Other days should not change, got %d activities", len(other))
+	}
+}
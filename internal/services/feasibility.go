@@ -0,0 +1,179 @@
+package services
+
+import (
+	"ai-travel-planner/internal/cache"
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultMaxDailyTravelMinutes = 180
+	defaultWalkingDistanceMeters = 1500
+	routeDurationCacheTTL        = 6 * time.Hour
+)
+
+// FeasibilityReport 行程可行性检查结果
+type FeasibilityReport struct {
+	Provider  string                      `json:"provider"`
+	Checked   int                         `json:"checked"`   // 查询了路线的相邻活动对数
+	Unchecked int                         `json:"unchecked"` // 缺少坐标或路线查询失败而跳过的活动对数
+	Days      []DayTravel                 `json:"days"`
+	Warnings  []models.FeasibilityWarning `json:"warnings"`
+}
+
+// DayTravel 一天中相邻活动之间的路上时间和距离合计
+type DayTravel struct {
+	DayNumber      int `json:"day_number"`
+	TravelMinutes  int `json:"travel_minutes"`
+	DistanceMeters int `json:"distance_meters"`
+}
+
+// travelLeg 两个地点之间的路线时间和距离
+type travelLeg struct {
+	Mode    string  `json:"mode"`
+	Minutes float64 `json:"minutes"`
+	Meters  float64 `json:"meters"`
+}
+
+// CheckPlanFeasibility 查询每天相邻的两个已定位活动之间的路线（近距离步行，其余驾车，偏好公共交通时乘公交），
+// 路上时间超过两个活动开始时间的间隔时记为冲突，一天的路上时间超过上限时提醒。
+// 路线结果按地点缓存，查询失败的活动对计入 Unchecked
+func (s *TravelService) CheckPlanFeasibility(tree *PlanTree, provider MapProvider) *FeasibilityReport {
+	settings := s.config.Planner.Feasibility
+	maxDaily := settings.MaxDailyTravelMinutes
+	if maxDaily <= 0 {
+		maxDaily = defaultMaxDailyTravelMinutes
+	}
+
+	report := &FeasibilityReport{Provider: provider.Name(), Days: []DayTravel{}, Warnings: []models.FeasibilityWarning{}}
+	for _, day := range tree.Days {
+		total := DayTravel{DayNumber: day.Day.DayNumber}
+		var travelMinutes float64
+		for i := 1; i < len(day.Activities); i++ {
+			from, to := day.Activities[i-1], day.Activities[i]
+			if !hasCoordinates(from) || !hasCoordinates(to) {
+				report.Unchecked++
+				continue
+			}
+
+			mode := s.travelMode(tree.Plan, from, to)
+			leg, err := s.travelLeg(provider, mode, from, to, tree.Plan.Destination)
+			if err != nil && mode == RouteModeTransit {
+				// 不支持公交或没有公交方案时按驾车估算
+				leg, err = s.travelLeg(provider, RouteModeDriving, from, to, tree.Plan.Destination)
+			}
+			if err != nil {
+				report.Unchecked++
+				continue
+			}
+			report.Checked++
+			travelMinutes += leg.Minutes
+			total.DistanceMeters += int(math.Round(leg.Meters))
+
+			// 没有结束时间时按开始时间的间隔计算，只提醒肯定来不及的安排
+			available := to.StartTime.Sub(from.StartTime)
+			if from.EndTime.After(from.StartTime) {
+				available = to.StartTime.Sub(from.EndTime)
+			}
+			needed := int(math.Ceil(leg.Minutes))
+			if float64(needed) > available.Minutes() {
+				report.Warnings = append(report.Warnings, models.FeasibilityWarning{
+					Type:             models.FeasibilityTravelConflict,
+					Severity:         models.BudgetSeverityCritical,
+					DayNumber:        day.Day.DayNumber,
+					FromActivityID:   from.ID,
+					ToActivityID:     to.ID,
+					Mode:             leg.Mode,
+					TravelMinutes:    needed,
+					AvailableMinutes: int(math.Max(0, math.Floor(available.Minutes()))),
+					Message: fmt.Sprintf(localizedText(tree.Plan.Locale,
+						"从「%[1]s」到「%[2]s」%[3]s约需 %[4]d 分钟，但只安排了 %[5]d 分钟",
+						"Getting from %[1]q to %[2]q takes about %[4]d minutes by %[3]s, but only %[5]d minutes are planned"),
+						from.Title, to.Title, routeModeLabel(leg.Mode, tree.Plan.Locale), needed, int(math.Max(0, math.Floor(available.Minutes())))),
+				})
+			}
+		}
+		total.TravelMinutes = int(math.Round(travelMinutes))
+		report.Days = append(report.Days, total)
+
+		if total.TravelMinutes > maxDaily {
+			report.Warnings = append(report.Warnings, models.FeasibilityWarning{
+				Type:             models.FeasibilityExcessiveTravel,
+				Severity:         models.BudgetSeverityWarning,
+				DayNumber:        day.Day.DayNumber,
+				TravelMinutes:    total.TravelMinutes,
+				AvailableMinutes: maxDaily,
+				Message: fmt.Sprintf(localizedText(tree.Plan.Locale,
+					"第 %d 天活动之间的路上时间约 %d 分钟，超过了 %d 分钟的上限",
+					"Day %d has about %d minutes of travel between activities, more than the limit of %d minutes"),
+					day.Day.DayNumber, total.TravelMinutes, maxDaily),
+			})
+		}
+	}
+	return report
+}
+
+// routeModeLabel 出行方式在提醒中的名称
+func routeModeLabel(mode, locale string) string {
+	labels := map[string]string{RouteModeWalking: "步行", RouteModeDriving: "驾车", RouteModeCycling: "骑行", RouteModeTransit: "乘公交"}
+	if label, ok := labels[mode]; ok {
+		return localizedText(locale, label, mode)
+	}
+	return mode
+}
+
+// travelMode 两个活动之间的出行方式：近距离步行，其余驾车，偏好公共交通时乘公交
+func (s *TravelService) travelMode(plan *models.TravelPlan, from, to *models.Activity) string {
+	walkingDistance := float64(s.config.Planner.Feasibility.WalkingDistanceMeters)
+	if walkingDistance <= 0 {
+		walkingDistance = defaultWalkingDistanceMeters
+	}
+	fromLng, fromLat := ActivityLngLat(from, coord.WGS84)
+	toLng, toLat := ActivityLngLat(to, coord.WGS84)
+	if HaversineMeters(fromLng, fromLat, toLng, toLat) <= walkingDistance {
+		return RouteModeWalking
+	}
+	if plan.Preferences != nil {
+		for _, mode := range plan.Preferences.TransportModes {
+			if mode == "public_transit" {
+				return RouteModeTransit
+			}
+		}
+	}
+	return RouteModeDriving
+}
+
+// travelLeg 查询两个活动之间的路线时间（坐标转换为地图服务的坐标系），结果按服务、方式和坐标缓存
+func (s *TravelService) travelLeg(provider MapProvider, mode string, from, to *models.Activity, city string) (*travelLeg, error) {
+	origin, destination := ActivityLocation(from, provider.Datum()), ActivityLocation(to, provider.Datum())
+	key := cache.Key(provider.Name(), mode, origin, destination, city)
+	var leg travelLeg
+	if data, ok := s.routeCache.Get(key); ok && json.Unmarshal(data, &leg) == nil {
+		return &leg, nil
+	}
+
+	response, err := PlanRoute(provider, mode, origin, destination, city)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := NormalizeRoutes(provider.Name(), mode, response)
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no %s route found", mode)
+	}
+
+	leg = travelLeg{Mode: mode, Minutes: float64(routes[0].DurationSeconds) / 60, Meters: float64(routes[0].DistanceMeters)}
+	if data, err := json.Marshal(leg); err == nil {
+		s.routeCache.Set(key, data, routeDurationCacheTTL)
+	}
+	return &leg, nil
+}
+
+// hasCoordinates 活动是否有坐标
+func hasCoordinates(activity *models.Activity) bool {
+	return activity.Latitude != 0 || activity.Longitude != 0
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestTravelService_CheckPlanFeasibility(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, feasibilityTestPlan)
+	provider := lineProvider()
+
+	report := travelService.CheckPlanFeasibility(tree, provider)
+	if report.Checked != 3 || report.Unchecked != 1 {
+		t.Errorf("Expected 3 checked and 1 unchecked pairs, got %d and %d", report.Checked, report.Unchecked)
+	}
+	if len(report.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %+v", report.Warnings)
+	}
+	conflict := report.Warnings[0]
+	if conflict.Type != models.FeasibilityTravelConflict || conflict.FromActivityID != "act-b" || conflict.ToActivityID != "act-c" ||
+		conflict.Mode != RouteModeDriving || conflict.TravelMinutes != 50 || conflict.AvailableMinutes != 30 {
+		t.Errorf("Unexpected conflict: %+v", conflict)
+	}
+	excessive := report.Warnings[1]
+	if excessive.Type != models.FeasibilityExcessiveTravel || excessive.DayNumber != 2 || excessive.TravelMinutes != 200 || excessive.AvailableMinutes != 180 {
+		t.Errorf("Unexpected daily travel warning: %+v", excessive)
+	}
+	if conflict.Message != "从「act-b」到「act-c」驾车约需 50 分钟，但只安排了 30 分钟" {
+		t.Errorf("Unexpected conflict message: %q", conflict.Message)
+	}
+	if report.Days[0].TravelMinutes < 50 || report.Days[1].TravelMinutes != 200 {
+		t.Errorf("Unexpected daily totals: %+v", report.Days)
+	}
+
+	// 近距离按步行查询，路线结果被缓存
+	queries := len(provider.queries)
+	if provider.queries[0] != "route:walking" {
+		t.Errorf("Expected the short hop to be walked, got %v", provider.queries)
+	}
+	travelService.CheckPlanFeasibility(tree, provider)
+	if len(provider.queries) != queries {
+		t.Errorf("Expected cached routes, got %d new queries", len(provider.queries)-queries)
+	}
+}
+
+func TestTravelService_CheckPlanFeasibilityTransit(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, feasibilityTestPlan)
+	tree.Plan.Preferences = &models.TripPreferences{TransportModes: []string{"public_transit"}}
+
+	report := travelService.CheckPlanFeasibility(tree, lineProvider())
+	if conflict := report.Warnings[0]; conflict.Mode != RouteModeTransit || conflict.TravelMinutes != 100 {
+		t.Errorf("Expected a transit conflict, got %+v", conflict)
+	}
+
+	// 路线查询失败时跳过，不产生提醒（新的服务实例，避免命中路线缓存）
+	report = NewTravelService(&config.Config{}).CheckPlanFeasibility(tree, &stubMapProvider{})
+	if report.Checked != 0 || report.Unchecked != 4 || len(report.Warnings) != 0 {
+		t.Errorf("Expected every pair to be unchecked, got %+v", report)
+	}
+}
+
+func TestTravelService_CheckPlanFeasibilityEnglish(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, feasibilityTestPlan)
+	tree.Plan.Locale = "en-US"
+
+	report := travelService.CheckPlanFeasibility(tree, lineProvider())
+	if len(report.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %+v", report.Warnings)
+	}
+	if message := report.Warnings[0].Message; message != `Getting from "act-b" to "act-c" takes about 50 minutes by driving, but only 30 minutes are planned` {
+		t.Errorf("Unexpected conflict message: %q", message)
+	}
+	if message := report.Warnings[1].Message; !strings.HasPrefix(message, "Day 2") {
+		t.Errorf("Expected an English daily travel warning, got %q", message)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/guardrails"
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuardrailError 用户输入或模型输出未通过安全检查
+type GuardrailError struct {
+	Stage    string
+	Field    string
+	Findings []guardrails.Finding
+}
+
+func (e *GuardrailError) Error() string {
+	if e.Stage == models.GuardrailStageInput {
+		return fmt.Sprintf("input field %s was rejected by guardrail rule %s", e.Field, e.Findings[0].Rule)
+	}
+	return fmt.Sprintf("LLM output was rejected by guardrail rule %s", e.Findings[0].Rule)
+}
+
+type GuardrailService struct {
+	config *config.Config
+	db     *MemoryDB
+}
+
+func NewGuardrailService(cfg *config.Config) *GuardrailService {
+	return &GuardrailService{
+		config: cfg,
+		db:     NewMemoryDB(),
+	}
+}
+
+// CheckInput 清理拼接进提示词的用户输入并检测指令注入，违规时记录并按配置拦截
+func (s *GuardrailService) CheckInput(meta LLMCallMeta, field, text string) (string, error) {
+	clean := guardrails.Sanitize(text, s.config.Guardrails.MaxInputLength)
+	findings := guardrails.DetectInjection(clean)
+	if len(findings) == 0 {
+		return clean, nil
+	}
+	return clean, s.report(meta, models.GuardrailStageInput, field, findings)
+}
+
+// CheckOutput 在持久化之前检查模型输出，违规时记录并按配置拦截
+func (s *GuardrailService) CheckOutput(meta LLMCallMeta, text string) error {
+	findings := guardrails.ScreenOutput(text, s.config.Guardrails.BlockedTerms)
+	if len(findings) == 0 {
+		return nil
+	}
+	return s.report(meta, models.GuardrailStageOutput, "response", findings)
+}
+
+// GetViolations 获取违规记录（最新的在前）
+func (s *GuardrailService) GetViolations() ([]*models.GuardrailViolation, error) {
+	violations, err := s.db.GetGuardrailViolations()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].CreatedAt.After(violations[j].CreatedAt) })
+	return violations, nil
+}
+
+// report 记录违规，block 模式下返回 GuardrailError
+func (s *GuardrailService) report(meta LLMCallMeta, stage, field string, findings []guardrails.Finding) error {
+	blocked := s.config.Guardrails.Mode != "log"
+	for _, finding := range findings {
+		violation := &models.GuardrailViolation{
+			ID:        uuid.New().String(),
+			UserID:    meta.UserID,
+			PlanID:    meta.PlanID,
+			Endpoint:  meta.Endpoint,
+			Stage:     stage,
+			Field:     field,
+			Rule:      finding.Rule,
+			Excerpt:   finding.Excerpt,
+			Blocked:   blocked,
+			CreatedAt: time.Now(),
+		}
+		if err := s.db.CreateGuardrailViolation(violation); err != nil {
+			log.Printf("记录安全检查违规失败: %v", err)
+		}
+		log.Printf("安全检查违规: user=%s endpoint=%s stage=%s field=%s rule=%s blocked=%v",
+			meta.UserID, meta.Endpoint, stage, field, finding.Rule, blocked)
+	}
+
+	if !blocked {
+		return nil
+	}
+	return &GuardrailError{Stage: stage, Field: field, Findings: findings}
+}
@@ -1,39 +1,97 @@
 package services
 
 import (
+	"ai-travel-planner/internal/cache"
 	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/guardrails"
 	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/prompts"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 type LLMService struct {
-	config *config.Config
+	config       *config.Config
+	prompts      *prompts.Store
+	usageService *UsageService
+	quotaService *QuotaService
+	guardrails   *GuardrailService
+	cache        cache.Cache // 为 nil 时不缓存
+}
+
+// LLMCallMeta 描述一次LLM调用的归属信息，用于用量统计
+type LLMCallMeta struct {
+	UserID   string
+	PlanID   string
+	Endpoint string
+
+	// Locale 期望的输出语言，用于选择本地化的提示词模板
+	Locale string
+
+	// BypassCache 跳过响应缓存，强制请求模型（新结果仍会写入缓存）
+	BypassCache bool
+
+	// PromptVersions 非空时记录本次调用使用的模板版本（模板名 -> 版本）
+	PromptVersions map[string]string
 }
 
 type OpenAIRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens"`
-	Temperature float64   `json:"temperature"`
+	Model       string       `json:"model"`
+	Messages    []Message    `json:"messages"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature float64      `json:"temperature"`
+	Tools       []OpenAITool `json:"tools,omitempty"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// OpenAITool 可供模型调用的工具定义
+type OpenAITool struct {
+	Type     string         `json:"type"` // 固定为 function
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction 工具函数的名称、说明和参数 JSON Schema
+type OpenAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall 模型发起的工具调用
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON字符串
+	} `json:"function"`
 }
 
 type OpenAIResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message Message `json:"message"`
 	} `json:"choices"`
+	Usage OpenAIUsage `json:"usage"`
+}
+
+// OpenAIUsage 响应中的token用量
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type TravelPlanResult struct {
@@ -43,6 +101,19 @@ type TravelPlanResult struct {
 		Breakdown map[string]float64 `json:"breakdown"`
 	} `json:"budget"`
 	Recommendations []string `json:"recommendations"`
+
+	// PromptVersions 生成时使用的提示词模板版本（由服务端填写）
+	PromptVersions map[string]string `json:"prompt_versions,omitempty"`
+
+	// Generator 生成方式：llm 或 offline（由服务端填写）
+	Generator string `json:"generator,omitempty"`
+
+	// UngroundedActivities 没有找到对应真实POI的活动（grounded 模式，由服务端填写），需要用户确认地点
+	UngroundedActivities []UngroundedActivity `json:"ungrounded_activities,omitempty"`
+
+	// Provider、Model 生成时使用的模型服务商和模型名（由服务端填写）
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
 }
 
 type DayPlan struct {
@@ -58,39 +129,63 @@ type Activity struct {
 	Location    string  `json:"location"`
 	Cost        float64 `json:"cost"`
 	Type        string  `json:"type"`
+
+	// 以下字段由高德POI校验后填写（grounded 模式）
+	POIID     string  `json:"poi_id,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+
+	// GeocodeStatus、GeocodeConfidence 与真实POI的对应情况，找不到可信的POI时为 unresolved（grounded 模式）
+	GeocodeStatus     string  `json:"geocode_status,omitempty"`
+	GeocodeConfidence float64 `json:"geocode_confidence,omitempty"`
+
+	// CoordinateSystem 坐标所在的坐标系，由填写坐标的地图服务决定，不由模型给出
+	CoordinateSystem string `json:"-"`
+}
+
+// UngroundedActivity 没有对应真实POI的活动
+type UngroundedActivity struct {
+	Day      int    `json:"day"`
+	Time     string `json:"time"`
+	Title    string `json:"title"`
+	Location string `json:"location"`
+}
+
+// ToRecord 将LLM返回的活动转换为指定日程的活动记录
+func (a Activity) ToRecord(dayID string, date time.Time) *models.Activity {
+	record := BuildActivity(dayID, date, a.Time, a.Type, a.Title, a.Description, a.Location, a.Cost)
+	record.POIID = a.POIID
+	record.Address = a.Address
+	record.Latitude = a.Latitude
+	record.Longitude = a.Longitude
+	record.GeocodeStatus = a.GeocodeStatus
+	record.GeocodeConfidence = a.GeocodeConfidence
+	if record.Latitude != 0 || record.Longitude != 0 {
+		record.CoordinateSystem = a.CoordinateSystem
+	}
+	return record
 }
 
 // ParseVoiceToPlanFieldsWithKey 使用LLM将语音文本解析为结构化行程字段
-func (s *LLMService) ParseVoiceToPlanFieldsWithKey(transcript, apiKey, baseURL, model string) (map[string]interface{}, error) {
-	prompt := fmt.Sprintf(`你是一个旅行助手。请从下面的中文用户语音文本中提取旅行规划表单所需字段，并只以JSON返回：
-
-文本："%s"
-
-严格返回以下JSON字段（缺失请填空或合理推断，日期用YYYY-MM-DD）：
-{
-  "destination": "字符串，目的地",
-  "start_date": "YYYY-MM-DD，可空",
-  "end_date": "YYYY-MM-DD，可空",
-  "people": 2,
-  "budget": 10000,
-  "preferences": ["美食", "亲子"]
-}
-注意：
-- 不要输出除JSON以外的任何文字；
-- 如果只给出时长（例如3天）和开始日期，请按开始日期+时长计算结束日期；
-- 预算单位默认人民币；
-`, transcript)
-
-	resp, err := s.callOpenAIWithKey(prompt, apiKey, baseURL, model)
+func (s *LLMService) ParseVoiceToPlanFieldsWithKey(meta LLMCallMeta, transcript, apiKey, baseURL, model string) (map[string]interface{}, error) {
+	meta.Endpoint = UsageEndpointVoiceUnderstanding
+	transcript, err := s.userInput(meta, "transcript", transcript)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.renderPrompt(meta, "voice_plan_fields", map[string]interface{}{"Transcript": transcript})
 	if err != nil {
 		return nil, err
 	}
 
-	resp = strings.TrimSpace(resp)
-	if strings.HasPrefix(resp, "`") { // 清理潜在代码块
-		resp = strings.Trim(resp, "` ")
+	resp, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
 	}
 
+	resp = extractJSON(resp) // 清理潜在代码块
+
 	var obj map[string]interface{}
 	if err := json.Unmarshal([]byte(resp), &obj); err != nil {
 		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw: %s", err, resp)
@@ -99,31 +194,22 @@ func (s *LLMService) ParseVoiceToPlanFieldsWithKey(transcript, apiKey, baseURL,
 }
 
 // ParseVoiceToExpenseFieldsWithKey 使用LLM将语音文本解析为费用表单字段
-func (s *LLMService) ParseVoiceToExpenseFieldsWithKey(transcript, apiKey, baseURL, model string) (map[string]interface{}, error) {
-	prompt := fmt.Sprintf(`你是一个旅行记账助手。请从下面的中文用户语音文本中提取费用记录字段，并只以JSON返回：
-
-文本："%s"
-
-严格返回以下JSON字段（缺失请填空或合理推断，日期用YYYY-MM-DD）：
-{
-  "category": "字符串，类别：food/transport/accommodation/shopping/other",
-  "description": "字符串，简短描述",
-  "amount": 123.45,
-  "currency": "CNY",
-  "date": "YYYY-MM-DD"
-}
-注意：
-- 不要输出除JSON以外的任何文字；
-- 金额默认单位人民币，中文金额如“一百二”“两百左右”需换算为数字；`, transcript)
-
-	resp, err := s.callOpenAIWithKey(prompt, apiKey, baseURL, model)
+func (s *LLMService) ParseVoiceToExpenseFieldsWithKey(meta LLMCallMeta, transcript, apiKey, baseURL, model string) (map[string]interface{}, error) {
+	meta.Endpoint = UsageEndpointVoiceUnderstanding
+	transcript, err := s.userInput(meta, "transcript", transcript)
 	if err != nil {
 		return nil, err
 	}
-	resp = strings.TrimSpace(resp)
-	if strings.HasPrefix(resp, "`") {
-		resp = strings.Trim(resp, "` ")
+	prompt, err := s.renderPrompt(meta, "voice_expense_fields", map[string]interface{}{"Transcript": transcript})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
 	}
+	resp = extractJSON(resp)
 	var obj map[string]interface{}
 	if err := json.Unmarshal([]byte(resp), &obj); err != nil {
 		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw: %s", err, resp)
@@ -131,29 +217,79 @@ func (s *LLMService) ParseVoiceToExpenseFieldsWithKey(transcript, apiKey, baseUR
 	return obj, nil
 }
 
-func NewLLMService(cfg *config.Config) *LLMService {
+// NewLLMService 创建LLM服务，usageService 为空时不记录用量，quotaService 为空时不限制配额
+func NewLLMService(cfg *config.Config, promptStore *prompts.Store, usageService *UsageService, quotaService *QuotaService, guardrailService *GuardrailService, responseCache cache.Cache) *LLMService {
 	return &LLMService{
-		config: cfg,
+		config:       cfg,
+		prompts:      promptStore,
+		usageService: usageService,
+		quotaService: quotaService,
+		guardrails:   guardrailService,
+		cache:        responseCache,
 	}
 }
 
-// GenerateTravelPlan 生成旅行计划
-func (s *LLMService) GenerateTravelPlan(request *models.CreateTravelPlanRequest) (*TravelPlanResult, error) {
-	return s.GenerateTravelPlanWithKey(request, "", "")
+// checkInput 清理并检查用户输入，未配置安全检查时只做清理
+func (s *LLMService) checkInput(meta LLMCallMeta, field, text string) (string, error) {
+	if s.guardrails == nil {
+		return guardrails.Sanitize(text, 0), nil
+	}
+	return s.guardrails.CheckInput(meta, field, text)
+}
+
+// userInput 检查用户输入并用分隔标签包裹，用于拼接进提示词模板
+func (s *LLMService) userInput(meta LLMCallMeta, field, text string) (string, error) {
+	clean, err := s.checkInput(meta, field, text)
+	if err != nil {
+		return "", err
+	}
+	return guardrails.Delimit(clean), nil
+}
+
+// renderPrompt 渲染提示词模板，并在 meta 中记录使用的版本
+func (s *LLMService) renderPrompt(meta LLMCallMeta, name string, data interface{}) (string, error) {
+	rendered, err := s.prompts.Render(name, meta.UserID, meta.Locale, data)
+	if err != nil {
+		return "", err
+	}
+	if meta.PromptVersions != nil {
+		meta.PromptVersions[name] = rendered.Version
+	}
+	return rendered.Text, nil
 }
 
 // GenerateTravelPlanWithKey 使用指定的API Key生成旅行计划
-func (s *LLMService) GenerateTravelPlanWithKey(request *models.CreateTravelPlanRequest, apiKey, baseURL string) (*TravelPlanResult, error) {
+func (s *LLMService) GenerateTravelPlanWithKey(meta LLMCallMeta, request *models.CreateTravelPlanRequest, apiKey, baseURL string) (*TravelPlanResult, error) {
 	// 构建提示词
-	prompt := s.buildTravelPrompt(request)
+	meta.PromptVersions = make(map[string]string)
+	meta.Endpoint = UsageEndpointPlanGeneration
+	prompt, err := s.buildTravelPrompt(meta, request)
+	if err != nil {
+		return nil, err
+	}
 
 	// 调用OpenAI API
-	response, err := s.callOpenAIWithKey(prompt, apiKey, baseURL, request.OpenAIModel)
+	response, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, request.OpenAIModel)
 	if err != nil {
 		return nil, err
 	}
 
 	// 解析响应 - 先清理响应文本，提取JSON部分
+	response = extractJSON(response)
+
+	var result TravelPlanResult
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+	result.PromptVersions = meta.PromptVersions
+	result.Generator = PlanGeneratorLLM
+	result.Provider, result.Model = s.ModelInfo(baseURL, request.OpenAIModel)
+
+	return &result, nil
+}
+
+// extractJSON 清理LLM响应，去掉可能包裹JSON的markdown代码块
+func extractJSON(response string) string {
 	response = strings.TrimSpace(response)
 
 	// 如果响应被markdown代码块包围，提取其中的JSON
@@ -195,139 +331,175 @@ func (s *LLMService) GenerateTravelPlanWithKey(request *models.CreateTravelPlanR
 		response = strings.Join(jsonLines, "\n")
 	}
 
-	var result TravelPlanResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
-	}
-
-	return &result, nil
+	return response
 }
 
 // buildTravelPrompt 构建旅行规划提示词
-func (s *LLMService) buildTravelPrompt(request *models.CreateTravelPlanRequest) string {
-	preferences := ""
+func (s *LLMService) buildTravelPrompt(meta LLMCallMeta, request *models.CreateTravelPlanRequest) (string, error) {
+	data, err := s.travelPromptData(meta, request)
+	if err != nil {
+		return "", err
+	}
+	return s.renderPrompt(meta, "travel_plan", data)
+}
+
+// travelPromptData 行程生成模板的数据，用户填写的字段经过安全检查
+func (s *LLMService) travelPromptData(meta LLMCallMeta, request *models.CreateTravelPlanRequest) (map[string]interface{}, error) {
+	destination, err := s.userInput(meta, "destination", request.Destination)
+	if err != nil {
+		return nil, err
+	}
+	var description string
 	if request.Preferences != nil {
-		prefs, _ := json.Marshal(request.Preferences)
-		preferences = string(prefs)
-	}
-
-	return fmt.Sprintf(`
-你是一个专业的旅行规划师。请根据以下信息生成详细的旅行计划：
-
-目的地：%s
-出发日期：%s
-结束日期：%s
-预算：%.2f元
-人数：%d人
-偏好：%s
-
-请严格按照以下JSON格式返回旅行计划，不要添加任何markdown标记或其他文字：
-
-{
-  "days": [
-    {
-      "day": 1,
-      "date": "2025-01-01",
-      "activities": [
-        {
-          "time": "09:00",
-          "title": "活动名称",
-          "description": "活动描述",
-          "location": "地点",
-          "cost": 100.0,
-          "type": "attraction"
-        }
-      ]
-    }
-  ],
-  "budget": {
-    "total": %.2f,
-    "breakdown": {
-      "accommodation": 1000.0,
-      "food": 500.0,
-      "transport": 800.0,
-      "attractions": 600.0
-    }
-  },
-  "recommendations": [
-    "实用建议1",
-    "实用建议2"
-  ]
-}
-
-要求：
-- 行程要合理，不要过于紧凑
-- 考虑当地特色和文化
-- 提供具体的费用估算
-- 包含交通方式和时间安排
-- 给出实用的旅行建议
-- 只返回JSON，不要其他内容
-`, request.Destination, request.StartDate.Time.Format("2006-01-02"),
-		request.EndDate.Time.Format("2006-01-02"), request.Budget, request.People, preferences, request.Budget)
-}
-
-// callOpenAI 调用OpenAI API（使用配置中的Key）
-func (s *LLMService) callOpenAI(prompt string) (string, error) {
-	return s.callOpenAIWithKey(prompt, "", "", "")
+		description = request.Preferences.Description
+	}
+	preferences, err := s.userInput(meta, "preferences", description)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"Destination": destination,
+		"StartDate":   request.StartDate.Time.Format("2006-01-02"),
+		"EndDate":     request.EndDate.Time.Format("2006-01-02"),
+		"Budget":      request.Budget,
+		"People":      request.People,
+		"Preferences": preferences,
+		"Constraints": PreferenceConstraints(request.Preferences, ResolveLocale(meta.Locale)),
+		"Currency":    CurrencyForLocale(ResolveLocale(meta.Locale)),
+		"Language":    LocaleName(ResolveLocale(meta.Locale)),
+	}, nil
 }
 
 // callOpenAIWithKey 使用指定的API Key调用OpenAI API
-func (s *LLMService) callOpenAIWithKey(prompt, apiKey, baseURL, model string) (string, error) {
+func (s *LLMService) callOpenAIWithKey(meta LLMCallMeta, prompt, apiKey, baseURL, model string) (string, error) {
+	systemPrompt, err := s.renderPrompt(meta, "system", nil)
+	if err != nil {
+		return "", err
+	}
+
+	messages := []Message{
+		{
+			Role:    "system",
+			Content: systemPrompt,
+		},
+		{
+			Role:    "user",
+			Content: prompt,
+		},
+	}
+	return s.callOpenAIMessagesWithKey(meta, messages, apiKey, baseURL, model)
+}
+
+// callOpenAIMessagesWithKey 使用指定的API Key发送多轮消息
+func (s *LLMService) callOpenAIMessagesWithKey(meta LLMCallMeta, messages []Message, apiKey, baseURL, model string) (string, error) {
+	reply, err := s.chatCompletionWithKey(meta, messages, nil, apiKey, baseURL, model)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// chatCompletionWithKey 发送多轮消息并返回模型的完整回复（可能包含工具调用）
+func (s *LLMService) chatCompletionWithKey(meta LLMCallMeta, messages []Message, tools []OpenAITool, apiKey, baseURL, model string) (*Message, error) {
 	// 优先使用传入的API Key，否则使用配置中的
 	actualApiKey := apiKey
+	keySource := KeySourceUser
 	if actualApiKey == "" {
 		actualApiKey = s.config.APIs.OpenAI.APIKey
+		keySource = KeySourceServer
 	}
 
 	// 检查OpenAI API密钥是否配置
 	if actualApiKey == "" {
-		return "", fmt.Errorf("OpenAI API key is not configured. Please configure it in config.yaml or settings")
-	}
-
-	// 优先使用传入的BaseURL，否则使用配置中的
-	actualBaseURL := baseURL
-	if actualBaseURL == "" {
-		actualBaseURL = s.config.APIs.OpenAI.BaseURL
+		return nil, fmt.Errorf("OpenAI API key is not configured. Please configure it in config.yaml or settings")
 	}
 
-	// 模型名：优先使用传入的，否则配置中的
-	actualModel := model
-	if actualModel == "" {
-		actualModel = s.config.APIs.OpenAI.Model
-		if actualModel == "" {
-			actualModel = "gpt-4o-mini"
-		}
-	}
+	actualBaseURL, actualModel := s.resolveModel(baseURL, model)
 
 	requestBody := OpenAIRequest{
-		Model: actualModel,
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "你是一个专业的旅行规划师，擅长制定详细的旅行计划。你必须只返回有效的JSON格式响应，不要包含任何markdown标记、代码块或其他文字说明。只返回纯JSON数据。",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+		Model:       actualModel,
+		Messages:    messages,
 		MaxTokens:   4000,
 		Temperature: 0.7,
+		Tools:       tools,
+	}
+
+	// 相同请求直接返回缓存的回复，不消耗配额
+	cacheKey := responseCacheKey(actualBaseURL, requestBody)
+	if s.cache != nil && !meta.BypassCache {
+		if cached, ok := s.cache.Get(cacheKey); ok {
+			var reply Message
+			if err := json.Unmarshal(cached, &reply); err == nil {
+				return &reply, nil
+			}
+		}
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", actualBaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+actualApiKey)
 
+	// 调用前预留用量记录；使用服务端Key时配额检查与预留一起完成，并发请求不会同时通过检查
+	var checkQuota func() error
+	if keySource == KeySourceServer && s.quotaService != nil && meta.UserID != "" {
+		checkQuota = func() error { return s.quotaService.CheckQuota(meta.UserID) }
+	}
+	var reservation *models.LLMUsage
+	if s.usageService != nil {
+		if reservation, err = s.usageService.ReserveLLMUsage(meta, actualModel, keySource, checkQuota); err != nil {
+			return nil, err
+		}
+	} else if checkQuota != nil {
+		if err := checkQuota(); err != nil {
+			return nil, err
+		}
+	}
+
+	openAIResp, body, err := s.postChatCompletion(req)
+
+	// 按实际用量结算，失败的调用同样计入（统计失败不影响本次调用结果）
+	if reservation != nil {
+		if settleErr := s.usageService.SettleLLMUsage(reservation.ID, openAIResp.Usage, err != nil); settleErr != nil {
+			log.Printf("记录LLM用量失败: %v", settleErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in OpenAI response: %s", string(body))
+	}
+
+	// 最终回复在持久化之前做输出检查（工具调用轮次的内容不会被保存）
+	reply := &openAIResp.Choices[0].Message
+	if s.guardrails != nil && len(reply.ToolCalls) == 0 {
+		if err := s.guardrails.CheckOutput(meta, reply.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.cache != nil {
+		if data, err := json.Marshal(reply); err == nil {
+			s.cache.Set(cacheKey, data, time.Duration(s.config.APIs.OpenAI.Cache.TTLSeconds)*time.Second)
+		}
+	}
+	return reply, nil
+}
+
+// postChatCompletion 发送请求并解析回复，非200状态码视为失败
+func (s *LLMService) postChatCompletion(req *http.Request) (OpenAIResponse, []byte, error) {
+	var openAIResp OpenAIResponse
 	timeout := time.Duration(s.config.APIs.OpenAI.TimeoutSeconds)
 	if timeout <= 0 {
 		timeout = 60
@@ -335,30 +507,75 @@ func (s *LLMService) callOpenAIWithKey(prompt, apiKey, baseURL, model string) (s
 	client := &http.Client{Timeout: timeout * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request to OpenAI: %v", err)
+		return openAIResp, nil, fmt.Errorf("failed to make request to OpenAI: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read OpenAI response: %v", err)
+		return openAIResp, nil, fmt.Errorf("failed to read OpenAI response: %v", err)
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("OpenAI API returned status %d: %s", resp.StatusCode, string(body))
+		return openAIResp, body, fmt.Errorf("OpenAI API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var openAIResp OpenAIResponse
 	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return "", fmt.Errorf("failed to parse OpenAI response: %v. Response: %s", err, string(body))
+		return openAIResp, body, fmt.Errorf("failed to parse OpenAI response: %v. Response: %s", err, string(body))
 	}
+	return openAIResp, body, nil
+}
 
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in OpenAI response: %s", string(body))
+// resolveModel 确定实际使用的BaseURL和模型名：优先使用传入的，否则使用配置中的
+func (s *LLMService) resolveModel(baseURL, model string) (string, string) {
+	if baseURL == "" {
+		baseURL = s.config.APIs.OpenAI.BaseURL
+	}
+	if model == "" {
+		model = s.config.APIs.OpenAI.Model
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+	}
+	return baseURL, model
+}
+
+// ModelInfo 返回一次调用实际使用的模型服务商和模型名，用于记录行程的生成来源
+func (s *LLMService) ModelInfo(baseURL, model string) (string, string) {
+	baseURL, model = s.resolveModel(baseURL, model)
+	return ProviderName(baseURL), model
+}
+
+// knownProviders 常见 OpenAI 兼容服务的域名和名称
+var knownProviders = []struct{ host, name string }{
+	{"openai.com", "openai"},
+	{"azure.com", "azure"},
+	{"deepseek.com", "deepseek"},
+	{"dashscope.aliyuncs.com", "dashscope"},
+	{"moonshot.cn", "moonshot"},
+	{"bigmodel.cn", "zhipu"},
+	{"openrouter.ai", "openrouter"},
+}
+
+// ProviderName 根据BaseURL推断模型服务商，无法识别时返回主机名
+func ProviderName(baseURL string) string {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	host := parsed.Hostname()
+	for _, provider := range knownProviders {
+		if host == provider.host || strings.HasSuffix(host, "."+provider.host) {
+			return provider.name
+		}
 	}
+	return host
+}
 
-	return openAIResp.Choices[0].Message.Content, nil
+// HasServerKey 服务端是否配置了可兜底的API Key
+func (s *LLMService) HasServerKey() bool {
+	return s.config.APIs.OpenAI.APIKey != ""
 }
 
 // TestApiKey 测试API Key是否有效
@@ -419,43 +636,12 @@ func (s *LLMService) TestApiKey(apiKey, baseURL string) error {
 	return nil
 }
 
-// AnalyzeBudget 分析预算
-func (s *LLMService) AnalyzeBudget(planID string, expenses []models.Expense) (map[string]interface{}, error) {
-	// 构建预算分析提示词
-	prompt := fmt.Sprintf(`
-请分析以下旅行费用数据，并提供预算建议：
-
-费用记录：
-%s
-
-请提供：
-1. 费用趋势分析
-2. 预算超支警告
-3. 省钱建议
-4. 费用优化方案
-
-请用JSON格式回复。
-`, s.formatExpenses(expenses))
-
-	response, err := s.callOpenAI(prompt)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
-}
-
 // formatExpenses 格式化费用数据
-func (s *LLMService) formatExpenses(expenses []models.Expense) string {
+func (s *LLMService) formatExpenses(expenses []*models.Expense) string {
 	var result string
 	for _, expense := range expenses {
-		result += fmt.Sprintf("- %s: %.2f元 (%s) - %s\n",
-			expense.Category, expense.Amount, expense.Currency, expense.Description)
+		result += fmt.Sprintf("- %s %s: %.2f %s - %s\n",
+			expense.Date.Format("2006-01-02"), expense.Category, expense.Amount, expense.Currency, expense.Description)
 	}
 	return result
 }
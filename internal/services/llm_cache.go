@@ -0,0 +1,44 @@
+package services
+
+import (
+	"ai-travel-planner/internal/cache"
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// NewLLMResponseCache 根据配置创建LLM响应缓存，未启用时返回 nil
+func NewLLMResponseCache(cfg *config.Config) (cache.Cache, error) {
+	cacheConfig := cfg.APIs.OpenAI.Cache
+	if !cacheConfig.Enabled {
+		return nil, nil
+	}
+
+	switch cacheConfig.Backend {
+	case "", "memory":
+		return cache.NewMemoryCache(cacheConfig.MaxEntries), nil
+	case "file":
+		return cache.NewFileCache(cacheConfig.Dir)
+	default:
+		return nil, fmt.Errorf("unknown LLM cache backend %q", cacheConfig.Backend)
+	}
+}
+
+// responseCacheKey 按规范化后的消息、模型、温度和工具定义计算缓存键；
+// 不包含 API Key，不同用户的相同请求可以共享缓存
+func responseCacheKey(baseURL string, request OpenAIRequest) string {
+	parts := []string{baseURL, request.Model, strconv.FormatFloat(request.Temperature, 'f', -1, 64)}
+	for _, message := range request.Messages {
+		parts = append(parts, message.Role, cache.NormalizeText(message.Content), message.ToolCallID)
+		if len(message.ToolCalls) > 0 {
+			calls, _ := json.Marshal(message.ToolCalls)
+			parts = append(parts, string(calls))
+		}
+	}
+	if len(request.Tools) > 0 {
+		tools, _ := json.Marshal(request.Tools)
+		parts = append(parts, string(tools))
+	}
+	return cache.Key(parts...)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"ai-travel-planner/internal/cache"
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLLMService_ResponseCache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": `{"destination":"杭州"}`}}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.APIs.OpenAI.Model = "gpt-4o-mini"
+	cfg.APIs.OpenAI.Cache.TTLSeconds = 60
+	usageService := newTestUsageService()
+	llmService := NewLLMService(cfg, nil, usageService, nil, nil, cache.NewMemoryCache(10))
+
+	meta := LLMCallMeta{UserID: "user-1"}
+	messages := []Message{{Role: "user", Content: "去杭州  玩三天"}}
+	normalized := []Message{{Role: "user", Content: " 去杭州 玩三天\n"}}
+
+	for _, m := range [][]Message{messages, normalized} {
+		if _, err := llmService.callOpenAIMessagesWithKey(meta, m, "sk-test", server.URL, ""); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected identical requests to hit the cache, got %d provider calls", calls)
+	}
+
+	meta.BypassCache = true
+	if _, err := llmService.callOpenAIMessagesWithKey(meta, messages, "sk-test", server.URL, ""); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected bypass to call the provider, got %d provider calls", calls)
+	}
+
+	if _, err := llmService.callOpenAIMessagesWithKey(LLMCallMeta{UserID: "user-1"}, messages, "sk-test", server.URL, "gpt-4o"); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected a different model to miss the cache, got %d provider calls", calls)
+	}
+
+	// 缓存命中不记录用量
+	report, _ := usageService.GetUserUsage("user-1")
+	if report.Total.Requests != calls {
+		t.Errorf("Expected %d recorded provider calls, got %d", calls, report.Total.Requests)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/fakeopenai"
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/prompts"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPipelineTestService 创建连接假 OpenAI 服务的LLM服务，使用仓库中的提示词模板和样例回复
+func newPipelineTestService(t *testing.T) (*LLMService, *UsageService, *fakeopenai.Server) {
+	t.Helper()
+	fake := fakeopenai.NewServer()
+	if err := fake.LoadFixtures("../../fixtures/fakeopenai"); err != nil {
+		t.Fatalf("LoadFixtures failed: %v", err)
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.APIs.OpenAI.BaseURL = server.URL + "/v1"
+	cfg.APIs.OpenAI.TimeoutSeconds = 1
+	store, err := prompts.NewStore(config.PromptsConfig{Dir: "../../prompts"})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	usageService := NewUsageService(cfg)
+	return NewLLMService(cfg, store, usageService, nil, NewGuardrailService(cfg), nil), usageService, fake
+}
+
+func newPipelineTestRequest() *models.CreateTravelPlanRequest {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	return &models.CreateTravelPlanRequest{
+		Title:       "杭州两日游",
+		Destination: "杭州",
+		StartDate:   models.DateOnly{Time: start},
+		EndDate:     models.DateOnly{Time: start.AddDate(0, 0, 1)},
+		Budget:      3000,
+		People:      2,
+		Preferences: &models.TripPreferences{Description: "美食"},
+	}
+}
+
+func TestLLMPipeline_GenerateTravelPlan(t *testing.T) {
+	llmService, usageService, fake := newPipelineTestService(t)
+	meta := LLMCallMeta{UserID: "user-1", PlanID: "plan-1", Locale: "zh-CN"}
+
+	result, err := llmService.GenerateTravelPlanWithKey(meta, newPipelineTestRequest(), "sk-user", "")
+	if err != nil {
+		t.Fatalf("GenerateTravelPlanWithKey failed: %v", err)
+	}
+	if len(result.Days) != 2 || result.Generator != PlanGeneratorLLM || result.PromptVersions["travel_plan"] != "v4" {
+		t.Fatalf("Unexpected result: days=%d generator=%s versions=%v", len(result.Days), result.Generator, result.PromptVersions)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 || requests[0].Authorization != "Bearer sk-user" {
+		t.Fatalf("Unexpected requests: %+v", requests)
+	}
+	if prompt := requests[0].Messages[1].Content; !strings.Contains(prompt, "杭州") || !strings.Contains(prompt, "<user_input") {
+		t.Errorf("Prompt should contain the wrapped request fields: %s", prompt)
+	}
+
+	report, _ := usageService.GetUserUsage("user-1")
+	if report.Total.Requests != 1 || report.Total.TotalTokens == 0 || report.ByEndpoint[UsageEndpointPlanGeneration] == nil {
+		t.Errorf("Usage not recorded: %+v", report.Total)
+	}
+}
+
+func TestLLMPipeline_GenerateTravelPlanInOtherLocale(t *testing.T) {
+	llmService, _, fake := newPipelineTestService(t)
+	meta := LLMCallMeta{UserID: "user-1", PlanID: "plan-1", Locale: "ja-JP"}
+
+	// 没有日语模板时使用默认模板，并明确要求输出语言和货币
+	if _, err := llmService.GenerateTravelPlanWithKey(meta, newPipelineTestRequest(), "sk-user", ""); err != nil {
+		t.Fatalf("GenerateTravelPlanWithKey failed: %v", err)
+	}
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected one request, got %d", len(requests))
+	}
+	if prompt := requests[0].Messages[1].Content; !strings.Contains(prompt, "使用 Japanese 书写") || !strings.Contains(prompt, "JPY") {
+		t.Errorf("Prompt should ask for Japanese output in JPY: %s", prompt)
+	}
+}
+
+func TestLLMPipeline_Faults(t *testing.T) {
+	llmService, usageService, fake := newPipelineTestService(t)
+	meta := LLMCallMeta{UserID: "user-1", Locale: "zh-CN"}
+
+	// 代码块包裹的回复应能正常解析
+	fake.SetFault(fakeopenai.FaultCodeFence, 0)
+	if _, err := llmService.GenerateTravelPlanWithKey(meta, newPipelineTestRequest(), "sk-user", ""); err != nil {
+		t.Errorf("Code fenced plan should parse: %v", err)
+	}
+	if fields, err := llmService.ParseVoiceToPlanFieldsWithKey(meta, "五一和家人去杭州玩两天", "sk-user", "", ""); err != nil || fields["destination"] != "杭州" {
+		t.Errorf("Code fenced voice fields should parse: %v %v", fields, err)
+	}
+
+	cases := []struct {
+		fault fakeopenai.Fault
+		want  string
+	}{
+		{fakeopenai.FaultMalformedJSON, "failed to parse LLM response"},
+		{fakeopenai.FaultRateLimit, "status 429"},
+		{fakeopenai.FaultServerError, "status 500"},
+		{fakeopenai.FaultSlow, "failed to make request"},
+	}
+	for _, tc := range cases {
+		fake.SetFault(tc.fault, 1500*time.Millisecond) // 超过 1 秒的请求超时
+		_, err := llmService.GenerateTravelPlanWithKey(meta, newPipelineTestRequest(), "sk-user", "")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Fault %s: expected error containing %q, got %v", tc.fault, tc.want, err)
+		}
+	}
+
+	// 失败的调用同样计入用量：429、500 和超时失败，格式错误的回复本身调用成功
+	report, _ := usageService.GetUserUsage("user-1")
+	if report.Total.Requests != 6 || report.Total.FailedRequests != 3 {
+		t.Errorf("Expected 6 requests with 3 failed, got %+v", report.Total)
+	}
+}
+
+func TestLLMPipeline_BudgetAnalysisAndExpenseFields(t *testing.T) {
+	llmService, _, _ := newPipelineTestService(t)
+	meta := LLMCallMeta{UserID: "user-1", Locale: "zh-CN"}
+
+	plan, expenses := newBudgetTestPlan()
+	analysis, err := llmService.AnalyzeBudgetWithKey(meta, plan, expenses, plan.StartDate.Add(36*time.Hour), "sk-user", "", "")
+	if err != nil {
+		t.Fatalf("AnalyzeBudgetWithKey failed: %v", err)
+	}
+	if analysis.Summary == "" || len(analysis.Suggestions) != 2 {
+		t.Errorf("Unexpected analysis: %+v", analysis)
+	}
+
+	fields, err := llmService.ParseVoiceToExpenseFieldsWithKey(meta, "午饭花了一百二", "sk-user", "", "")
+	if err != nil || fields["category"] != "food" {
+		t.Errorf("Unexpected expense fields: %v %v", fields, err)
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	cases := map[string]string{
+		"https://api.openai.com/v1":                         "openai",
+		"https://api.deepseek.com":                          "deepseek",
+		"https://dashscope.aliyuncs.com/compatible-mode/v1": "dashscope",
+		"http://localhost:8081/v1":                          "localhost",
+		"":                                                  "",
+	}
+	for baseURL, want := range cases {
+		if got := ProviderName(baseURL); got != want {
+			t.Errorf("ProviderName(%q) = %q, want %q", baseURL, got, want)
+		}
+	}
+}
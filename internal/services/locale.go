@@ -0,0 +1,102 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"strings"
+)
+
+// DefaultLocale 未指定语言时使用的默认语言
+const DefaultLocale = "zh-CN"
+
+// localeCurrencies 支持的语言及对应的默认货币
+var localeCurrencies = map[string]string{
+	"zh-CN": "CNY",
+	"zh-TW": "TWD",
+	"zh-HK": "HKD",
+	"en-US": "USD",
+	"en-GB": "GBP",
+	"ja-JP": "JPY",
+	"ko-KR": "KRW",
+	"fr-FR": "EUR",
+	"de-DE": "EUR",
+	"es-ES": "EUR",
+	"it-IT": "EUR",
+}
+
+// localeNames 语言的英文名称，用于翻译提示词
+var localeNames = map[string]string{
+	"zh-CN": "Simplified Chinese",
+	"zh-TW": "Traditional Chinese",
+	"zh-HK": "Traditional Chinese",
+	"en-US": "English",
+	"en-GB": "English",
+	"ja-JP": "Japanese",
+	"ko-KR": "Korean",
+	"fr-FR": "French",
+	"de-DE": "German",
+	"es-ES": "Spanish",
+	"it-IT": "Italian",
+}
+
+// NormalizeLocale 规范化语言标识（如 en_us -> en-US），不支持的语言返回 false
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.TrimSpace(strings.ReplaceAll(locale, "_", "-"))
+	if locale == "" {
+		return "", false
+	}
+	for supported := range localeCurrencies {
+		if strings.EqualFold(supported, locale) {
+			return supported, true
+		}
+	}
+	// 只给出语种时（如 "en"），选择该语种下字典序最小的地区
+	var match string
+	for supported := range localeCurrencies {
+		if strings.EqualFold(strings.SplitN(supported, "-", 2)[0], locale) && (match == "" || supported < match) {
+			match = supported
+		}
+	}
+	return match, match != ""
+}
+
+// ResolveLocale 按顺序取第一个受支持的语言，均不支持时返回默认语言
+func ResolveLocale(candidates ...string) string {
+	for _, candidate := range candidates {
+		if locale, ok := NormalizeLocale(candidate); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// CurrencyForLocale 返回语言对应的默认货币
+func CurrencyForLocale(locale string) string {
+	if currency, ok := localeCurrencies[locale]; ok {
+		return currency
+	}
+	return localeCurrencies[DefaultLocale]
+}
+
+// LocaleName 返回语言的英文名称
+func LocaleName(locale string) string {
+	if name, ok := localeNames[locale]; ok {
+		return name
+	}
+	return locale
+}
+
+// planCurrency 返回计划使用的货币，旧计划未记录时按语言推断
+func planCurrency(plan *models.TravelPlan) string {
+	if plan.Currency != "" {
+		return plan.Currency
+	}
+	return CurrencyForLocale(ResolveLocale(plan.Locale))
+}
+
+// localizedText 中文语言（或未指定语言）使用中文文案，其余语言使用英文文案
+func localizedText(locale, zh, en string) string {
+	if locale == "" || strings.HasPrefix(locale, "zh") {
+		return zh
+	}
+	return en
+}
@@ -2,11 +2,14 @@ package services
 
 import (
 	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/coord"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,14 +19,19 @@ type AmapService struct {
 	apiKey   string
 	baseURL  string
 	client   *http.Client
+	cache    *MapCache // 地理编码、POI搜索结果缓存，为 nil 时不缓存
 }
 
 // NewAmapService 创建高德地图服务
 func NewAmapService(cfg *config.Config) *AmapService {
+	baseURL := cfg.APIs.Amap.BaseURL
+	if baseURL == "" {
+		baseURL = "https://restapi.amap.com/v3"
+	}
 	return &AmapService{
 		config:  cfg,
 		apiKey:  cfg.APIs.Amap.APIKey,
-		baseURL: "https://restapi.amap.com/v3",
+		baseURL: baseURL,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -35,6 +43,41 @@ func (s *AmapService) GetApiKey() string {
 	return s.apiKey
 }
 
+// WithKey 返回使用指定API Key的高德地图服务（共享配置和HTTP客户端），apiKey 为空时返回自身
+func (s *AmapService) WithKey(apiKey string) *AmapService {
+	if s == nil || apiKey == "" || apiKey == s.apiKey {
+		return s
+	}
+	keyed := *s
+	keyed.apiKey = apiKey
+	return &keyed
+}
+
+// WithCache 返回使用查询缓存的高德地图服务，按用户Key派生的服务共享同一缓存
+func (s *AmapService) WithCache(mapCache *MapCache) *AmapService {
+	if s == nil || mapCache == nil {
+		return s
+	}
+	cached := *s
+	cached.cache = mapCache
+	return &cached
+}
+
+// Name 地图服务名称
+func (s *AmapService) Name() string {
+	return MapProviderAmap
+}
+
+// Datum 高德使用 GCJ-02 坐标系
+func (s *AmapService) Datum() string {
+	return coord.GCJ02
+}
+
+// Available 是否已配置API Key
+func (s *AmapService) Available() bool {
+	return s != nil && s.apiKey != ""
+}
+
 // GeocodeResponse 地理编码响应
 type GeocodeResponse struct {
 	Status    string   `json:"status"`
@@ -97,6 +140,46 @@ type POI struct {
 	Address  string `json:"address"`
 	Tel      string `json:"tel"`
 	Distance string `json:"distance"`
+
+	// BizExt 扩展信息（评分、人均消费），无数据时高德返回空数组，因此按原始JSON保存
+	BizExt json.RawMessage `json:"biz_ext,omitempty"`
+}
+
+// AverageCost 返回POI的人均消费，没有数据时返回 0
+func (p POI) AverageCost() float64 {
+	var ext struct {
+		Cost interface{} `json:"cost"`
+	}
+	if err := json.Unmarshal(p.BizExt, &ext); err != nil {
+		return 0
+	}
+	switch cost := ext.Cost.(type) {
+	case string:
+		value, _ := strconv.ParseFloat(cost, 64)
+		return value
+	case float64:
+		return cost
+	}
+	return 0
+}
+
+// Coordinates 解析 "经度,纬度" 格式的位置
+func (p POI) Coordinates() (float64, float64, bool) {
+	return ParseLngLat(p.Location)
+}
+
+// ParseLngLat 解析高德 "经度,纬度" 格式的坐标
+func ParseLngLat(location string) (float64, float64, bool) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lng, errLng := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLng != nil || errLat != nil {
+		return 0, 0, false
+	}
+	return lng, lat, true
 }
 
 // RouteResponse 路线规划响应
@@ -109,7 +192,10 @@ type RouteResponse struct {
 
 // RouteData 路线数据
 type RouteData struct {
-	Paths []Path `json:"paths"`
+	Paths    []Path    `json:"paths"`
+	Transits []Transit `json:"transits,omitempty"` // 公交路线方案
+	Distance AmapString `json:"distance,omitempty"` // 起终点步行距离（公交）
+	TaxiCost AmapString `json:"taxi_cost,omitempty"` // 打车费用（元）
 }
 
 // Path 路径
@@ -150,6 +236,12 @@ func (s *AmapService) Geocode(address string) (*GeocodeResponse, error) {
 		return nil, fmt.Errorf("高德地图API Key未配置")
 	}
 
+	cacheKey := geocodeCacheKey(address)
+	var cached GeocodeResponse
+	if s.cache.get(MapCacheGeocode, cacheKey, &cached) {
+		return &cached, nil
+	}
+
 	params := url.Values{}
 	params.Set("key", s.apiKey)
 	params.Set("address", address)
@@ -174,6 +266,10 @@ func (s *AmapService) Geocode(address string) (*GeocodeResponse, error) {
 		return nil, fmt.Errorf("地理编码失败: %s", result.Info)
 	}
 
+	// 没有结果的查询不缓存，地点数据更新后可以查到
+	if len(result.Geocodes) > 0 {
+		s.cache.set(MapCacheGeocode, cacheKey, &result)
+	}
 	return &result, nil
 }
 
@@ -216,6 +312,12 @@ func (s *AmapService) SearchPOI(keyword string, city string, types string) (*POI
 		return nil, fmt.Errorf("高德地图API Key未配置")
 	}
 
+	cacheKey := poiCacheKey(keyword, city, types)
+	var cached POIResponse
+	if s.cache.get(MapCachePOI, cacheKey, &cached) {
+		return &cached, nil
+	}
+
 	params := url.Values{}
 	params.Set("key", s.apiKey)
 	params.Set("keywords", keyword)
@@ -249,6 +351,56 @@ func (s *AmapService) SearchPOI(keyword string, city string, types string) (*POI
 		return nil, fmt.Errorf("POI搜索失败: %s", result.Info)
 	}
 
+	if len(result.Pois) > 0 {
+		s.cache.set(MapCachePOI, cacheKey, &result)
+	}
+	return &result, nil
+}
+
+// SearchNearby 周边搜索，结果按距离排序并分页
+func (s *AmapService) SearchNearby(search NearbySearch) (*POIResponse, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("高德地图API Key未配置")
+	}
+	search, err := search.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("key", s.apiKey)
+	params.Set("location", search.Location)
+	if search.Keyword != "" {
+		params.Set("keywords", search.Keyword)
+	}
+	if types := search.amapTypes(); types != "" {
+		params.Set("types", types)
+	}
+	params.Set("radius", strconv.Itoa(search.Radius))
+	params.Set("sortrule", "distance")
+	params.Set("offset", strconv.Itoa(search.PageSize))
+	params.Set("page", strconv.Itoa(search.Page))
+	params.Set("extensions", "all")
+
+	resp, err := s.client.Get(fmt.Sprintf("%s/place/around?%s", s.baseURL, params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result POIResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Status != "1" {
+		return nil, fmt.Errorf("周边搜索失败: %s", result.Info)
+	}
 	return &result, nil
 }
 
@@ -262,6 +414,56 @@ func (s *AmapService) WalkingRoute(origin, destination string) (*RouteResponse,
 	return s.route("walking", origin, destination)
 }
 
+// CyclingRoute 骑行路线规划（高德 v4 接口，响应格式与 v3 不同）
+func (s *AmapService) CyclingRoute(origin, destination string) (*RouteResponse, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("高德地图API Key未配置")
+	}
+
+	params := url.Values{}
+	params.Set("key", s.apiKey)
+	params.Set("origin", origin)
+	params.Set("destination", destination)
+
+	resp, err := s.client.Get(fmt.Sprintf("%s/direction/bicycling?%s", amapV4URL(s.baseURL), params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data struct {
+			Paths []Path `json:"paths"`
+		} `json:"data"`
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	if result.ErrCode != 0 {
+		return nil, fmt.Errorf("骑行路线规划失败: %s", result.ErrMsg)
+	}
+
+	response := &RouteResponse{Status: "1", Info: "OK", Count: strconv.Itoa(len(result.Data.Paths))}
+	response.Route.Paths = result.Data.Paths
+	return response, nil
+}
+
+// amapV4URL 由 v3 接口地址得到 v4 接口地址，不是以 /v3 结尾的地址（如代理、测试服务）原样使用
+func amapV4URL(baseURL string) string {
+	if strings.HasSuffix(baseURL, "/v3") {
+		return strings.TrimSuffix(baseURL, "/v3") + "/v4"
+	}
+	return baseURL
+}
+
 // TransitRoute 公交路线规划
 func (s *AmapService) TransitRoute(origin, destination string, city string) (*RouteResponse, error) {
 	if s.apiKey == "" {
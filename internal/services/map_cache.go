@@ -0,0 +1,185 @@
+package services
+
+import (
+	"ai-travel-planner/internal/cache"
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 缓存的地图查询类型
+const (
+	MapCacheGeocode = "geocode"
+	MapCachePOI     = "poi"
+)
+
+var mapCacheEndpoints = []string{MapCacheGeocode, MapCachePOI}
+
+// MapCache 高德地理编码、POI搜索结果缓存，每类查询单独存储、单独设置有效期。
+// 缓存键不包含 API Key，不同用户的相同查询共享结果；为 nil 时不缓存
+type MapCache struct {
+	endpoints map[string]*mapCacheEndpoint
+}
+
+type mapCacheEndpoint struct {
+	cache  cache.Cache
+	ttl    time.Duration
+	hits   atomic.Int64
+	misses atomic.Int64
+	purged atomic.Int64
+}
+
+// MapCacheStats 一类查询的缓存统计（进程启动以来）
+type MapCacheStats struct {
+	Endpoint   string  `json:"endpoint"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	HitRate    float64 `json:"hit_rate"`
+	Purged     int64   `json:"purged"`
+	TTLSeconds int     `json:"ttl_seconds"`
+}
+
+// NewMapCache 根据配置创建地图查询缓存，未启用时返回 nil
+func NewMapCache(cfg *config.Config) (*MapCache, error) {
+	cacheConfig := cfg.APIs.Amap.Cache
+	if !cacheConfig.Enabled {
+		return nil, nil
+	}
+
+	ttls := map[string]int{
+		MapCacheGeocode: cacheConfig.GeocodeTTLSeconds,
+		MapCachePOI:     cacheConfig.POITTLSeconds,
+	}
+	mapCache := &MapCache{endpoints: make(map[string]*mapCacheEndpoint)}
+	for _, endpoint := range mapCacheEndpoints {
+		var store cache.Cache
+		switch cacheConfig.Backend {
+		case "", "memory":
+			store = cache.NewMemoryCache(cacheConfig.MaxEntries)
+		case "file":
+			fileCache, err := cache.NewFileCache(filepath.Join(cacheConfig.Dir, endpoint))
+			if err != nil {
+				return nil, err
+			}
+			store = fileCache
+		default:
+			return nil, fmt.Errorf("unknown map cache backend %q", cacheConfig.Backend)
+		}
+		mapCache.endpoints[endpoint] = &mapCacheEndpoint{cache: store, ttl: time.Duration(ttls[endpoint]) * time.Second}
+	}
+	return mapCache, nil
+}
+
+// Stats 返回各类查询的命中统计
+func (c *MapCache) Stats() []MapCacheStats {
+	if c == nil {
+		return []MapCacheStats{}
+	}
+	stats := make([]MapCacheStats, 0, len(mapCacheEndpoints))
+	for _, name := range mapCacheEndpoints {
+		endpoint := c.endpoints[name]
+		hits, misses := endpoint.hits.Load(), endpoint.misses.Load()
+		stat := MapCacheStats{Endpoint: name, Hits: hits, Misses: misses, Purged: endpoint.purged.Load(), TTLSeconds: int(endpoint.ttl / time.Second)}
+		if hits+misses > 0 {
+			stat.HitRate = float64(hits) / float64(hits+misses)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// Purge 清空一类查询的缓存，endpoint 为空时清空全部，返回删除的条目数
+func (c *MapCache) Purge(endpoint string) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	names := mapCacheEndpoints
+	if endpoint != "" {
+		if _, ok := c.endpoints[endpoint]; !ok {
+			return 0, fmt.Errorf("unknown map cache endpoint %q", endpoint)
+		}
+		names = []string{endpoint}
+	}
+	total := 0
+	for _, name := range names {
+		removed := c.endpoints[name].cache.Clear()
+		c.endpoints[name].purged.Add(int64(removed))
+		total += removed
+	}
+	return total, nil
+}
+
+// PurgeQuery 删除一条查询的缓存（地理编码按地址，POI搜索按关键字、城市和类型），返回删除的条目数
+func (c *MapCache) PurgeQuery(endpoint, query, city, types string) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	var key string
+	switch endpoint {
+	case MapCacheGeocode:
+		key = geocodeCacheKey(query)
+	case MapCachePOI:
+		key = poiCacheKey(query, city, types)
+	default:
+		return 0, fmt.Errorf("unknown map cache endpoint %q", endpoint)
+	}
+	store := c.endpoints[endpoint].cache
+	if _, ok := store.Get(key); !ok {
+		return 0, nil
+	}
+	store.Delete(key)
+	c.endpoints[endpoint].purged.Add(1)
+	return 1, nil
+}
+
+// get 读取缓存并解析到 out，未命中时返回 false
+func (c *MapCache) get(endpoint, key string, out interface{}) bool {
+	if c == nil {
+		return false
+	}
+	e := c.endpoints[endpoint]
+	if data, ok := e.cache.Get(key); ok && json.Unmarshal(data, out) == nil {
+		e.hits.Add(1)
+		return true
+	}
+	e.misses.Add(1)
+	return false
+}
+
+// set 写入缓存
+func (c *MapCache) set(endpoint, key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	e := c.endpoints[endpoint]
+	if data, err := json.Marshal(value); err == nil {
+		e.cache.Set(key, data, e.ttl)
+	}
+}
+
+func geocodeCacheKey(address string) string {
+	return cache.Key(MapProviderAmap, MapCacheGeocode, normalizeMapQuery(address))
+}
+
+func poiCacheKey(keyword, city, types string) string {
+	return cache.Key(MapProviderAmap, MapCachePOI, normalizeMapQuery(keyword), normalizeMapQuery(city), normalizeMapQuery(types))
+}
+
+// normalizeMapQuery 规范化查询文字：全角字符转半角、合并空白、转小写，
+// 使 "西湖 " 与 "西湖"、"（北门）" 与 "(北门)" 命中同一条缓存
+func normalizeMapQuery(query string) string {
+	folded := strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, query)
+	return strings.ToLower(cache.NormalizeText(folded))
+}
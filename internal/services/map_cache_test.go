@@ -0,0 +1,106 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newCachedAmapStandIn 本地高德替身服务，"无结果" 查询返回空列表，返回请求计数
+func newCachedAmapStandIn(t *testing.T, cacheConfig config.MapCacheConfig) (*config.Config, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		query := r.URL.Query()
+		response := map[string]interface{}{"status": "1", "info": "OK"}
+		if query.Get("address") == "无结果" || query.Get("keywords") == "无结果" {
+			response["geocodes"], response["pois"] = []interface{}{}, []interface{}{}
+		} else {
+			response["geocodes"] = []map[string]string{{"formatted_address": query.Get("address"), "location": "120.13,30.24"}}
+			response["pois"] = []map[string]string{{"id": "B0001", "name": query.Get("keywords"), "location": "120.13,30.24"}}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = "system-key"
+	cfg.APIs.Amap.BaseURL = server.URL
+	cfg.APIs.Amap.Cache = cacheConfig
+	return cfg, &requests
+}
+
+func TestAmapService_Cache(t *testing.T) {
+	cfg, requests := newCachedAmapStandIn(t, config.MapCacheConfig{Enabled: true, MaxEntries: 10, GeocodeTTLSeconds: 3600, POITTLSeconds: 600})
+	mapCache, err := NewMapCache(cfg)
+	if err != nil {
+		t.Fatalf("NewMapCache failed: %v", err)
+	}
+	amap := NewAmapService(cfg).WithCache(mapCache)
+
+	first, err := amap.Geocode("杭州西湖")
+	if err != nil {
+		t.Fatalf("Geocode failed: %v", err)
+	}
+	// 空白、全角字符和用户Key不影响缓存命中
+	second, err := amap.WithKey("user-key").Geocode(" 杭州西湖　")
+	if err != nil || second.Geocodes[0].FormattedAddress != first.Geocodes[0].FormattedAddress {
+		t.Fatalf("Expected the cached geocode, got %+v, %v", second, err)
+	}
+	amap.SearchPOI("灵隐寺（北门）", "杭州", "")
+	amap.SearchPOI("灵隐寺(北门)", "杭州", "")
+	amap.SearchPOI("灵隐寺(北门)", "上海", "")
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", got)
+	}
+
+	// 没有结果的查询不缓存
+	amap.Geocode("无结果")
+	amap.Geocode("无结果")
+	if got := requests.Load(); got != 5 {
+		t.Errorf("Empty results should not be cached, got %d requests", got)
+	}
+
+	stats := mapCache.Stats()
+	if stats[0].Endpoint != MapCacheGeocode || stats[0].Hits != 1 || stats[0].Misses != 3 || stats[0].TTLSeconds != 3600 {
+		t.Errorf("Unexpected geocode stats: %+v", stats[0])
+	}
+	if stats[1].Endpoint != MapCachePOI || stats[1].Hits != 1 || stats[1].Misses != 2 || stats[1].HitRate < 0.33 || stats[1].HitRate > 0.34 {
+		t.Errorf("Unexpected POI stats: %+v", stats[1])
+	}
+
+	if purged, _ := mapCache.PurgeQuery(MapCachePOI, "灵隐寺（北门）", "杭州", ""); purged != 1 {
+		t.Errorf("Expected one POI entry purged, got %d", purged)
+	}
+	amap.SearchPOI("灵隐寺(北门)", "杭州", "")
+	if got := requests.Load(); got != 6 {
+		t.Errorf("Purged query should be fetched again, got %d requests", got)
+	}
+	if purged, _ := mapCache.Purge(""); purged != 3 {
+		t.Errorf("Expected 3 entries purged, got %d", purged)
+	}
+	if _, err := mapCache.Purge("route"); err == nil {
+		t.Errorf("Expected an error for an unknown endpoint")
+	}
+}
+
+func TestMapCache_FileBackend(t *testing.T) {
+	dir := t.TempDir()
+	cfg, requests := newCachedAmapStandIn(t, config.MapCacheConfig{Enabled: true, Backend: "file", Dir: dir, GeocodeTTLSeconds: 3600})
+	first, _ := NewMapCache(cfg)
+	NewAmapService(cfg).WithCache(first).Geocode("西湖")
+
+	// 重启后仍然命中
+	second, _ := NewMapCache(cfg)
+	if _, err := NewAmapService(cfg).WithCache(second).Geocode("西湖"); err != nil || requests.Load() != 1 {
+		t.Errorf("Expected the persisted entry to be used, got %d requests, %v", requests.Load(), err)
+	}
+
+	if mapCache, err := NewMapCache(&config.Config{}); mapCache != nil || err != nil {
+		t.Errorf("Expected no cache when disabled, got %v, %v", mapCache, err)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"strings"
+)
+
+// ActivityLngLat 活动在指定坐标系下的坐标，活动的坐标系未知时原样返回
+func ActivityLngLat(activity *models.Activity, datum string) (float64, float64) {
+	return coord.Convert(activity.Longitude, activity.Latitude, activity.CoordinateSystem, datum)
+}
+
+// ActivityLocation 活动在指定坐标系下的 "经度,纬度"
+func ActivityLocation(activity *models.Activity, datum string) string {
+	return formatLngLat(ActivityLngLat(activity, datum))
+}
+
+// ActivityInDatum 返回转换到指定坐标系的活动副本，没有坐标、坐标系未知或相同时返回原活动
+func ActivityInDatum(activity *models.Activity, datum string) *models.Activity {
+	if !hasCoordinates(activity) || activity.CoordinateSystem == "" || datum == "" || activity.CoordinateSystem == datum {
+		return activity
+	}
+	converted := *activity
+	converted.Longitude, converted.Latitude = ActivityLngLat(activity, datum)
+	converted.CoordinateSystem = datum
+	return &converted
+}
+
+// ConvertLocation 转换 "经度,纬度" 坐标，多个坐标以 | 或 ; 分隔（如距离计算的起点、路线轨迹）。
+// 不是坐标的部分（如地址）原样保留
+func ConvertLocation(location, from, to string) string {
+	if location == "" || from == "" || to == "" || from == to {
+		return location
+	}
+	groups := strings.Split(location, "|")
+	for i, group := range groups {
+		points := strings.Split(group, ";")
+		for j, point := range points {
+			if lng, lat, ok := ParseLngLat(point); ok {
+				points[j] = formatLngLat(coord.Convert(lng, lat, from, to))
+			}
+		}
+		groups[i] = strings.Join(points, ";")
+	}
+	return strings.Join(groups, "|")
+}
+
+// ConvertDatum 转换地理编码结果的坐标
+func (r *GeocodeResponse) ConvertDatum(from, to string) {
+	for i := range r.Geocodes {
+		r.Geocodes[i].Location = ConvertLocation(r.Geocodes[i].Location, from, to)
+	}
+}
+
+// ConvertDatum 转换逆地理编码结果中附近POI的坐标
+func (r *RegeocodeResponse) ConvertDatum(from, to string) {
+	convertPOIs(r.Regeocode.Pois, from, to)
+}
+
+// ConvertDatum 转换POI搜索结果的坐标
+func (r *POIResponse) ConvertDatum(from, to string) {
+	convertPOIs(r.Pois, from, to)
+}
+
+func convertPOIs(pois []POI, from, to string) {
+	for i := range pois {
+		pois[i].Location = ConvertLocation(pois[i].Location, from, to)
+	}
+}
+
+// ConvertDatum 转换路线的轨迹和公交站点坐标
+func (r *RouteResponse) ConvertDatum(from, to string) {
+	for i := range r.Route.Paths {
+		convertSteps(r.Route.Paths[i].Steps, from, to)
+	}
+	location := func(value AmapString) AmapString {
+		return AmapString(ConvertLocation(string(value), from, to))
+	}
+	stop := func(stop *TransitStop) {
+		stop.Location = location(stop.Location)
+	}
+	for i := range r.Route.Transits {
+		for j := range r.Route.Transits[i].Segments {
+			segment := &r.Route.Transits[i].Segments[j]
+			if segment.Walking != nil {
+				convertSteps(segment.Walking.Steps, from, to)
+			}
+			for k := range segment.Bus.Buslines {
+				line := &segment.Bus.Buslines[k]
+				line.Polyline = location(line.Polyline)
+				stop(&line.DepartureStop)
+				stop(&line.ArrivalStop)
+				for l := range line.ViaStops {
+					stop(&line.ViaStops[l])
+				}
+			}
+			for _, station := range []*TransitStation{segment.Entrance, segment.Exit} {
+				if station != nil {
+					station.Location = location(station.Location)
+				}
+			}
+			if segment.Railway != nil {
+				stop(&segment.Railway.DepartureStop)
+				stop(&segment.Railway.ArrivalStop)
+			}
+		}
+	}
+}
+
+func convertSteps(steps []Step, from, to string) {
+	for i := range steps {
+		steps[i].Polyline = ConvertLocation(steps[i].Polyline, from, to)
+	}
+}
+
+// ConvertDatum 转换沿途搜索结果中地点的坐标
+func (r *AlongRouteResult) ConvertDatum(from, to string) {
+	for i := range r.Pois {
+		r.Pois[i].Location = ConvertLocation(r.Pois[i].Location, from, to)
+	}
+}
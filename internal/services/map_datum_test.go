@@ -0,0 +1,99 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"testing"
+)
+
+func TestConvertLocation(t *testing.T) {
+	wgsLng, wgsLat := coord.GCJ02ToWGS84(120.153576, 30.287459)
+	expected := formatLngLat(wgsLng, wgsLat)
+	if got := ConvertLocation("120.153576,30.287459", coord.GCJ02, coord.WGS84); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got := ConvertLocation("120.153576,30.287459|120.153576,30.287459;120.153576,30.287459", coord.GCJ02, coord.WGS84); got != expected+"|"+expected+";"+expected {
+		t.Errorf("Separators should be kept, got %s", got)
+	}
+	for _, location := range []string{"杭州西湖", ""} {
+		if got := ConvertLocation(location, coord.GCJ02, coord.WGS84); got != location {
+			t.Errorf("Non-coordinate %q should be left unchanged, got %q", location, got)
+		}
+	}
+	if got := ConvertLocation("120.153576,30.287459", "", coord.WGS84); got != "120.153576,30.287459" {
+		t.Errorf("Unknown datum should be left unchanged, got %s", got)
+	}
+}
+
+func TestActivityInDatum(t *testing.T) {
+	activity := &models.Activity{Longitude: 120.153576, Latitude: 30.287459, CoordinateSystem: coord.GCJ02}
+	converted := ActivityInDatum(activity, coord.WGS84)
+	if converted == activity || converted.CoordinateSystem != coord.WGS84 || activity.CoordinateSystem != coord.GCJ02 {
+		t.Fatalf("Expected a converted copy, got %+v", converted)
+	}
+	if lng, lat := coord.GCJ02ToWGS84(120.153576, 30.287459); converted.Longitude != lng || converted.Latitude != lat {
+		t.Errorf("Unexpected converted coordinates %f,%f", converted.Longitude, converted.Latitude)
+	}
+	if ActivityInDatum(activity, coord.GCJ02) != activity || ActivityInDatum(activity, "") != activity {
+		t.Errorf("Same or empty datum should return the activity itself")
+	}
+	untagged := &models.Activity{Longitude: 120.15, Latitude: 30.28}
+	if ActivityInDatum(untagged, coord.WGS84) != untagged {
+		t.Errorf("Activities with an unknown datum should not be converted")
+	}
+}
+
+func TestRouteResponse_ConvertDatum(t *testing.T) {
+	response := &RouteResponse{}
+	response.Route.Paths = []Path{{Steps: []Step{{Polyline: "120.1,30.2;120.2,30.3"}}}}
+	response.Route.Transits = []Transit{{Segments: []TransitSegment{{
+		Bus:      TransitBus{Buslines: []Busline{{Polyline: "120.1,30.2;120.2,30.3", DepartureStop: TransitStop{Location: "120.1,30.2"}}}},
+		Entrance: &TransitStation{Location: "120.1,30.2"},
+	}}}}
+
+	response.ConvertDatum(coord.GCJ02, coord.WGS84)
+	expected := ConvertLocation("120.1,30.2", coord.GCJ02, coord.WGS84)
+	segment := response.Route.Transits[0].Segments[0]
+	if response.Route.Paths[0].Steps[0].Polyline != expected+";"+ConvertLocation("120.2,30.3", coord.GCJ02, coord.WGS84) {
+		t.Errorf("Unexpected step polyline %s", response.Route.Paths[0].Steps[0].Polyline)
+	}
+	if string(segment.Bus.Buslines[0].DepartureStop.Location) != expected || string(segment.Entrance.Location) != expected {
+		t.Errorf("Unexpected transit stops: %+v", segment)
+	}
+}
+
+func TestBuildPlanExportConvertsDatum(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, exportTestPlan)
+	for _, day := range tree.Days {
+		for _, activity := range day.Activities {
+			if hasCoordinates(activity) {
+				activity.CoordinateSystem = coord.GCJ02
+			}
+		}
+	}
+
+	var origins []string
+	provider := &stubMapProvider{minutes: func(from, to string) float64 {
+		origins = append(origins, from)
+		return 20
+	}}
+	export := travelService.BuildPlanExport(tree, provider, coord.WGS84)
+	wgsLng, wgsLat := coord.GCJ02ToWGS84(120.14, 30.25)
+	waypoint := export.Days[0].Waypoints[0]
+	if export.CoordinateSystem != coord.WGS84 || waypoint.CoordinateSystem != coord.WGS84 || waypoint.Longitude != wgsLng || waypoint.Latitude != wgsLat {
+		t.Errorf("Unexpected waypoint: %+v", waypoint)
+	}
+	if stored := tree.Days[0].Activities[0]; stored.CoordinateSystem != coord.GCJ02 || stored.Longitude != 120.14 {
+		t.Errorf("Stored activity should not change, got %+v", stored)
+	}
+	// 路线按高德的 GCJ-02 坐标查询，轨迹转换回 WGS-84
+	if len(origins) != 1 || origins[0] != "120.140000,30.250000" {
+		t.Errorf("Route should be queried in the provider datum, got %v", origins)
+	}
+	track := export.Days[0].Tracks[0]
+	if point := track.Points[0]; point[0]-wgsLng > 1e-6 || wgsLng-point[0] > 1e-6 || point[1]-wgsLat > 1e-6 || wgsLat-point[1] > 1e-6 {
+		t.Errorf("Unexpected track start %v, expected %f,%f", point, wgsLng, wgsLat)
+	}
+}
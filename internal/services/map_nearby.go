@@ -0,0 +1,298 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultNearbyRadius   = 1000
+	maxNearbyRadius       = 50000 // 高德周边搜索的最大半径
+	defaultNearbyPageSize = 20
+	maxNearbyPageSize     = 25 // 高德每页最多 25 条
+
+	defaultAlongCorridor = 500
+	maxAlongCorridor     = 5000
+	defaultAlongLimit    = 20
+	maxAlongSamples      = 10 // 沿途搜索最多查询的采样点数
+)
+
+// nearbyCategory 常用的周边搜索类别：高德 POI 分类编码和 OpenStreetMap 的搜索词
+type nearbyCategory struct {
+	amapTypes string
+	osmQuery  string
+}
+
+// nearbyCategories 周边搜索支持的类别
+var nearbyCategories = map[string]nearbyCategory{
+	"restaurant":  {amapTypes: "050000", osmQuery: "restaurant"},
+	"coffee":      {amapTypes: "050500", osmQuery: "cafe"},
+	"toilet":      {amapTypes: "200300", osmQuery: "toilets"},
+	"convenience": {amapTypes: "060200", osmQuery: "convenience store"},
+	"hotel":       {amapTypes: "100000", osmQuery: "hotel"},
+	"parking":     {amapTypes: "150900", osmQuery: "parking"},
+	"attraction":  {amapTypes: "110000", osmQuery: "attraction"},
+}
+
+// NearbyCategories 返回支持的周边搜索类别
+func NearbyCategories() []string {
+	categories := make([]string, 0, len(nearbyCategories))
+	for name := range nearbyCategories {
+		categories = append(categories, name)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// NearbySearch 周边搜索条件，Keyword、Category、Types 至少指定一个
+type NearbySearch struct {
+	Location string `json:"location"` // 中心点 "经度,纬度"
+	Keyword  string `json:"keyword"`
+	Category string `json:"category"` // restaurant, coffee, toilet 等，见 NearbyCategories
+	Types    string `json:"types"`    // 高德POI类型，如 050000|060000（仅高德支持）
+	Radius   int    `json:"radius"`   // 搜索半径（米），默认 1000，最大 50000
+	Page     int    `json:"page"`     // 页码，从 1 开始
+	PageSize int    `json:"page_size"`
+}
+
+// Normalize 校验搜索条件并补全默认的半径和分页
+func (s NearbySearch) Normalize() (NearbySearch, error) {
+	if _, _, ok := ParseLngLat(s.Location); !ok {
+		return s, fmt.Errorf("invalid location %q", s.Location)
+	}
+	s.Category = strings.ToLower(strings.TrimSpace(s.Category))
+	if s.Category != "" {
+		if _, ok := nearbyCategories[s.Category]; !ok {
+			return s, fmt.Errorf("unsupported category %q, supported: %s", s.Category, strings.Join(NearbyCategories(), ", "))
+		}
+	}
+	s.Keyword = strings.TrimSpace(s.Keyword)
+	if s.Keyword == "" && s.Category == "" && s.Types == "" {
+		return s, fmt.Errorf("keyword, category or types is required")
+	}
+	if s.Radius <= 0 {
+		s.Radius = defaultNearbyRadius
+	}
+	if s.Radius > maxNearbyRadius {
+		s.Radius = maxNearbyRadius
+	}
+	if s.Page <= 0 {
+		s.Page = 1
+	}
+	if s.PageSize <= 0 {
+		s.PageSize = defaultNearbyPageSize
+	}
+	if s.PageSize > maxNearbyPageSize {
+		s.PageSize = maxNearbyPageSize
+	}
+	return s, nil
+}
+
+// amapTypes 高德的类型参数，指定了类别时与 Types 合并
+func (s NearbySearch) amapTypes() string {
+	types := s.Types
+	if category, ok := nearbyCategories[s.Category]; ok {
+		types = strings.Trim(category.amapTypes+"|"+types, "|")
+	}
+	return types
+}
+
+// osmQuery OpenStreetMap 的搜索词，指定了类别时与关键词合并
+func (s NearbySearch) osmQuery() string {
+	query := s.Keyword
+	if category, ok := nearbyCategories[s.Category]; ok {
+		query = strings.TrimSpace(query + " " + category.osmQuery)
+	}
+	return query
+}
+
+// AlongRouteSearch 沿途搜索条件
+type AlongRouteSearch struct {
+	Keyword  string `json:"keyword"`
+	Category string `json:"category"`
+	Types    string `json:"types"`
+	Corridor int    `json:"corridor"` // 离路线的最大距离（米），默认 500
+	Limit    int    `json:"limit"`    // 最多返回的地点数，默认 20
+}
+
+// AlongRoutePOI 沿途的地点
+type AlongRoutePOI struct {
+	POI
+	OffsetMeters   int `json:"offset_meters"`   // 离路线的直线距离
+	ProgressMeters int `json:"progress_meters"` // 从起点沿路线到该地点附近的距离
+}
+
+// AlongRouteResult 沿途搜索结果，地点按在路线上的先后排序
+type AlongRouteResult struct {
+	Provider            string          `json:"provider"`
+	RouteDistanceMeters int             `json:"route_distance_meters"`
+	Corridor            int             `json:"corridor"`
+	Samples             int             `json:"samples"` // 查询的采样点数
+	Failed              int             `json:"failed"`  // 查询失败的采样点数
+	Pois                []AlongRoutePOI `json:"pois"`
+}
+
+// routePoint 路线上的点及从起点到该点的路线距离
+type routePoint struct {
+	lng, lat float64
+	progress float64
+}
+
+// SearchAlongRoute 沿路线搜索地点：在路线上等距取采样点做周边搜索，合并去重后保留离路线不超过 Corridor 的地点。
+// 路线没有轨迹时按起终点直线计算；部分采样点查询失败时返回其余结果，全部失败时返回错误
+func SearchAlongRoute(provider MapProvider, route Route, origin, destination string, search AlongRouteSearch) (*AlongRouteResult, error) {
+	if search.Corridor <= 0 {
+		search.Corridor = defaultAlongCorridor
+	}
+	if search.Corridor > maxAlongCorridor {
+		search.Corridor = maxAlongCorridor
+	}
+	if search.Limit <= 0 {
+		search.Limit = defaultAlongLimit
+	}
+
+	points := routePolyline(route)
+	if len(points) < 2 {
+		points = nil
+		for _, location := range []string{origin, destination} {
+			if lng, lat, ok := ParseLngLat(location); ok {
+				points = append(points, routePoint{lng: lng, lat: lat})
+			}
+		}
+		if len(points) < 2 {
+			return nil, fmt.Errorf("route has no geometry")
+		}
+	}
+	for i := 1; i < len(points); i++ {
+		points[i].progress = points[i-1].progress + HaversineMeters(points[i-1].lng, points[i-1].lat, points[i].lng, points[i].lat)
+	}
+	length := points[len(points)-1].progress
+
+	// 包括起终点等距采样，间隔不超过走廊宽度的两倍；路线较长时增大间隔。
+	// 搜索半径覆盖两个采样点中间、离路线 Corridor 米处的地点
+	samples := int(math.Ceil(length/(float64(search.Corridor)*2))) + 1
+	if samples > maxAlongSamples {
+		samples = maxAlongSamples
+	}
+	spacing := 0.0
+	if samples > 1 {
+		spacing = length / float64(samples-1)
+	}
+	radius := int(math.Ceil(math.Hypot(float64(search.Corridor), spacing/2)))
+
+	result := &AlongRouteResult{
+		Provider:            provider.Name(),
+		RouteDistanceMeters: int(math.Round(length)),
+		Corridor:            search.Corridor,
+		Pois:                []AlongRoutePOI{},
+	}
+	seen := map[string]bool{}
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		lng, lat := pointAt(points, float64(i)*spacing)
+		nearby, err := NearbySearch{
+			Location: formatLngLat(lng, lat),
+			Keyword:  search.Keyword,
+			Category: search.Category,
+			Types:    search.Types,
+			Radius:   radius,
+		}.Normalize()
+		if err != nil {
+			return nil, err
+		}
+		result.Samples++
+		response, err := provider.SearchNearby(nearby)
+		if err != nil {
+			result.Failed++
+			lastErr = err
+			continue
+		}
+		for _, poi := range response.Pois {
+			key := poi.ID
+			if key == "" {
+				key = poi.Name + "@" + poi.Location
+			}
+			poiLng, poiLat, ok := ParseLngLat(poi.Location)
+			if seen[key] || !ok {
+				continue
+			}
+			seen[key] = true
+			offset, progress := nearestOnRoute(points, poiLng, poiLat)
+			if offset > float64(search.Corridor) {
+				continue
+			}
+			result.Pois = append(result.Pois, AlongRoutePOI{POI: poi, OffsetMeters: int(math.Round(offset)), ProgressMeters: int(math.Round(progress))})
+		}
+	}
+	if result.Failed == result.Samples {
+		return nil, fmt.Errorf("along-route search failed: %v", lastErr)
+	}
+
+	sort.SliceStable(result.Pois, func(i, j int) bool {
+		return result.Pois[i].ProgressMeters < result.Pois[j].ProgressMeters
+	})
+	if len(result.Pois) > search.Limit {
+		result.Pois = result.Pois[:search.Limit]
+	}
+	return result, nil
+}
+
+// routePolyline 依次连接各段的轨迹，去掉相邻的重复点
+func routePolyline(route Route) []routePoint {
+	var points []routePoint
+	for _, leg := range route.Legs {
+		for _, location := range strings.Split(leg.Polyline, ";") {
+			lng, lat, ok := ParseLngLat(location)
+			if !ok {
+				continue
+			}
+			if n := len(points); n > 0 && points[n-1].lng == lng && points[n-1].lat == lat {
+				continue
+			}
+			points = append(points, routePoint{lng: lng, lat: lat})
+		}
+	}
+	return points
+}
+
+// pointAt 路线上距起点 distance 米处的坐标
+func pointAt(points []routePoint, distance float64) (float64, float64) {
+	for i := 1; i < len(points); i++ {
+		if points[i].progress < distance {
+			continue
+		}
+		segment := points[i].progress - points[i-1].progress
+		if segment <= 0 {
+			return points[i].lng, points[i].lat
+		}
+		t := (distance - points[i-1].progress) / segment
+		return points[i-1].lng + t*(points[i].lng-points[i-1].lng), points[i-1].lat + t*(points[i].lat-points[i-1].lat)
+	}
+	last := points[len(points)-1]
+	return last.lng, last.lat
+}
+
+// nearestOnRoute 地点到路线的最短距离，以及路线上最近点距起点的路线距离。
+// 每段在其起点附近按平面近似计算，城市范围内误差可以忽略
+func nearestOnRoute(points []routePoint, lng, lat float64) (float64, float64) {
+	best, progress := math.Inf(1), 0.0
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		metersPerLng := 111320 * math.Cos(a.lat*math.Pi/180)
+		const metersPerLat = 110540.0
+		bx, by := (b.lng-a.lng)*metersPerLng, (b.lat-a.lat)*metersPerLat
+		px, py := (lng-a.lng)*metersPerLng, (lat-a.lat)*metersPerLat
+
+		t := 0.0
+		if lengthSquared := bx*bx + by*by; lengthSquared > 0 {
+			t = math.Max(0, math.Min(1, (px*bx+py*by)/lengthSquared))
+		}
+		if distance := math.Hypot(px-t*bx, py-t*by); distance < best {
+			best = distance
+			progress = a.progress + t*(b.progress-a.progress)
+		}
+	}
+	return best, progress
+}
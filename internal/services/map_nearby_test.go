@@ -0,0 +1,104 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNearbySearch_Normalize(t *testing.T) {
+	search, err := NearbySearch{Location: "120.15,30.25", Category: " Coffee ", Radius: 90000, PageSize: 100}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize failed: %v", err)
+	}
+	if search.Category != "coffee" || search.Radius != maxNearbyRadius || search.Page != 1 || search.PageSize != maxNearbyPageSize {
+		t.Errorf("Unexpected normalized search: %+v", search)
+	}
+	if search.amapTypes() != "050500" || search.osmQuery() != "cafe" {
+		t.Errorf("Unexpected category mapping: %q, %q", search.amapTypes(), search.osmQuery())
+	}
+
+	invalid := []NearbySearch{
+		{Location: "西湖", Keyword: "咖啡"},
+		{Location: "120.15,30.25"},
+		{Location: "120.15,30.25", Category: "museum-shop"},
+	}
+	for _, search := range invalid {
+		if _, err := search.Normalize(); err == nil {
+			t.Errorf("Expected an error for %+v", search)
+		}
+	}
+}
+
+func TestAmapService_SearchNearby(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/place/around" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		w.Write([]byte(`{"status":"1","info":"OK","count":"42","pois":[{"id":"B1","name":"星巴克","location":"120.151,30.251","distance":"120","biz_ext":[]}]}`))
+	}))
+	defer server.Close()
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = "system-key"
+	cfg.APIs.Amap.BaseURL = server.URL
+
+	result, err := NewAmapService(cfg).SearchNearby(NearbySearch{Location: "120.15,30.25", Category: "coffee", Types: "060000", Radius: 500, Page: 3, PageSize: 10})
+	if err != nil {
+		t.Fatalf("SearchNearby failed: %v", err)
+	}
+	if result.Count != "42" || len(result.Pois) != 1 || result.Pois[0].Distance != "120" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	expected := map[string]string{"location": "120.15,30.25", "types": "050500|060000", "radius": "500", "sortrule": "distance", "offset": "10", "page": "3"}
+	for key, value := range expected {
+		if query.Get(key) != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, query.Get(key))
+		}
+	}
+}
+
+func TestSearchAlongRoute(t *testing.T) {
+	provider := &stubMapProvider{nearby: []POI{
+		{ID: "far-side", Name: "离路线太远", Location: "120.120,30.260"},
+		{ID: "late", Name: "终点附近", Location: "120.149,30.252"},
+		{ID: "early", Name: "起点附近", Location: "120.110,30.2505"},
+		{ID: "after-end", Name: "终点以后", Location: "120.200,30.250"},
+	}}
+	route := Route{Legs: []RouteLeg{
+		{Polyline: "120.10,30.25;120.12,30.25"},
+		{Polyline: "120.12,30.25;120.15,30.25"},
+	}}
+
+	result, err := SearchAlongRoute(provider, route, "", "", AlongRouteSearch{Category: "restaurant"})
+	if err != nil {
+		t.Fatalf("SearchAlongRoute failed: %v", err)
+	}
+	if len(result.Pois) != 2 || result.Pois[0].ID != "early" || result.Pois[1].ID != "late" {
+		t.Fatalf("Expected the two places along the route in order, got %+v", result.Pois)
+	}
+	if result.Pois[0].OffsetMeters < 50 || result.Pois[0].OffsetMeters > 60 || result.Pois[0].ProgressMeters < 950 || result.Pois[0].ProgressMeters > 970 {
+		t.Errorf("Unexpected offset or progress: %+v", result.Pois[0])
+	}
+	if result.RouteDistanceMeters < 4790 || result.RouteDistanceMeters > 4820 || result.Samples != 6 || result.Corridor != defaultAlongCorridor {
+		t.Errorf("Unexpected result summary: %+v", result)
+	}
+
+	// 没有轨迹时按起终点直线搜索，结果数受 Limit 限制
+	result, err = SearchAlongRoute(provider, Route{}, "120.10,30.25", "120.15,30.25", AlongRouteSearch{Keyword: "餐厅", Corridor: 2000, Limit: 1})
+	if err != nil || len(result.Pois) != 1 || result.Pois[0].ID != "early" {
+		t.Errorf("Unexpected straight-line result: %+v, %v", result, err)
+	}
+
+	if _, err := SearchAlongRoute(&stubMapProvider{err: errors.New("quota exceeded")}, route, "", "", AlongRouteSearch{Keyword: "餐厅"}); err == nil {
+		t.Errorf("Expected an error when every search fails")
+	}
+	if _, err := SearchAlongRoute(provider, Route{}, "", "", AlongRouteSearch{Keyword: "餐厅"}); err == nil {
+		t.Errorf("Expected an error without route geometry")
+	}
+}
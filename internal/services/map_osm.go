@@ -0,0 +1,534 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/coord"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// publicNominatimURL 公共 Nominatim 服务，使用政策要求每秒不超过一次请求
+const publicNominatimURL = "https://nominatim.openstreetmap.org"
+
+// OSMMapService OpenStreetMap 地图服务：Nominatim 兼容接口负责地理编码和POI搜索，OSRM 兼容接口负责路线规划和距离计算。
+// 结果转换为与高德相同的格式
+type OSMMapService struct {
+	config       *config.Config
+	nominatimURL string
+	osrmURL      string
+	userAgent    string
+	client       *http.Client
+
+	// 请求限速
+	interval    time.Duration
+	mutex       sync.Mutex
+	lastRequest time.Time
+}
+
+// NewOSMMapService 创建 OpenStreetMap 地图服务
+func NewOSMMapService(cfg *config.Config) *OSMMapService {
+	osm := cfg.APIs.OSM
+	nominatimURL := strings.TrimRight(orDefault(osm.NominatimBaseURL, publicNominatimURL), "/")
+	interval := time.Duration(osm.RequestIntervalMs) * time.Millisecond
+	if osm.RequestIntervalMs == 0 && nominatimURL == publicNominatimURL {
+		interval = time.Second
+	}
+	return &OSMMapService{
+		config:       cfg,
+		nominatimURL: nominatimURL,
+		osrmURL:      strings.TrimRight(orDefault(osm.OSRMBaseURL, "https://router.project-osrm.org"), "/"),
+		userAgent:    orDefault(osm.UserAgent, "ai-travel-planner"),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		interval: interval,
+	}
+}
+
+// Name 地图服务名称
+func (s *OSMMapService) Name() string {
+	return MapProviderOSM
+}
+
+// Datum OpenStreetMap 使用 WGS-84 坐标系
+func (s *OSMMapService) Datum() string {
+	return coord.WGS84
+}
+
+// Available 是否启用
+func (s *OSMMapService) Available() bool {
+	return s != nil && !s.config.APIs.OSM.Disabled
+}
+
+// nominatimPlace Nominatim 搜索和逆地理编码结果（format=jsonv2）
+type nominatimPlace struct {
+	PlaceID     int64            `json:"place_id"`
+	OSMType     string           `json:"osm_type"`
+	OSMID       int64            `json:"osm_id"`
+	Lat         string           `json:"lat"`
+	Lon         string           `json:"lon"`
+	Name        string           `json:"name"`
+	DisplayName string           `json:"display_name"`
+	Category    string           `json:"category"`
+	Type        string           `json:"type"`
+	Address     nominatimAddress `json:"address"`
+	Error       string           `json:"error"`
+}
+
+// nominatimAddress Nominatim 地址明细（addressdetails=1）
+type nominatimAddress struct {
+	Country      string `json:"country"`
+	CountryCode  string `json:"country_code"`
+	State        string `json:"state"`
+	City         string `json:"city"`
+	Town         string `json:"town"`
+	Village      string `json:"village"`
+	County       string `json:"county"`
+	CityDistrict string `json:"city_district"`
+	Suburb       string `json:"suburb"`
+	Road         string `json:"road"`
+	Postcode     string `json:"postcode"`
+}
+
+func (a nominatimAddress) city() string {
+	return orDefault(a.City, orDefault(a.Town, a.Village))
+}
+
+func (a nominatimAddress) district() string {
+	return orDefault(a.CityDistrict, orDefault(a.Suburb, a.County))
+}
+
+func (p nominatimPlace) location() string {
+	return p.Lon + "," + p.Lat
+}
+
+// poi 转换为高德格式的POI，ID 为 "osm:<类型>/<ID>"
+func (p nominatimPlace) poi() POI {
+	name := p.Name
+	if name == "" {
+		name = strings.TrimSpace(strings.Split(p.DisplayName, ",")[0])
+	}
+	return POI{
+		ID:       fmt.Sprintf("osm:%s/%d", p.OSMType, p.OSMID),
+		Name:     name,
+		Type:     strings.Trim(p.Category+";"+p.Type, ";"),
+		Location: p.location(),
+		Address:  p.DisplayName,
+	}
+}
+
+// Geocode 地理编码：地址转坐标
+func (s *OSMMapService) Geocode(address string) (*GeocodeResponse, error) {
+	places, err := s.search(address, 5, nil)
+	if err != nil {
+		return nil, fmt.Errorf("地理编码失败: %v", err)
+	}
+
+	result := &GeocodeResponse{Status: "1", Info: "OK", Count: strconv.Itoa(len(places)), Geocodes: []Geocode{}}
+	for _, place := range places {
+		result.Geocodes = append(result.Geocodes, Geocode{
+			FormattedAddress: place.DisplayName,
+			Country:          place.Address.Country,
+			Province:         place.Address.State,
+			City:             place.Address.city(),
+			District:         place.Address.district(),
+			Location:         place.location(),
+			Level:            place.Type,
+		})
+	}
+	return result, nil
+}
+
+// Regeocode 逆地理编码：坐标转地址
+func (s *OSMMapService) Regeocode(longitude, latitude string) (*RegeocodeResponse, error) {
+	params := url.Values{}
+	params.Set("lat", latitude)
+	params.Set("lon", longitude)
+	params.Set("format", "jsonv2")
+	params.Set("addressdetails", "1")
+
+	var place nominatimPlace
+	if err := s.getJSON(fmt.Sprintf("%s/reverse?%s", s.nominatimURL, params.Encode()), &place); err != nil {
+		return nil, fmt.Errorf("逆地理编码失败: %v", err)
+	}
+	if place.Error != "" {
+		return nil, fmt.Errorf("逆地理编码失败: %s", place.Error)
+	}
+
+	return &RegeocodeResponse{
+		Status: "1",
+		Info:   "OK",
+		Regeocode: Regeocode{
+			FormattedAddress: place.DisplayName,
+			AddressComponent: AddressComponent{
+				Country:  place.Address.Country,
+				Province: place.Address.State,
+				City:     place.Address.city(),
+				District: place.Address.district(),
+				Township: place.Address.Suburb,
+				Street:   place.Address.Road,
+			},
+		},
+	}, nil
+}
+
+// SearchPOI POI搜索。Nominatim 不支持高德的POI类型过滤，types 被忽略
+func (s *OSMMapService) SearchPOI(keyword string, city string, types string) (*POIResponse, error) {
+	query := keyword
+	if city != "" {
+		query = keyword + ", " + city
+	}
+	places, err := s.search(query, 20, nil)
+	if err != nil {
+		return nil, fmt.Errorf("POI搜索失败: %v", err)
+	}
+
+	result := &POIResponse{Status: "1", Info: "OK", Count: strconv.Itoa(len(places)), Pois: []POI{}}
+	for _, place := range places {
+		result.Pois = append(result.Pois, place.poi())
+	}
+	return result, nil
+}
+
+// SearchNearby 周边搜索：在半径对应的范围内搜索，按距离过滤、排序后分页。
+// Nominatim 不支持分页，因此每次取前面所有页的结果再截取
+func (s *OSMMapService) SearchNearby(search NearbySearch) (*POIResponse, error) {
+	search, err := search.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	query := search.osmQuery()
+	if query == "" {
+		return nil, fmt.Errorf("周边搜索失败: OpenStreetMap 需要关键词或类别")
+	}
+	lng, lat, _ := ParseLngLat(search.Location)
+
+	// 搜索范围为包含圆形区域的矩形
+	latDelta := float64(search.Radius) / 110540
+	lngDelta := float64(search.Radius) / (111320 * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	params := url.Values{}
+	params.Set("viewbox", fmt.Sprintf("%f,%f,%f,%f", lng-lngDelta, lat+latDelta, lng+lngDelta, lat-latDelta))
+	params.Set("bounded", "1")
+	limit := search.Page * search.PageSize
+	if limit > 50 {
+		limit = 50 // Nominatim 单次最多返回 50 条
+	}
+	places, err := s.search(query, limit, params)
+	if err != nil {
+		return nil, fmt.Errorf("周边搜索失败: %v", err)
+	}
+
+	type nearbyPOI struct {
+		poi      POI
+		distance float64
+	}
+	var nearby []nearbyPOI
+	for _, place := range places {
+		poi := place.poi()
+		poiLng, poiLat, ok := ParseLngLat(poi.Location)
+		if !ok {
+			continue
+		}
+		distance := HaversineMeters(lng, lat, poiLng, poiLat)
+		if distance > float64(search.Radius) {
+			continue
+		}
+		poi.Distance = formatRounded(distance)
+		nearby = append(nearby, nearbyPOI{poi: poi, distance: distance})
+	}
+	sort.SliceStable(nearby, func(i, j int) bool { return nearby[i].distance < nearby[j].distance })
+
+	result := &POIResponse{Status: "1", Info: "OK", Count: strconv.Itoa(len(nearby)), Pois: []POI{}}
+	for i := (search.Page - 1) * search.PageSize; i < len(nearby) && i < search.Page*search.PageSize; i++ {
+		result.Pois = append(result.Pois, nearby[i].poi)
+	}
+	return result, nil
+}
+
+// search 调用 Nominatim 搜索，extra 为额外的查询参数（如 viewbox）
+func (s *OSMMapService) search(query string, limit int, extra url.Values) ([]nominatimPlace, error) {
+	params := url.Values{}
+	for key, values := range extra {
+		params[key] = values
+	}
+	params.Set("q", query)
+	params.Set("format", "jsonv2")
+	params.Set("addressdetails", "1")
+	params.Set("limit", strconv.Itoa(limit))
+
+	var places []nominatimPlace
+	if err := s.getJSON(fmt.Sprintf("%s/search?%s", s.nominatimURL, params.Encode()), &places); err != nil {
+		return nil, err
+	}
+	return places, nil
+}
+
+// osrmRouteResponse OSRM 路线规划响应（steps=true&geometries=geojson）
+type osrmRouteResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		Distance float64 `json:"distance"`
+		Duration float64 `json:"duration"`
+		Legs     []struct {
+			Steps []osrmStep `json:"steps"`
+		} `json:"legs"`
+	} `json:"routes"`
+}
+
+// osrmStep OSRM 路线中的一步
+type osrmStep struct {
+	Distance float64 `json:"distance"`
+	Duration float64 `json:"duration"`
+	Name     string  `json:"name"`
+	Geometry struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Maneuver struct {
+		Type     string `json:"type"`
+		Modifier string `json:"modifier"`
+	} `json:"maneuver"`
+}
+
+// instruction 生成步骤说明，如 "turn left onto Main Street"
+func (step osrmStep) instruction() string {
+	instruction := strings.TrimSpace(step.Maneuver.Type + " " + step.Maneuver.Modifier)
+	if step.Name != "" {
+		instruction += " onto " + step.Name
+	}
+	return instruction
+}
+
+// polyline 转换为高德格式的 "经度,纬度;经度,纬度"
+func (step osrmStep) polyline() string {
+	var points []string
+	for _, point := range step.Geometry.Coordinates {
+		if len(point) >= 2 {
+			points = append(points, formatLngLat(point[0], point[1]))
+		}
+	}
+	return strings.Join(points, ";")
+}
+
+// DrivingRoute 驾车路线规划
+func (s *OSMMapService) DrivingRoute(origin, destination string) (*RouteResponse, error) {
+	return s.route("driving", origin, destination)
+}
+
+// WalkingRoute 步行路线规划
+func (s *OSMMapService) WalkingRoute(origin, destination string) (*RouteResponse, error) {
+	return s.route("foot", origin, destination)
+}
+
+// CyclingRoute 骑行路线规划
+func (s *OSMMapService) CyclingRoute(origin, destination string) (*RouteResponse, error) {
+	return s.route("bike", origin, destination)
+}
+
+// TransitRoute OSRM 没有公交数据，不支持公交路线规划
+func (s *OSMMapService) TransitRoute(origin, destination string, city string) (*RouteResponse, error) {
+	return nil, fmt.Errorf("OpenStreetMap 地图服务不支持公交路线规划")
+}
+
+// route 调用 OSRM 路线规划，profile 为 driving、foot 或 bike
+func (s *OSMMapService) route(profile, origin, destination string) (*RouteResponse, error) {
+	from, err := s.resolveLocation(origin)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.resolveLocation(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var result osrmRouteResponse
+	endpoint := fmt.Sprintf("%s/route/v1/%s/%s;%s?steps=true&geometries=geojson&overview=false", s.osrmURL, profile, from, to)
+	if err := s.getJSON(endpoint, &result); err != nil {
+		return nil, fmt.Errorf("路线规划失败: %v", err)
+	}
+	if result.Code != "Ok" {
+		return nil, fmt.Errorf("路线规划失败: %s", orDefault(result.Message, result.Code))
+	}
+
+	response := &RouteResponse{Status: "1", Info: "OK", Count: strconv.Itoa(len(result.Routes))}
+	for _, route := range result.Routes {
+		path := Path{
+			Distance: formatRounded(route.Distance),
+			Duration: formatRounded(route.Duration),
+		}
+		for _, leg := range route.Legs {
+			for _, step := range leg.Steps {
+				path.Steps = append(path.Steps, Step{
+					Instruction: step.instruction(),
+					Road:        step.Name,
+					Distance:    formatRounded(step.Distance),
+					Duration:    formatRounded(step.Duration),
+					Polyline:    step.polyline(),
+					Action:      step.Maneuver.Type,
+				})
+			}
+		}
+		response.Route.Paths = append(response.Route.Paths, path)
+	}
+	return response, nil
+}
+
+// osrmTableResponse OSRM 距离矩阵响应
+type osrmTableResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Distances [][]*float64 `json:"distances"`
+	Durations [][]*float64 `json:"durations"`
+}
+
+// CalculateDistance 计算多个起点到一个终点的距离，mode 与高德一致：0 直线距离，1 驾车距离（默认），3 步行距离
+func (s *OSMMapService) CalculateDistance(origins, destinations string, mode string) (*DistanceResponse, error) {
+	var points []string
+	for _, origin := range strings.Split(origins, "|") {
+		point, err := s.resolveLocation(origin)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	destination, err := s.resolveLocation(strings.Split(destinations, "|")[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DistanceResponse{Status: "1", Info: "OK"}
+	if mode == "0" {
+		toLng, toLat, _ := ParseLngLat(destination)
+		for i, point := range points {
+			lng, lat, _ := ParseLngLat(point)
+			result.Results = append(result.Results, DistanceResult{
+				OriginID: strconv.Itoa(i + 1),
+				DestID:   "1",
+				Distance: formatRounded(HaversineMeters(lng, lat, toLng, toLat)),
+				Duration: "0",
+			})
+		}
+		return result, nil
+	}
+
+	profile := "driving"
+	if mode == "3" {
+		profile = "foot"
+	}
+	sources := make([]string, len(points))
+	for i := range points {
+		sources[i] = strconv.Itoa(i)
+	}
+	endpoint := fmt.Sprintf("%s/table/v1/%s/%s;%s?sources=%s&destinations=%d&annotations=distance,duration",
+		s.osrmURL, profile, strings.Join(points, ";"), destination, strings.Join(sources, ";"), len(points))
+
+	var table osrmTableResponse
+	if err := s.getJSON(endpoint, &table); err != nil {
+		return nil, fmt.Errorf("距离计算失败: %v", err)
+	}
+	if table.Code != "Ok" {
+		return nil, fmt.Errorf("距离计算失败: %s", orDefault(table.Message, table.Code))
+	}
+	for i := range points {
+		var distance, duration float64
+		if i < len(table.Distances) && len(table.Distances[i]) > 0 && table.Distances[i][0] != nil {
+			distance = *table.Distances[i][0]
+		}
+		if i < len(table.Durations) && len(table.Durations[i]) > 0 && table.Durations[i][0] != nil {
+			duration = *table.Durations[i][0]
+		}
+		result.Results = append(result.Results, DistanceResult{
+			OriginID: strconv.Itoa(i + 1),
+			DestID:   "1",
+			Distance: formatRounded(distance),
+			Duration: formatRounded(duration),
+		})
+	}
+	return result, nil
+}
+
+// resolveLocation 将 "经度,纬度" 或地址转换为 OSRM 使用的 "经度,纬度"
+func (s *OSMMapService) resolveLocation(location string) (string, error) {
+	location = strings.TrimSpace(location)
+	if lng, lat, ok := ParseLngLat(location); ok {
+		return formatLngLat(lng, lat), nil
+	}
+	geocode, err := s.Geocode(location)
+	if err != nil {
+		return "", err
+	}
+	if len(geocode.Geocodes) == 0 {
+		return "", fmt.Errorf("地址未找到: %s", location)
+	}
+	return geocode.Geocodes[0].Location, nil
+}
+
+// getJSON 发送 GET 请求并解析JSON，Nominatim 要求带 User-Agent
+func (s *OSMMapService) getJSON(endpoint string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "application/json")
+	s.wait()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// OSRM 的错误响应（如 NoRoute）同样是JSON，交给调用方按 code 处理
+	if resp.StatusCode >= 500 || (resp.StatusCode >= 300 && !json.Valid(body)) {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, target)
+}
+
+// wait 按配置的最小间隔依次发出请求
+func (s *OSMMapService) wait() {
+	if s.interval <= 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if elapsed := time.Since(s.lastRequest); elapsed < s.interval {
+		time.Sleep(s.interval - elapsed)
+	}
+	s.lastRequest = time.Now()
+}
+
+// formatLngLat 格式化为 "经度,纬度"，保留6位小数
+func formatLngLat(lng, lat float64) string {
+	return strconv.FormatFloat(lng, 'f', 6, 64) + "," + strconv.FormatFloat(lat, 'f', 6, 64)
+}
+
+// formatRounded 将距离（米）或时间（秒）取整后格式化为字符串，与高德一致
+func formatRounded(value float64) string {
+	return strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+}
+
+// HaversineMeters 两个经纬度之间的球面直线距离（米）
+func HaversineMeters(lng1, lat1, lng2, lat2 float64) float64 {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"ai-travel-planner/internal/coord"
+	"strings"
+	"unicode"
+)
+
+// MapProvider 地图服务。各实现统一返回高德格式的结果（坐标为 "经度,纬度"），
+// 坐标使用各自的坐标系（高德为 GCJ-02，OpenStreetMap 为 WGS-84），与其他坐标混用前需按 Datum 转换
+type MapProvider interface {
+	Name() string
+	Available() bool // 是否已配置可用
+	Datum() string   // 输入和返回的坐标所用的坐标系，见 coord 包
+	Geocode(address string) (*GeocodeResponse, error)
+	Regeocode(longitude, latitude string) (*RegeocodeResponse, error)
+	SearchPOI(keyword string, city string, types string) (*POIResponse, error)
+	SearchNearby(search NearbySearch) (*POIResponse, error)
+	DrivingRoute(origin, destination string) (*RouteResponse, error)
+	WalkingRoute(origin, destination string) (*RouteResponse, error)
+	CyclingRoute(origin, destination string) (*RouteResponse, error)
+	TransitRoute(origin, destination string, city string) (*RouteResponse, error)
+	CalculateDistance(origins, destinations string, mode string) (*DistanceResponse, error)
+}
+
+// 地图服务名称
+const (
+	MapProviderAmap = "amap"
+	MapProviderOSM  = "osm"
+)
+
+// 地图服务API Key的来源
+const (
+	MapKeySourceUser   = "user"   // 用户在设置中保存的Key
+	MapKeySourceSystem = "system" // 配置文件中的Key
+	MapKeySourceNone   = "none"   // 没有可用的Key，或使用的地图服务不需要Key
+)
+
+// MapRouter 按目的地所在国家选择地图服务：中国境内使用高德，其余使用 OpenStreetMap。
+// 首选的服务未配置时改用另一个
+type MapRouter struct {
+	amap      *AmapService
+	osm       *OSMMapService
+	keySource string // 高德Key的来源
+}
+
+// NewMapRouter 创建地图服务路由
+func NewMapRouter(amap *AmapService, osm *OSMMapService) *MapRouter {
+	return &MapRouter{amap: amap, osm: osm, keySource: MapKeySourceSystem}
+}
+
+// WithAmapKey 返回使用用户高德Key的路由，apiKey 为空时返回自身（使用系统Key）
+func (r *MapRouter) WithAmapKey(apiKey string) *MapRouter {
+	if apiKey == "" || r.amap == nil {
+		return r
+	}
+	return &MapRouter{amap: r.amap.WithKey(apiKey), osm: r.osm, keySource: MapKeySourceUser}
+}
+
+// Amap 返回高德地图服务（前端SDK的Key、离线规划等高德专用功能）
+func (r *MapRouter) Amap() *AmapService {
+	return r.amap
+}
+
+// KeySource 返回地图服务实际使用的Key来源
+func (r *MapRouter) KeySource(provider MapProvider) string {
+	if provider == nil || provider.Name() != MapProviderAmap || !provider.Available() {
+		return MapKeySourceNone
+	}
+	return r.keySource
+}
+
+// ForCountry 按国家选择地图服务，country 为 ISO 3166-1 代码或国家名，为空时按中国处理
+func (r *MapRouter) ForCountry(country string) MapProvider {
+	return r.pick(country == "" || isChinaCountry(country))
+}
+
+// ForDestination 按目的地名称选择地图服务
+func (r *MapRouter) ForDestination(destination string) MapProvider {
+	return r.ForCountry(DestinationCountry(destination))
+}
+
+// ForQuery 选择地图服务：给出国家时按国家，否则按地址或 "经度,纬度" 坐标判断
+func (r *MapRouter) ForQuery(query, country string) MapProvider {
+	if country != "" {
+		return r.ForCountry(country)
+	}
+	// 多个坐标用 | 分隔时按第一个判断
+	first := strings.TrimSpace(strings.Split(query, "|")[0])
+	if lng, lat, ok := ParseLngLat(first); ok {
+		return r.pick(coord.InChina(lng, lat))
+	}
+	return r.ForDestination(query)
+}
+
+func (r *MapRouter) pick(china bool) MapProvider {
+	amapAvailable := r.amap != nil && r.amap.Available()
+	osmAvailable := r.osm != nil && r.osm.Available()
+	if (china && amapAvailable) || !osmAvailable {
+		return r.amap
+	}
+	return r.osm
+}
+
+// isChinaCountry 判断国家代码或名称是否为中国大陆
+func isChinaCountry(country string) bool {
+	switch strings.ToLower(strings.TrimSpace(country)) {
+	case "cn", "chn", "china", "中国", "中华人民共和国":
+		return true
+	}
+	return false
+}
+
+// foreignDestinations 常见境外目的地的中文名及所属国家/地区，中文名无法按文字判断国家
+var foreignDestinations = map[string]string{
+	"香港": "HK", "澳门": "MO", "台湾": "TW", "台北": "TW", "高雄": "TW",
+	"日本": "JP", "东京": "JP", "大阪": "JP", "京都": "JP", "奈良": "JP", "北海道": "JP", "札幌": "JP", "冲绳": "JP", "名古屋": "JP", "福冈": "JP",
+	"韩国": "KR", "首尔": "KR", "釜山": "KR", "济州": "KR",
+	"泰国": "TH", "曼谷": "TH", "清迈": "TH", "普吉": "TH", "芭提雅": "TH",
+	"新加坡": "SG", "马来西亚": "MY", "吉隆坡": "MY", "槟城": "MY", "越南": "VN", "河内": "VN", "胡志明": "VN", "岘港": "VN",
+	"印尼": "ID", "印度尼西亚": "ID", "巴厘岛": "ID", "菲律宾": "PH", "马尼拉": "PH", "宿务": "PH", "柬埔寨": "KH", "吴哥": "KH",
+	"马尔代夫": "MV", "斯里兰卡": "LK", "印度": "IN", "尼泊尔": "NP", "迪拜": "AE", "阿联酋": "AE", "土耳其": "TR", "伊斯坦布尔": "TR", "埃及": "EG",
+	"英国": "GB", "伦敦": "GB", "法国": "FR", "巴黎": "FR", "意大利": "IT", "罗马": "IT", "米兰": "IT", "威尼斯": "IT", "佛罗伦萨": "IT",
+	"德国": "DE", "柏林": "DE", "慕尼黑": "DE", "西班牙": "ES", "巴塞罗那": "ES", "马德里": "ES", "瑞士": "CH", "荷兰": "NL", "阿姆斯特丹": "NL",
+	"希腊": "GR", "雅典": "GR", "俄罗斯": "RU", "莫斯科": "RU", "冰岛": "IS",
+	"美国": "US", "纽约": "US", "洛杉矶": "US", "旧金山": "US", "夏威夷": "US", "拉斯维加斯": "US", "加拿大": "CA", "温哥华": "CA", "多伦多": "CA",
+	"澳大利亚": "AU", "澳洲": "AU", "悉尼": "AU", "墨尔本": "AU", "新西兰": "NZ",
+}
+
+// CountryUnknown 无法判断的境外目的地，ISO 3166 中表示未知地区的代码
+const CountryUnknown = "ZZ"
+
+// DestinationCountry 粗略判断目的地所在国家/地区：先查常见境外目的地，其余含汉字的按中国处理，
+// 不含汉字又无法判断的（如 "Paris"）按境外处理并返回 CountryUnknown，目的地为空时返回空
+func DestinationCountry(destination string) string {
+	destination = strings.TrimSpace(destination)
+	if destination == "" {
+		return ""
+	}
+	// 取最长的匹配，避免"印度尼西亚"匹配到"印度"
+	var matched, matchedCountry string
+	for name, country := range foreignDestinations {
+		if len(name) > len(matched) && strings.Contains(destination, name) {
+			matched, matchedCountry = name, country
+		}
+	}
+	if matchedCountry != "" {
+		return matchedCountry
+	}
+	for _, r := range destination {
+		if unicode.Is(unicode.Han, r) {
+			return "CN"
+		}
+	}
+	if strings.Contains(strings.ToLower(destination), "china") {
+		return "CN"
+	}
+	return CountryUnknown
+}
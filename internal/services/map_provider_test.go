@@ -0,0 +1,173 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDestinationCountry(t *testing.T) {
+	cases := map[string]string{
+		"杭州":             "CN",
+		"北京市":            "CN",
+		"东京":             "JP",
+		"日本大阪":           "JP",
+		"印度尼西亚巴厘岛":       "ID",
+		"香港":             "HK",
+		"Paris":          CountryUnknown,
+		"":               "",
+		"Beijing, China": "CN",
+	}
+	for destination, expected := range cases {
+		if country := DestinationCountry(destination); country != expected {
+			t.Errorf("DestinationCountry(%q) = %q, expected %q", destination, country, expected)
+		}
+	}
+}
+
+func TestMapRouter(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = "amap-key"
+	router := NewMapRouter(NewAmapService(cfg), NewOSMMapService(cfg))
+
+	if name := router.ForDestination("杭州").Name(); name != MapProviderAmap {
+		t.Errorf("Expected amap for 杭州, got %s", name)
+	}
+	if name := router.ForDestination("东京").Name(); name != MapProviderOSM {
+		t.Errorf("Expected osm for 东京, got %s", name)
+	}
+	if name := router.ForDestination("Paris").Name(); name != MapProviderOSM {
+		t.Errorf("Expected osm for Paris, got %s", name)
+	}
+	if name := router.ForQuery("2.35,48.86|2.29,48.85", "").Name(); name != MapProviderOSM {
+		t.Errorf("Expected osm for coordinates in Paris, got %s", name)
+	}
+	if name := router.ForQuery("Paris", "CN").Name(); name != MapProviderAmap {
+		t.Errorf("An explicit country should win, got %s", name)
+	}
+
+	// 没有高德Key时中国目的地也使用 OpenStreetMap；关闭 OpenStreetMap 时全部使用高德
+	noAmap := NewMapRouter(NewAmapService(&config.Config{}), NewOSMMapService(&config.Config{}))
+	if name := noAmap.ForDestination("杭州").Name(); name != MapProviderOSM {
+		t.Errorf("Expected osm without an Amap key, got %s", name)
+	}
+	cfg.APIs.OSM.Disabled = true
+	if name := router.ForDestination("巴黎").Name(); name != MapProviderAmap {
+		t.Errorf("Expected amap when OSM is disabled, got %s", name)
+	}
+}
+
+// newOSMStandIn 本地的 Nominatim/OSRM 替身服务
+func newOSMStandIn(t *testing.T) (*OSMMapService, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/search":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"osm_type": "way", "osm_id": 5013364, "lat": "48.8582599", "lon": "2.2945006",
+				"name": "Tour Eiffel", "display_name": "Tour Eiffel, Avenue Gustave Eiffel, Paris, France",
+				"category": "tourism", "type": "attraction",
+				"address": map[string]string{"country": "France", "state": "Île-de-France", "city": "Paris", "suburb": "Gros-Caillou"},
+			}})
+		case r.URL.Path == "/reverse":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"display_name": "Louvre, Paris, France",
+				"address":      map[string]string{"country": "France", "city": "Paris", "road": "Rue de Rivoli"},
+			})
+		case strings.HasPrefix(r.URL.Path, "/route/v1/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": "Ok",
+				"routes": []map[string]interface{}{{
+					"distance": 3210.4, "duration": 2400.6,
+					"legs": []map[string]interface{}{{"steps": []map[string]interface{}{{
+						"distance": 3210.4, "duration": 2400.6, "name": "Quai Branly",
+						"geometry": map[string]interface{}{"coordinates": [][]float64{{2.2945, 48.8582}, {2.3376, 48.8606}}},
+						"maneuver": map[string]string{"type": "depart", "modifier": "left"},
+					}}}},
+				}},
+			})
+		case strings.HasPrefix(r.URL.Path, "/table/v1/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": "Ok", "distances": [][]float64{{3500}, {1200}}, "durations": [][]float64{{600}, {240}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.APIs.OSM.NominatimBaseURL = server.URL
+	cfg.APIs.OSM.OSRMBaseURL = server.URL
+	cfg.APIs.OSM.UserAgent = "planner-test"
+	return NewOSMMapService(cfg), &requests
+}
+
+func TestOSMMapService(t *testing.T) {
+	osm, requests := newOSMStandIn(t)
+
+	geocode, err := osm.Geocode("Tour Eiffel")
+	if err != nil {
+		t.Fatalf("Geocode failed: %v", err)
+	}
+	if geocode.Status != "1" || len(geocode.Geocodes) != 1 || geocode.Geocodes[0].Location != "2.2945006,48.8582599" || geocode.Geocodes[0].City != "Paris" {
+		t.Errorf("Unexpected geocode result: %+v", geocode)
+	}
+	if agent := (*requests)[0].Header.Get("User-Agent"); agent != "planner-test" {
+		t.Errorf("Expected the configured User-Agent, got %q", agent)
+	}
+
+	pois, err := osm.SearchPOI("Tour Eiffel", "Paris", "")
+	if err != nil {
+		t.Fatalf("SearchPOI failed: %v", err)
+	}
+	if len(pois.Pois) != 1 || pois.Pois[0].ID != "osm:way/5013364" || pois.Pois[0].Name != "Tour Eiffel" {
+		t.Errorf("Unexpected POIs: %+v", pois.Pois)
+	}
+	if q := (*requests)[1].URL.Query().Get("q"); q != "Tour Eiffel, Paris" {
+		t.Errorf("Expected the city in the query, got %q", q)
+	}
+
+	regeocode, err := osm.Regeocode("2.3376", "48.8606")
+	if err != nil || regeocode.Regeocode.AddressComponent.Street != "Rue de Rivoli" {
+		t.Errorf("Unexpected regeocode result: %+v, %v", regeocode, err)
+	}
+
+	// 地址作为起点时先地理编码
+	route, err := osm.WalkingRoute("Tour Eiffel", "2.3376,48.8606")
+	if err != nil {
+		t.Fatalf("WalkingRoute failed: %v", err)
+	}
+	path := route.Route.Paths[0]
+	if path.Distance != "3210" || path.Duration != "2401" || path.Steps[0].Polyline != "2.294500,48.858200;2.337600,48.860600" {
+		t.Errorf("Unexpected route: %+v", path)
+	}
+	if last := (*requests)[len(*requests)-1].URL.Path; !strings.HasPrefix(last, "/route/v1/foot/2.2945006,48.8582599;2.337600,48.860600") {
+		t.Errorf("Unexpected OSRM request path %s", last)
+	}
+
+	if _, err := osm.TransitRoute("2.29,48.85", "2.33,48.86", "Paris"); err == nil {
+		t.Errorf("Transit routing should not be supported")
+	}
+
+	distance, err := osm.CalculateDistance("2.2945,48.8582|2.3376,48.8606", "2.3522,48.8566", "1")
+	if err != nil {
+		t.Fatalf("CalculateDistance failed: %v", err)
+	}
+	if len(distance.Results) != 2 || distance.Results[1].Distance != "1200" || distance.Results[1].OriginID != "2" {
+		t.Errorf("Unexpected distance results: %+v", distance.Results)
+	}
+
+	requestCount := len(*requests)
+	straight, _ := osm.CalculateDistance("2.2945,48.8582", "2.3522,48.8566", "0")
+	if meters, _ := strconv.Atoi(straight.Results[0].Distance); meters < 4200 || meters > 4250 || len(*requests) != requestCount {
+		t.Errorf("Straight-line distance should be computed locally, got %d", meters)
+	}
+}
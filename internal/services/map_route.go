@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 路线出行方式
+const (
+	RouteModeDriving = "driving"
+	RouteModeWalking = "walking"
+	RouteModeCycling = "cycling"
+	RouteModeTransit = "transit"
+)
+
+// 公交路线中乘车段的方式
+const (
+	LegModeBus     = "bus"
+	LegModeSubway  = "subway"
+	LegModeRailway = "railway"
+)
+
+// ErrUnsupportedRouteMode 不支持的路线方式
+var ErrUnsupportedRouteMode = errors.New("unsupported route mode")
+
+// PlanRoute 按出行方式规划路线，city 仅公交路线需要
+func PlanRoute(provider MapProvider, mode, origin, destination, city string) (*RouteResponse, error) {
+	switch mode {
+	case RouteModeDriving:
+		return provider.DrivingRoute(origin, destination)
+	case RouteModeWalking:
+		return provider.WalkingRoute(origin, destination)
+	case RouteModeCycling:
+		return provider.CyclingRoute(origin, destination)
+	case RouteModeTransit:
+		return provider.TransitRoute(origin, destination, city)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedRouteMode, mode)
+}
+
+// AmapString 高德接口的文本字段。没有值时高德返回空数组 [] 而不是空字符串，v4 接口的数值字段为数字，统一解析为字符串
+type AmapString string
+
+// UnmarshalJSON 接受字符串、数字，空数组、空对象和 null 解析为空字符串
+func (s *AmapString) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		*s = ""
+		return nil
+	}
+	switch data[0] {
+	case '"':
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*s = AmapString(value)
+	case '[', '{', 'n':
+		*s = ""
+	default:
+		*s = AmapString(data)
+	}
+	return nil
+}
+
+// amapEmpty 高德返回的对象字段是否为空（[]、{}、null）
+func amapEmpty(data json.RawMessage) bool {
+	switch string(bytes.TrimSpace(data)) {
+	case "", "[]", "{}", "null", `""`:
+		return true
+	}
+	return false
+}
+
+// UnmarshalJSON 兼容高德没有值时返回的 [] 和 v4 接口的数值字段
+func (p *Path) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Distance AmapString `json:"distance"`
+		Duration AmapString `json:"duration"`
+		Steps    []Step     `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Path{Distance: string(raw.Distance), Duration: string(raw.Duration), Steps: raw.Steps}
+	return nil
+}
+
+// UnmarshalJSON 兼容高德没有值时返回的 []（如 road、action）和 v4 接口的数值字段
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Instruction AmapString `json:"instruction"`
+		Road        AmapString `json:"road"`
+		Distance    AmapString `json:"distance"`
+		Duration    AmapString `json:"duration"`
+		Polyline    AmapString `json:"polyline"`
+		Action      AmapString `json:"action"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Step{
+		Instruction: string(raw.Instruction),
+		Road:        string(raw.Road),
+		Distance:    string(raw.Distance),
+		Duration:    string(raw.Duration),
+		Polyline:    string(raw.Polyline),
+		Action:      string(raw.Action),
+	}
+	return nil
+}
+
+// Transit 高德公交路线方案（/direction/transit/integrated 的 transits）
+type Transit struct {
+	Cost            AmapString       `json:"cost"`             // 票价（元）
+	Duration        AmapString       `json:"duration"`         // 时间（秒）
+	Distance        AmapString       `json:"distance"`         // 距离（米）
+	WalkingDistance AmapString       `json:"walking_distance"` // 步行距离（米）
+	Nightflag       AmapString       `json:"nightflag"`        // 是否夜班车，"1" 为是
+	Segments        []TransitSegment `json:"segments"`
+}
+
+// TransitSegment 公交方案中的一段：先步行到站，再乘坐公交/地铁或火车
+type TransitSegment struct {
+	Walking  *TransitWalking `json:"walking,omitempty"`
+	Bus      TransitBus      `json:"bus"`
+	Entrance *TransitStation `json:"entrance,omitempty"` // 地铁进站口
+	Exit     *TransitStation `json:"exit,omitempty"`     // 地铁出站口
+	Railway  *TransitRailway `json:"railway,omitempty"`  // 城际火车
+}
+
+// UnmarshalJSON 高德用 [] 表示没有该部分（如不需要步行），这些字段保持为空
+func (s *TransitSegment) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Walking  json.RawMessage `json:"walking"`
+		Bus      json.RawMessage `json:"bus"`
+		Entrance json.RawMessage `json:"entrance"`
+		Exit     json.RawMessage `json:"exit"`
+		Railway  json.RawMessage `json:"railway"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = TransitSegment{}
+	if !amapEmpty(raw.Walking) {
+		s.Walking = &TransitWalking{}
+		if err := json.Unmarshal(raw.Walking, s.Walking); err != nil {
+			return err
+		}
+	}
+	if !amapEmpty(raw.Bus) {
+		if err := json.Unmarshal(raw.Bus, &s.Bus); err != nil {
+			return err
+		}
+	}
+	if !amapEmpty(raw.Entrance) {
+		s.Entrance = &TransitStation{}
+		if err := json.Unmarshal(raw.Entrance, s.Entrance); err != nil {
+			return err
+		}
+	}
+	if !amapEmpty(raw.Exit) {
+		s.Exit = &TransitStation{}
+		if err := json.Unmarshal(raw.Exit, s.Exit); err != nil {
+			return err
+		}
+	}
+	if !amapEmpty(raw.Railway) {
+		railway := &TransitRailway{}
+		if err := json.Unmarshal(raw.Railway, railway); err != nil {
+			return err
+		}
+		if railway.Name != "" {
+			s.Railway = railway
+		}
+	}
+	return nil
+}
+
+// TransitWalking 公交方案中的步行段
+type TransitWalking struct {
+	Distance AmapString `json:"distance"`
+	Duration AmapString `json:"duration"`
+	Steps    []Step     `json:"steps"`
+}
+
+// TransitBus 公交/地铁乘车段，Buslines 中第一条为推荐线路，其余为可替换的线路
+type TransitBus struct {
+	Buslines []Busline `json:"buslines"`
+}
+
+// Busline 公交或地铁线路
+type Busline struct {
+	Name          AmapString    `json:"name"` // 如 "地铁1号线(湘湖--下沙江滨)"
+	Type          AmapString    `json:"type"` // 如 "地铁线路"、"普通公交线路"
+	Distance      AmapString    `json:"distance"`
+	Duration      AmapString    `json:"duration"`
+	Polyline      AmapString    `json:"polyline"`
+	DepartureStop TransitStop   `json:"departure_stop"`
+	ArrivalStop   TransitStop   `json:"arrival_stop"`
+	ViaNum        AmapString    `json:"via_num"`
+	ViaStops      []TransitStop `json:"via_stops"`
+	StartTime     AmapString    `json:"start_time"` // 首班车时间，如 "0600"
+	EndTime       AmapString    `json:"end_time"`   // 末班车时间
+}
+
+// TransitStop 公交站、地铁站或火车站
+type TransitStop struct {
+	Name     AmapString `json:"name"`
+	Location AmapString `json:"location"`
+	Time     AmapString `json:"time,omitempty"` // 火车到发时间
+}
+
+// TransitStation 地铁出入口
+type TransitStation struct {
+	Name     AmapString `json:"name"`
+	Location AmapString `json:"location"`
+}
+
+// TransitRailway 城际火车段
+type TransitRailway struct {
+	Name          AmapString  `json:"name"`
+	Trip          AmapString  `json:"trip"` // 车次
+	Distance      AmapString  `json:"distance"`
+	Time          AmapString  `json:"time"` // 时间（秒）
+	DepartureStop TransitStop `json:"departure_stop"`
+	ArrivalStop   TransitStop `json:"arrival_stop"`
+}
+
+// Route 统一的路线：驾车、步行、骑行、公交的结果及各地图服务都转换为该格式
+type Route struct {
+	Provider        string     `json:"provider"`
+	Mode            string     `json:"mode"` // driving, walking, cycling, transit
+	DistanceMeters  int        `json:"distance_meters"`
+	DurationSeconds int        `json:"duration_seconds"`
+	Fare            float64    `json:"fare,omitempty"`           // 公交票价
+	WalkingMeters   int        `json:"walking_meters,omitempty"` // 公交方案中的步行距离
+	Night           bool       `json:"night,omitempty"`          // 是否夜班车
+	Legs            []RouteLeg `json:"legs"`
+}
+
+// RouteLeg 路线中的一段：一个驾车/步行/骑行步骤，或乘坐一条公交、地铁、火车线路
+type RouteLeg struct {
+	Mode            string       `json:"mode"` // 路线方式或 bus, subway, railway
+	Instruction     string       `json:"instruction"`
+	Road            string       `json:"road,omitempty"`
+	DistanceMeters  int          `json:"distance_meters"`
+	DurationSeconds int          `json:"duration_seconds"`
+	Polyline        string       `json:"polyline,omitempty"` // "经度,纬度;经度,纬度"
+	Line            *TransitLine `json:"line,omitempty"`
+}
+
+// TransitLine 乘车段的线路信息
+type TransitLine struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type,omitempty"`
+	DepartureStop TransitStop   `json:"departure_stop"`
+	ArrivalStop   TransitStop   `json:"arrival_stop"`
+	ViaStops      []TransitStop `json:"via_stops"`
+	StartTime     string        `json:"start_time,omitempty"`
+	EndTime       string        `json:"end_time,omitempty"`
+	Entrance      string        `json:"entrance,omitempty"` // 地铁进站口
+	Exit          string        `json:"exit,omitempty"`     // 地铁出站口
+	Alternatives  []string      `json:"alternatives,omitempty"`
+}
+
+// NormalizeRoutes 将路线规划结果转换为统一的路线列表（每个备选方案一条）
+func NormalizeRoutes(provider, mode string, response *RouteResponse) []Route {
+	routes := []Route{}
+	if response == nil {
+		return routes
+	}
+	if mode == RouteModeTransit {
+		for _, transit := range response.Route.Transits {
+			routes = append(routes, normalizeTransit(provider, transit))
+		}
+		return routes
+	}
+	for _, path := range response.Route.Paths {
+		route := Route{
+			Provider:        provider,
+			Mode:            mode,
+			DistanceMeters:  atoiRounded(path.Distance),
+			DurationSeconds: atoiRounded(path.Duration),
+			Legs:            []RouteLeg{},
+		}
+		for _, step := range path.Steps {
+			route.Legs = append(route.Legs, stepLeg(mode, step))
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func normalizeTransit(provider string, transit Transit) Route {
+	fare, _ := strconv.ParseFloat(string(transit.Cost), 64)
+	route := Route{
+		Provider:        provider,
+		Mode:            RouteModeTransit,
+		DistanceMeters:  atoiRounded(string(transit.Distance)),
+		DurationSeconds: atoiRounded(string(transit.Duration)),
+		Fare:            fare,
+		WalkingMeters:   atoiRounded(string(transit.WalkingDistance)),
+		Night:           transit.Nightflag == "1",
+		Legs:            []RouteLeg{},
+	}
+	for _, segment := range transit.Segments {
+		if segment.Walking != nil {
+			for _, step := range segment.Walking.Steps {
+				route.Legs = append(route.Legs, stepLeg(RouteModeWalking, step))
+			}
+		}
+		if len(segment.Bus.Buslines) > 0 {
+			route.Legs = append(route.Legs, buslineLeg(segment))
+		}
+		if railway := segment.Railway; railway != nil {
+			route.Legs = append(route.Legs, RouteLeg{
+				Mode:            LegModeRailway,
+				Instruction:     fmt.Sprintf("乘坐%s，%s上车，%s下车", orDefault(string(railway.Trip), string(railway.Name)), railway.DepartureStop.Name, railway.ArrivalStop.Name),
+				DistanceMeters:  atoiRounded(string(railway.Distance)),
+				DurationSeconds: atoiRounded(string(railway.Time)),
+				Line: &TransitLine{
+					Name:          string(railway.Name),
+					DepartureStop: railway.DepartureStop,
+					ArrivalStop:   railway.ArrivalStop,
+					ViaStops:      []TransitStop{},
+				},
+			})
+		}
+	}
+	return route
+}
+
+// buslineLeg 乘车段使用推荐线路，其余线路作为可替换线路
+func buslineLeg(segment TransitSegment) RouteLeg {
+	line := segment.Bus.Buslines[0]
+	mode := LegModeBus
+	if strings.Contains(string(line.Type), "地铁") || strings.Contains(string(line.Type), "轻轨") {
+		mode = LegModeSubway
+	}
+	transitLine := &TransitLine{
+		Name:          string(line.Name),
+		Type:          string(line.Type),
+		DepartureStop: line.DepartureStop,
+		ArrivalStop:   line.ArrivalStop,
+		ViaStops:      line.ViaStops,
+		StartTime:     string(line.StartTime),
+		EndTime:       string(line.EndTime),
+	}
+	if transitLine.ViaStops == nil {
+		transitLine.ViaStops = []TransitStop{}
+	}
+	if segment.Entrance != nil {
+		transitLine.Entrance = string(segment.Entrance.Name)
+	}
+	if segment.Exit != nil {
+		transitLine.Exit = string(segment.Exit.Name)
+	}
+	for _, alternative := range segment.Bus.Buslines[1:] {
+		transitLine.Alternatives = append(transitLine.Alternatives, string(alternative.Name))
+	}
+
+	return RouteLeg{
+		Mode:            mode,
+		Instruction:     fmt.Sprintf("乘坐%s，%s上车，%s下车（途经%d站）", line.Name, line.DepartureStop.Name, line.ArrivalStop.Name, len(line.ViaStops)),
+		DistanceMeters:  atoiRounded(string(line.Distance)),
+		DurationSeconds: atoiRounded(string(line.Duration)),
+		Polyline:        string(line.Polyline),
+		Line:            transitLine,
+	}
+}
+
+func stepLeg(mode string, step Step) RouteLeg {
+	return RouteLeg{
+		Mode:            mode,
+		Instruction:     step.Instruction,
+		Road:            step.Road,
+		DistanceMeters:  atoiRounded(step.Distance),
+		DurationSeconds: atoiRounded(step.Duration),
+		Polyline:        step.Polyline,
+	}
+}
+
+// atoiRounded 解析距离或时间字符串（可能带小数），无法解析时返回 0
+func atoiRounded(value string) int {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(number))
+}
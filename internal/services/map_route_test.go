@@ -0,0 +1,154 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// amapTransitFixture 高德公交路线响应：步行 → 地铁（含出入口）→ 公交（含替换线路），没有值的字段为 []
+const amapTransitFixture = `{
+  "status": "1", "info": "OK", "count": "1",
+  "route": {
+    "origin": "120.130663,30.240018", "destination": "120.212410,30.290850",
+    "distance": "12034", "taxi_cost": "38",
+    "transits": [{
+      "cost": "5.0", "duration": "3120", "nightflag": "0", "walking_distance": "820", "distance": "11800", "missed": "0",
+      "segments": [
+        {
+          "taxi": [],
+          "walking": {
+            "origin": "120.130663,30.240018", "destination": "120.133100,30.243200", "distance": "520", "duration": "420",
+            "steps": [
+              {"instruction": "向北步行300米", "road": [], "distance": "300", "duration": "240", "polyline": "120.130663,30.240018;120.131,30.242", "action": [], "assistant_action": "到达龙翔桥"},
+              {"instruction": "步行220米到达龙翔桥站B口", "road": "延安路", "distance": "220", "duration": "180", "polyline": "120.131,30.242;120.1331,30.2432", "action": "右转", "assistant_action": []}
+            ]
+          },
+          "bus": {"buslines": [{
+            "departure_stop": {"name": "龙翔桥", "id": "BV1", "location": "120.1331,30.2432"},
+            "arrival_stop": {"name": "打铁关", "id": "BV2", "location": "120.1801,30.2855"},
+            "name": "地铁1号线(湘湖--下沙江滨)", "id": "330100023101", "type": "地铁线路",
+            "distance": "7600", "duration": "1080", "polyline": "120.1331,30.2432;120.1801,30.2855",
+            "start_time": "0600", "end_time": "2300", "via_num": "2",
+            "via_stops": [{"name": "凤起路", "id": "BV3", "location": "120.1630,30.2640"}, {"name": "武林广场", "id": "BV4", "location": "120.1650,30.2720"}]
+          }]},
+          "entrance": {"name": "B口", "location": "120.1331,30.2432"},
+          "exit": {"name": "C口", "location": "120.1801,30.2855"},
+          "railway": {"spaces": [], "alters": []}
+        },
+        {
+          "taxi": [],
+          "walking": [],
+          "bus": {"buslines": [
+            {
+              "departure_stop": {"name": "打铁关", "location": "120.1805,30.2860"},
+              "arrival_stop": {"name": "文晖路口", "location": "120.2120,30.2905"},
+              "name": "B1路(下沙高教东区--武林广场)", "type": "普通公交线路",
+              "distance": "3680", "duration": "1320", "polyline": [], "start_time": [], "end_time": [], "via_num": "0", "via_stops": []
+            },
+            {
+              "departure_stop": {"name": "打铁关", "location": "120.1805,30.2860"},
+              "arrival_stop": {"name": "文晖路口", "location": "120.2120,30.2905"},
+              "name": "63路", "type": "普通公交线路", "distance": "3700", "duration": "1500", "via_num": "1", "via_stops": []
+            }
+          ]},
+          "entrance": [], "exit": [], "railway": []
+        },
+        {"taxi": [], "walking": {"distance": "300", "duration": "300", "steps": [{"instruction": "步行300米到达终点", "road": [], "distance": "300", "duration": "300", "polyline": [], "action": [], "assistant_action": "到达终点"}]}, "bus": {"buslines": []}, "entrance": [], "exit": [], "railway": []}
+      ]
+    }]
+  }
+}`
+
+// amapCyclingFixture 高德 v4 骑行路线响应，数值字段为数字
+const amapCyclingFixture = `{
+  "data": {"origin": "120.130663,30.240018", "destination": "120.212410,30.290850", "paths": [{
+    "distance": 10234, "duration": 2456.5,
+    "steps": [{"instruction": "沿北山街骑行800米", "road": "北山街", "distance": 800, "duration": 192, "polyline": "120.1306,30.2400;120.1390,30.2580", "action": "左转", "assistant_action": ""}]
+  }]},
+  "errcode": 0, "errmsg": "OK"
+}`
+
+func newRouteAmapStandIn(t *testing.T) *AmapService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/direction/transit/integrated":
+			w.Write([]byte(amapTransitFixture))
+		case "/direction/bicycling":
+			w.Write([]byte(amapCyclingFixture))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = "system-key"
+	cfg.APIs.Amap.BaseURL = server.URL
+	return NewAmapService(cfg)
+}
+
+func TestAmapService_TransitRoute(t *testing.T) {
+	amap := newRouteAmapStandIn(t)
+	response, err := amap.TransitRoute("120.130663,30.240018", "120.212410,30.290850", "杭州")
+	if err != nil {
+		t.Fatalf("TransitRoute failed: %v", err)
+	}
+	if len(response.Route.Transits) != 1 || response.Route.TaxiCost != "38" {
+		t.Fatalf("Unexpected transit response: %+v", response.Route)
+	}
+	segments := response.Route.Transits[0].Segments
+	if len(segments) != 3 || segments[0].Entrance == nil || segments[1].Walking != nil || segments[1].Entrance != nil || segments[0].Railway != nil {
+		t.Fatalf("Empty [] parts should be left unset: %+v", segments)
+	}
+
+	routes := NormalizeRoutes(amap.Name(), RouteModeTransit, response)
+	if len(routes) != 1 {
+		t.Fatalf("Expected one route, got %d", len(routes))
+	}
+	route := routes[0]
+	if route.Mode != RouteModeTransit || route.DurationSeconds != 3120 || route.DistanceMeters != 11800 || route.Fare != 5 || route.WalkingMeters != 820 || route.Night {
+		t.Errorf("Unexpected route summary: %+v", route)
+	}
+
+	modes := []string{}
+	for _, leg := range route.Legs {
+		modes = append(modes, leg.Mode)
+	}
+	if len(modes) != 5 || modes[0] != RouteModeWalking || modes[2] != LegModeSubway || modes[3] != LegModeBus || modes[4] != RouteModeWalking {
+		t.Fatalf("Unexpected leg modes: %v", modes)
+	}
+	if route.Legs[0].Road != "" || route.Legs[1].Road != "延安路" {
+		t.Errorf("Unexpected walking roads: %+v", route.Legs[:2])
+	}
+	subway := route.Legs[2].Line
+	if subway.DepartureStop.Name != "龙翔桥" || subway.ArrivalStop.Name != "打铁关" || len(subway.ViaStops) != 2 ||
+		subway.Entrance != "B口" || subway.Exit != "C口" || subway.StartTime != "0600" || route.Legs[2].DurationSeconds != 1080 {
+		t.Errorf("Unexpected subway leg: %+v %+v", route.Legs[2], subway)
+	}
+	bus := route.Legs[3].Line
+	if bus.Name != "B1路(下沙高教东区--武林广场)" || len(bus.Alternatives) != 1 || bus.Alternatives[0] != "63路" || bus.StartTime != "" || route.Legs[3].Polyline != "" {
+		t.Errorf("Unexpected bus leg: %+v %+v", route.Legs[3], bus)
+	}
+}
+
+func TestAmapService_CyclingRoute(t *testing.T) {
+	amap := newRouteAmapStandIn(t)
+	response, err := amap.CyclingRoute("120.130663,30.240018", "120.212410,30.290850")
+	if err != nil {
+		t.Fatalf("CyclingRoute failed: %v", err)
+	}
+	routes := NormalizeRoutes(amap.Name(), RouteModeCycling, response)
+	if len(routes) != 1 || routes[0].DistanceMeters != 10234 || routes[0].DurationSeconds != 2457 {
+		t.Fatalf("Unexpected cycling routes: %+v", routes)
+	}
+	if leg := routes[0].Legs[0]; leg.Mode != RouteModeCycling || leg.Road != "北山街" || leg.DistanceMeters != 800 {
+		t.Errorf("Unexpected cycling leg: %+v", leg)
+	}
+
+	if got := amapV4URL("https://restapi.amap.com/v3"); got != "https://restapi.amap.com/v4" {
+		t.Errorf("amapV4URL = %q", got)
+	}
+}
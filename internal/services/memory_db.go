@@ -15,6 +15,10 @@ type MemoryDB struct {
 	travelDays  map[string]*models.TravelDay
 	activities  map[string]*models.Activity
 	expenses    map[string]*models.Expense
+	llmUsages   map[string]*models.LLMUsage
+	quotas      map[string]*models.UserQuotaOverride
+	planChats   map[string]*models.PlanChatMessage
+	violations  map[string]*models.GuardrailViolation
 	mutex       sync.RWMutex
 }
 
@@ -27,6 +31,10 @@ func NewMemoryDB() *MemoryDB {
 		travelDays:  make(map[string]*models.TravelDay),
 		activities:  make(map[string]*models.Activity),
 		expenses:    make(map[string]*models.Expense),
+		llmUsages:   make(map[string]*models.LLMUsage),
+		quotas:      make(map[string]*models.UserQuotaOverride),
+		planChats:   make(map[string]*models.PlanChatMessage),
+		violations:  make(map[string]*models.GuardrailViolation),
 	}
 }
 
@@ -125,6 +133,15 @@ func (db *MemoryDB) UpdateUserProfile(userID string, updates map[string]interfac
 	if phone, ok := updates["phone"].(string); ok {
 		profile.Phone = phone
 	}
+	if locale, ok := updates["locale"].(string); ok {
+		profile.Locale = locale
+	}
+	if preferences, ok := updates["preferences"].(string); ok {
+		profile.Preferences = preferences
+	}
+	if preferences, ok := updates["trip_preferences"].(*models.TripPreferences); ok {
+		profile.TripPreferences = preferences
+	}
 	profile.UpdatedAt = time.Now()
 
 	return nil
@@ -167,10 +184,13 @@ func (db *MemoryDB) UpdateTravelPlan(id, userID string, updates map[string]inter
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	plan, exists := db.travelPlans[id]
-	if !exists || plan.UserID != userID {
+	stored, exists := db.travelPlans[id]
+	if !exists || stored.UserID != userID {
 		return nil
 	}
+	// 在副本上更新后替换，已取出的计划（如正在序列化的响应）不受后台更新影响
+	updated := *stored
+	plan := &updated
 
 	// 应用更新
 	if title, ok := updates["title"].(string); ok {
@@ -182,11 +202,29 @@ func (db *MemoryDB) UpdateTravelPlan(id, userID string, updates map[string]inter
 	if status, ok := updates["status"].(string); ok {
 		plan.Status = status
 	}
+	if recommendations, ok := updates["recommendations"].([]string); ok {
+		plan.Recommendations = recommendations
+	}
 	plan.UpdatedAt = time.Now()
+	db.travelPlans[id] = plan
 
 	return nil
 }
 
+// SetPlanGeocodeStatus 记录计划活动坐标的解析进度，由服务内部调用，不通过 UpdateTravelPlan 对外开放
+func (db *MemoryDB) SetPlanGeocodeStatus(id, userID, status string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	stored, exists := db.travelPlans[id]
+	if !exists || stored.UserID != userID {
+		return
+	}
+	updated := *stored
+	updated.GeocodeStatus = status
+	db.travelPlans[id] = &updated
+}
+
 func (db *MemoryDB) DeleteTravelPlan(id, userID string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
@@ -244,6 +282,54 @@ func (db *MemoryDB) GetActivities(dayID string) ([]*models.Activity, error) {
 	return activities, nil
 }
 
+func (db *MemoryDB) GetActivity(id string) (*models.Activity, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	activity, ok := db.activities[id]
+	if !ok {
+		return nil, fmt.Errorf("activity not found")
+	}
+	return activity, nil
+}
+
+func (db *MemoryDB) UpdateActivity(activity *models.Activity) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, ok := db.activities[activity.ID]; !ok {
+		return fmt.Errorf("activity not found")
+	}
+	db.activities[activity.ID] = activity
+	return nil
+}
+
+// ModifyActivity 在活动当前记录的副本上修改后替换，只改动 modify 涉及的字段，不会覆盖期间其他请求的修改；活动不存在时返回 false
+func (db *MemoryDB) ModifyActivity(id string, modify func(activity *models.Activity)) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	stored, ok := db.activities[id]
+	if !ok {
+		return false
+	}
+	updated := *stored
+	modify(&updated)
+	db.activities[id] = &updated
+	return true
+}
+
+func (db *MemoryDB) DeleteActivity(id string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, ok := db.activities[id]; !ok {
+		return fmt.Errorf("activity not found")
+	}
+	delete(db.activities, id)
+	return nil
+}
+
 // Expense operations
 func (db *MemoryDB) CreateExpense(expense *models.Expense) error {
 	db.mutex.Lock()
@@ -298,3 +384,141 @@ func (db *MemoryDB) DeleteExpense(id string) error {
 	delete(db.expenses, id)
 	return nil
 }
+
+// LLM usage operations
+func (db *MemoryDB) CreateLLMUsage(usage *models.LLMUsage) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.llmUsages[usage.ID] = usage
+	return nil
+}
+
+// ModifyLLMUsage 在用量记录的副本上修改后替换，记录不存在时返回 false
+func (db *MemoryDB) ModifyLLMUsage(id string, modify func(usage *models.LLMUsage)) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	stored, ok := db.llmUsages[id]
+	if !ok {
+		return false
+	}
+	updated := *stored
+	modify(&updated)
+	db.llmUsages[id] = &updated
+	return true
+}
+
+func (db *MemoryDB) GetLLMUsages(userID string) ([]*models.LLMUsage, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var usages []*models.LLMUsage
+	for _, usage := range db.llmUsages {
+		if userID == "" || usage.UserID == userID {
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+// Quota override operations
+func (db *MemoryDB) SetQuotaOverride(override *models.UserQuotaOverride) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.quotas[override.UserID] = override
+	return nil
+}
+
+func (db *MemoryDB) GetQuotaOverride(userID string) (*models.UserQuotaOverride, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	override, exists := db.quotas[userID]
+	if !exists {
+		return nil, nil
+	}
+	return override, nil
+}
+
+func (db *MemoryDB) DeleteQuotaOverride(userID string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	delete(db.quotas, userID)
+	return nil
+}
+
+// Plan chat operations
+func (db *MemoryDB) CreatePlanChatMessage(message *models.PlanChatMessage) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.planChats[message.ID] = message
+	return nil
+}
+
+func (db *MemoryDB) GetPlanChatMessages(planID string) ([]*models.PlanChatMessage, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var messages []*models.PlanChatMessage
+	for _, message := range db.planChats {
+		if message.PlanID == planID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (db *MemoryDB) UpdatePlanChatMessage(message *models.PlanChatMessage) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, ok := db.planChats[message.ID]; !ok {
+		return fmt.Errorf("chat message not found")
+	}
+	db.planChats[message.ID] = message
+	return nil
+}
+
+// SetPlanChatPatchStatus 消息的修改建议状态为 from 时改为 to（在副本上修改后替换），返回修改后的消息和是否修改；
+// 比较和修改在同一把锁内完成，并发确认同一条建议时只有一个能成功
+func (db *MemoryDB) SetPlanChatPatchStatus(id, from, to string) (*models.PlanChatMessage, bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	stored, ok := db.planChats[id]
+	if !ok {
+		return nil, false
+	}
+	if stored.PatchStatus != from {
+		return stored, false
+	}
+	updated := *stored
+	updated.PatchStatus = to
+	updated.UpdatedAt = time.Now()
+	db.planChats[id] = &updated
+	return &updated, true
+}
+
+// Guardrail violation operations
+func (db *MemoryDB) CreateGuardrailViolation(violation *models.GuardrailViolation) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.violations[violation.ID] = violation
+	return nil
+}
+
+func (db *MemoryDB) GetGuardrailViolations() ([]*models.GuardrailViolation, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var violations []*models.GuardrailViolation
+	for _, violation := range db.violations {
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
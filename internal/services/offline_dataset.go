@@ -0,0 +1,111 @@
+package services
+
+// offlinePOI 离线规划器使用的地点
+type offlinePOI struct {
+	ID        string
+	Name      string
+	Address   string
+	Longitude float64
+	Latitude  float64
+}
+
+// 离线规划器的地点分类
+const (
+	offlineCategoryAttraction = "attraction"
+	offlineCategoryRestaurant = "restaurant"
+	offlineCategoryHotel      = "hotel"
+)
+
+// offlineDataset 内置的热门城市地点数据，高德不可用且没有缓存时使用（坐标为GCJ-02）
+var offlineDataset = map[string]map[string][]offlinePOI{
+	"北京": {
+		offlineCategoryAttraction: {
+			{Name: "故宫博物院", Address: "东城区景山前街4号", Longitude: 116.397029, Latitude: 39.917839},
+			{Name: "天坛公园", Address: "东城区天坛东里甲1号", Longitude: 116.410829, Latitude: 39.881913},
+			{Name: "颐和园", Address: "海淀区新建宫门路19号", Longitude: 116.275179, Latitude: 39.999617},
+			{Name: "八达岭长城", Address: "延庆区G6京藏高速58号出口", Longitude: 116.016033, Latitude: 40.356188},
+			{Name: "南锣鼓巷", Address: "东城区南锣鼓巷", Longitude: 116.403119, Latitude: 39.937183},
+			{Name: "景山公园", Address: "西城区景山西街44号", Longitude: 116.396906, Latitude: 39.925209},
+		},
+		offlineCategoryRestaurant: {
+			{Name: "全聚德(前门店)", Address: "东城区前门大街30号", Longitude: 116.397751, Latitude: 39.898719},
+			{Name: "四季民福烤鸭店(故宫店)", Address: "东城区南池子大街11号", Longitude: 116.404129, Latitude: 39.913694},
+			{Name: "护国寺小吃(护国寺街总店)", Address: "西城区护国寺街93号", Longitude: 116.372521, Latitude: 39.936287},
+		},
+		offlineCategoryHotel: {
+			{Name: "王府井商圈酒店", Address: "东城区王府井大街", Longitude: 116.410886, Latitude: 39.914931},
+		},
+	},
+	"上海": {
+		offlineCategoryAttraction: {
+			{Name: "外滩", Address: "黄浦区中山东一路", Longitude: 121.490317, Latitude: 31.240018},
+			{Name: "豫园", Address: "黄浦区福佑路168号", Longitude: 121.492156, Latitude: 31.227203},
+			{Name: "东方明珠广播电视塔", Address: "浦东新区世纪大道1号", Longitude: 121.499718, Latitude: 31.239703},
+			{Name: "上海博物馆", Address: "黄浦区人民大道201号", Longitude: 121.475684, Latitude: 31.228415},
+			{Name: "田子坊", Address: "黄浦区泰康路210弄", Longitude: 121.466868, Latitude: 31.208628},
+			{Name: "武康路", Address: "徐汇区武康路", Longitude: 121.437874, Latitude: 31.206021},
+		},
+		offlineCategoryRestaurant: {
+			{Name: "南翔馒头店(豫园店)", Address: "黄浦区豫园路85号", Longitude: 121.491611, Latitude: 31.227588},
+			{Name: "老正兴菜馆", Address: "黄浦区福州路556号", Longitude: 121.480391, Latitude: 31.235148},
+			{Name: "小杨生煎(黄河路店)", Address: "黄浦区黄河路90号", Longitude: 121.470437, Latitude: 31.236417},
+		},
+		offlineCategoryHotel: {
+			{Name: "人民广场商圈酒店", Address: "黄浦区人民广场", Longitude: 121.475164, Latitude: 31.232136},
+		},
+	},
+	"杭州": {
+		offlineCategoryAttraction: {
+			{Name: "西湖断桥残雪", Address: "西湖区北山街", Longitude: 120.151931, Latitude: 30.258882},
+			{Name: "灵隐寺", Address: "西湖区法云弄1号", Longitude: 120.101406, Latitude: 30.240913},
+			{Name: "雷峰塔", Address: "西湖区南山路15号", Longitude: 120.148865, Latitude: 30.231378},
+			{Name: "河坊街", Address: "上城区河坊街", Longitude: 120.169818, Latitude: 30.242916},
+			{Name: "西溪国家湿地公园", Address: "西湖区天目山路518号", Longitude: 120.063362, Latitude: 30.272441},
+			{Name: "中国茶叶博物馆", Address: "西湖区龙井路88号", Longitude: 120.124633, Latitude: 30.233887},
+		},
+		offlineCategoryRestaurant: {
+			{Name: "楼外楼(孤山路店)", Address: "西湖区孤山路30号", Longitude: 120.145813, Latitude: 30.255795},
+			{Name: "知味观(湖滨店)", Address: "上城区仁和路83号", Longitude: 120.167788, Latitude: 30.252454},
+			{Name: "外婆家(湖滨店)", Address: "上城区湖滨路3号", Longitude: 120.164613, Latitude: 30.256132},
+		},
+		offlineCategoryHotel: {
+			{Name: "湖滨商圈酒店", Address: "上城区湖滨路", Longitude: 120.165093, Latitude: 30.258109},
+		},
+	},
+	"成都": {
+		offlineCategoryAttraction: {
+			{Name: "宽窄巷子", Address: "青羊区长顺街附近", Longitude: 104.055429, Latitude: 30.670086},
+			{Name: "武侯祠", Address: "武侯区武侯祠大街231号", Longitude: 104.047816, Latitude: 30.646221},
+			{Name: "锦里古街", Address: "武侯区武侯祠大街231号附1号", Longitude: 104.050258, Latitude: 30.644895},
+			{Name: "成都大熊猫繁育研究基地", Address: "成华区熊猫大道1375号", Longitude: 104.146244, Latitude: 30.733075},
+			{Name: "杜甫草堂", Address: "青羊区青华路37号", Longitude: 104.028931, Latitude: 30.660566},
+			{Name: "人民公园", Address: "青羊区少城路12号", Longitude: 104.057564, Latitude: 30.658046},
+		},
+		offlineCategoryRestaurant: {
+			{Name: "陈麻婆豆腐(青华路店)", Address: "青羊区青华路10号", Longitude: 104.033514, Latitude: 30.663401},
+			{Name: "龙抄手(春熙路店)", Address: "锦江区春熙路南段6号", Longitude: 104.080657, Latitude: 30.654874},
+			{Name: "蜀大侠火锅(春熙路店)", Address: "锦江区春熙路", Longitude: 104.081224, Latitude: 30.657143},
+		},
+		offlineCategoryHotel: {
+			{Name: "春熙路商圈酒店", Address: "锦江区春熙路", Longitude: 104.080989, Latitude: 30.657689},
+		},
+	},
+	"西安": {
+		offlineCategoryAttraction: {
+			{Name: "秦始皇帝陵博物院(兵马俑)", Address: "临潼区秦陵北路", Longitude: 109.278533, Latitude: 34.384742},
+			{Name: "西安城墙永宁门", Address: "碑林区南大街南门", Longitude: 108.947056, Latitude: 34.251065},
+			{Name: "大雁塔", Address: "雁塔区雁塔路", Longitude: 108.964222, Latitude: 34.219269},
+			{Name: "陕西历史博物馆", Address: "雁塔区小寨东路91号", Longitude: 108.954346, Latitude: 34.224997},
+			{Name: "回民街", Address: "莲湖区北院门", Longitude: 108.942256, Latitude: 34.264979},
+			{Name: "钟鼓楼广场", Address: "莲湖区西大街", Longitude: 108.944267, Latitude: 34.261221},
+		},
+		offlineCategoryRestaurant: {
+			{Name: "老孙家饭庄(东大街店)", Address: "碑林区东大街364号", Longitude: 108.955781, Latitude: 34.261857},
+			{Name: "德发长饺子馆(钟楼店)", Address: "碑林区西大街3号", Longitude: 108.945398, Latitude: 34.260806},
+			{Name: "樊记腊汁肉夹馍", Address: "莲湖区竹笆市53号", Longitude: 108.942102, Latitude: 34.259517},
+		},
+		offlineCategoryHotel: {
+			{Name: "钟楼商圈酒店", Address: "碑林区钟楼附近", Longitude: 108.947025, Latitude: 34.260997},
+		},
+	},
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// 行程结果的生成方式
+const (
+	PlanGeneratorLLM     = "llm"
+	PlanGeneratorOffline = "offline"
+)
+
+// offlineBudgetShares 离线规划的预算分配比例，没有住宿（当天往返）时按比例分摊给其它分类
+var offlineBudgetShares = map[string]float64{
+	"accommodation": 0.35,
+	"food":          0.25,
+	"transport":     0.20,
+	"attractions":   0.20,
+}
+
+// offlinePOIQueries 各分类在高德上的搜索关键词和类型编码
+var offlinePOIQueries = map[string][2]string{
+	offlineCategoryAttraction: {"景点", "110000"},
+	offlineCategoryRestaurant: {"美食", "050000"},
+	offlineCategoryHotel:      {"酒店", "100000"},
+}
+
+// OfflinePlanner 不依赖LLM的规则行程规划器：按固定的日程模板排布高德POI（或内置数据），
+// 按比例拆分预算，输出与LLM相同结构的 TravelPlanResult
+type OfflinePlanner struct {
+	amap     *AmapService // 可为 nil，此时只使用缓存和内置数据
+	fallback bool
+
+	mutex sync.RWMutex
+	pois  map[string][]offlinePOI // 目的地|分类 -> 从高德获取过的POI
+}
+
+// NewOfflinePlanner 创建离线规划器
+func NewOfflinePlanner(fallback bool, amap *AmapService) *OfflinePlanner {
+	return &OfflinePlanner{
+		amap:     amap,
+		fallback: fallback,
+		pois:     make(map[string][]offlinePOI),
+	}
+}
+
+// FallbackEnabled 是否在LLM不可用时自动改用离线规划
+func (p *OfflinePlanner) FallbackEnabled() bool {
+	return p.fallback
+}
+
+// offlineSlot 日程模板中的一个时间段
+type offlineSlot struct {
+	time     string
+	kind     string // attraction, restaurant, hotel, transport
+	category string // 预算分类
+}
+
+// GeneratePlan 为请求生成规则行程，locale 决定描述和建议使用的语言
+func (p *OfflinePlanner) GeneratePlan(request *models.CreateTravelPlanRequest, locale string) (*TravelPlanResult, error) {
+	return p.GeneratePlanWithKey(request, locale, "")
+}
+
+// GeneratePlanWithKey 使用指定的高德API Key搜索POI生成规则行程，amapKey 为空时使用系统Key
+func (p *OfflinePlanner) GeneratePlanWithKey(request *models.CreateTravelPlanRequest, locale, amapKey string) (*TravelPlanResult, error) {
+	amap := p.amap.WithKey(amapKey)
+	startDate := request.StartDate.Time
+	endDate := request.EndDate.Time
+	if endDate.Before(startDate) {
+		return nil, fmt.Errorf("end date is before start date")
+	}
+	totalDays := int(endDate.Sub(startDate).Hours()/24) + 1
+	text := offlineTextFor(locale)
+
+	// 按日程模板排出每天的时间段，再统计每个预算分类的时间段数量用于拆分预算
+	schedule := make([][]offlineSlot, totalDays)
+	slotCounts := make(map[string]int)
+	for i := range schedule {
+		schedule[i] = offlineDaySlots(i == 0, i == totalDays-1)
+		for _, slot := range schedule[i] {
+			slotCounts[slot.category]++
+		}
+	}
+
+	breakdown := offlineBudgetBreakdown(request.Budget, slotCounts["accommodation"] > 0)
+	costs := make(map[string][]float64)
+	for category, count := range slotCounts {
+		costs[category] = splitMoney(breakdown[category], count)
+	}
+
+	attractions := p.candidates(amap, request.Destination, offlineCategoryAttraction, text)
+	restaurants := p.candidates(amap, request.Destination, offlineCategoryRestaurant, text)
+	hotels := p.candidates(amap, request.Destination, offlineCategoryHotel, text)
+	hotel := hotels[0] // 整个行程住同一家，避免每天换酒店
+
+	result := &TravelPlanResult{Generator: PlanGeneratorOffline, Provider: PlanGeneratorOffline}
+	result.Budget.Total = roundMoney(request.Budget)
+	result.Budget.Breakdown = breakdown
+
+	used := make(map[string]int)   // 预算分类 -> 已分配的费用份数
+	visits := make(map[string]int) // 地点分类 -> 已安排次数，用于轮换地点
+	for i, slots := range schedule {
+		day := DayPlan{Day: i + 1, Date: startDate.AddDate(0, 0, i).Format("2006-01-02")}
+		for _, slot := range slots {
+			cost := costs[slot.category][used[slot.category]]
+			used[slot.category]++
+
+			activity := Activity{Time: slot.time, Type: slot.kind, Cost: cost}
+			switch slot.kind {
+			case offlineCategoryAttraction:
+				applyOfflinePOI(&activity, attractions[visits[slot.kind]%len(attractions)])
+				visits[slot.kind]++
+				activity.Description = fmt.Sprintf(text.visit, activity.Title)
+			case offlineCategoryRestaurant:
+				applyOfflinePOI(&activity, restaurants[visits[slot.kind]%len(restaurants)])
+				visits[slot.kind]++
+				activity.Description = text.meal
+			case offlineCategoryHotel:
+				applyOfflinePOI(&activity, hotel)
+				activity.Description = text.hotel
+			default:
+				if i == 0 {
+					activity.Title = fmt.Sprintf(text.arrive, request.Destination)
+				} else {
+					activity.Title = fmt.Sprintf(text.depart, request.Destination)
+				}
+				activity.Location = request.Destination
+				activity.Description = text.transport
+			}
+			day.Activities = append(day.Activities, activity)
+		}
+		result.Days = append(result.Days, day)
+	}
+
+	result.Recommendations = append([]string{}, text.recommendations...)
+	return result, nil
+}
+
+// offlineDaySlots 每天的日程模板：上午、下午各一个景点，午餐和晚餐，除最后一天外入住酒店；
+// 第一天先抵达，最后一天以返程代替晚餐
+func offlineDaySlots(first, last bool) []offlineSlot {
+	var slots []offlineSlot
+	if first {
+		slots = append(slots, offlineSlot{"08:30", "transport", "transport"})
+	}
+	slots = append(slots,
+		offlineSlot{"09:30", offlineCategoryAttraction, "attractions"},
+		offlineSlot{"12:00", offlineCategoryRestaurant, "food"},
+		offlineSlot{"14:00", offlineCategoryAttraction, "attractions"},
+	)
+	if last {
+		return append(slots, offlineSlot{"17:00", "transport", "transport"})
+	}
+	return append(slots,
+		offlineSlot{"18:00", offlineCategoryRestaurant, "food"},
+		offlineSlot{"20:00", offlineCategoryHotel, "accommodation"},
+	)
+}
+
+// offlineBudgetBreakdown 按比例拆分总预算，各分类之和等于总预算
+func offlineBudgetBreakdown(budget float64, withAccommodation bool) map[string]float64 {
+	shares := make(map[string]float64)
+	var sum float64
+	for category, share := range offlineBudgetShares {
+		if category == "accommodation" && !withAccommodation {
+			continue
+		}
+		shares[category] = share
+		sum += share
+	}
+
+	breakdown := make(map[string]float64)
+	remaining := roundMoney(budget)
+	// 固定顺序分配，最后一个分类承担舍入误差
+	order := []string{"accommodation", "food", "attractions", "transport"}
+	for i, category := range order {
+		share, ok := shares[category]
+		if !ok {
+			continue
+		}
+		if i == len(order)-1 {
+			breakdown[category] = roundMoney(remaining)
+			break
+		}
+		amount := roundMoney(budget * share / sum)
+		breakdown[category] = amount
+		remaining -= amount
+	}
+	return breakdown
+}
+
+// splitMoney 将金额平均分成 n 份，最后一份承担舍入误差
+func splitMoney(amount float64, n int) []float64 {
+	if n <= 0 {
+		return nil
+	}
+	parts := make([]float64, n)
+	each := roundMoney(amount / float64(n))
+	for i := 0; i < n-1; i++ {
+		parts[i] = each
+	}
+	parts[n-1] = roundMoney(amount - each*float64(n-1))
+	return parts
+}
+
+// candidates 获取目的地某一分类的候选地点：缓存的高德结果 > 实时高德搜索 > 内置数据 > 通用模板
+func (p *OfflinePlanner) candidates(amap *AmapService, destination, category string, text offlineText) []offlinePOI {
+	key := destination + "|" + category
+	p.mutex.RLock()
+	cached := p.pois[key]
+	p.mutex.RUnlock()
+	if len(cached) > 0 {
+		return cached
+	}
+
+	if pois := p.searchAmap(amap, destination, category); len(pois) > 0 {
+		p.mutex.Lock()
+		p.pois[key] = pois
+		p.mutex.Unlock()
+		return pois
+	}
+
+	city := strings.TrimSuffix(strings.TrimSpace(destination), "市")
+	if pois := offlineDataset[city][category]; len(pois) > 0 {
+		return pois
+	}
+
+	var generic []offlinePOI
+	for _, name := range text.generic[category] {
+		generic = append(generic, offlinePOI{Name: fmt.Sprintf(name, destination)})
+	}
+	return generic
+}
+
+// searchAmap 从高德搜索目的地的POI，未配置或请求失败时返回空
+func (p *OfflinePlanner) searchAmap(amap *AmapService, destination, category string) []offlinePOI {
+	if !amap.Available() {
+		return nil
+	}
+	query := offlinePOIQueries[category]
+	resp, err := amap.SearchPOI(query[0], destination, query[1])
+	if err != nil {
+		log.Printf("离线规划获取高德POI失败(%s %s): %v", destination, category, err)
+		return nil
+	}
+
+	var pois []offlinePOI
+	for _, poi := range resp.Pois {
+		lng, lat, _ := poi.Coordinates()
+		pois = append(pois, offlinePOI{ID: poi.ID, Name: poi.Name, Address: poi.Address, Longitude: lng, Latitude: lat})
+	}
+	return pois
+}
+
+// applyOfflinePOI 将地点信息写入活动
+func applyOfflinePOI(activity *Activity, poi offlinePOI) {
+	activity.Title = poi.Name
+	activity.Location = orDefault(poi.Address, poi.Name)
+	activity.POIID = poi.ID
+	activity.Address = poi.Address
+	activity.Longitude = poi.Longitude
+	activity.Latitude = poi.Latitude
+	activity.CoordinateSystem = coord.GCJ02 // 内置数据和高德POI均为 GCJ-02
+}
+
+// offlineText 离线规划器使用的文案
+type offlineText struct {
+	visit, meal, hotel, transport string
+	arrive, depart                string
+	generic                       map[string][]string // 分类 -> 通用地点名称模板（%s 为目的地）
+	recommendations               []string
+}
+
+// offlineTextFor 中文语言使用中文文案，其余语言使用英文文案
+func offlineTextFor(locale string) offlineText {
+	if strings.HasPrefix(locale, "zh") || locale == "" {
+		return offlineText{
+			visit:     "游览%s，建议提前查看开放时间并预约门票",
+			meal:      "品尝当地特色菜",
+			hotel:     "入住酒店休息",
+			transport: "城市间交通，建议提前购票",
+			arrive:    "抵达%s",
+			depart:    "离开%s返程",
+			generic: map[string][]string{
+				offlineCategoryAttraction: {"%s博物馆", "%s老城区", "%s城市公园", "%s地标建筑", "%s历史街区", "%s观景台"},
+				offlineCategoryRestaurant: {"%s特色餐厅", "%s小吃街", "%s本地家常菜馆"},
+				offlineCategoryHotel:      {"%s市中心酒店"},
+			},
+			recommendations: []string{
+				"本行程由离线规划器按规则生成，出发前请确认景点开放时间和票价",
+				"预算按住宿35%、餐饮25%、景点20%、交通20%的比例分配",
+				"每天安排两个景点并留出午休时间，可根据体力自行调整",
+			},
+		}
+	}
+	return offlineText{
+		visit:     "Visit %s; check opening hours and book tickets in advance",
+		meal:      "Try local specialties",
+		hotel:     "Check in and rest at the hotel",
+		transport: "Intercity transport; book tickets in advance",
+		arrive:    "Arrive in %s",
+		depart:    "Depart from %s",
+		generic: map[string][]string{
+			offlineCategoryAttraction: {"%s Museum", "%s Old Town", "%s City Park", "%s Landmark", "%s Historic District", "%s Viewpoint"},
+			offlineCategoryRestaurant: {"%s Local Restaurant", "%s Street Food Market", "%s Family Kitchen"},
+			offlineCategoryHotel:      {"%s Downtown Hotel"},
+		},
+		recommendations: []string{
+			"This itinerary was generated by the offline rule-based planner; confirm opening hours and prices before you go",
+			"The budget is split 35% accommodation, 25% food, 20% attractions and 20% transport",
+			"Two sights per day with a midday break; adjust to your own pace",
+		},
+	}
+}
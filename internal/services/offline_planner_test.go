@@ -0,0 +1,92 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newOfflineRequest(destination string, days int, budget float64) *models.CreateTravelPlanRequest {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	return &models.CreateTravelPlanRequest{
+		Title:       "测试行程",
+		Destination: destination,
+		StartDate:   models.DateOnly{Time: start},
+		EndDate:     models.DateOnly{Time: start.AddDate(0, 0, days-1)},
+		Budget:      budget,
+		People:      2,
+	}
+}
+
+func TestOfflinePlanner_GeneratePlan(t *testing.T) {
+	planner := NewOfflinePlanner(true, nil)
+	result, err := planner.GeneratePlan(newOfflineRequest("杭州市", 3, 3000.01), "zh-CN")
+	if err != nil {
+		t.Fatalf("GeneratePlan failed: %v", err)
+	}
+
+	if result.Generator != PlanGeneratorOffline || len(result.Days) != 3 {
+		t.Fatalf("Unexpected result: generator=%s days=%d", result.Generator, len(result.Days))
+	}
+	if result.Days[2].Date != "2025-05-03" {
+		t.Errorf("Unexpected last day date: %s", result.Days[2].Date)
+	}
+
+	// 预算分配与活动费用之和都应等于总预算
+	var breakdownTotal, activityTotal float64
+	for _, amount := range result.Budget.Breakdown {
+		breakdownTotal += amount
+	}
+	seen := make(map[string]bool)
+	for _, day := range result.Days {
+		for _, activity := range day.Activities {
+			activityTotal += activity.Cost
+			if activity.Type == offlineCategoryAttraction {
+				if seen[activity.Title] {
+					t.Errorf("Attraction repeated: %s", activity.Title)
+				}
+				seen[activity.Title] = true
+				if activity.Latitude == 0 || activity.Longitude == 0 {
+					t.Errorf("Bundled attraction should have coordinates: %+v", activity)
+				}
+			}
+		}
+	}
+	if math.Abs(breakdownTotal-3000.01) > 0.001 || math.Abs(activityTotal-3000.01) > 0.001 {
+		t.Errorf("Budget not preserved: breakdown=%.2f activities=%.2f", breakdownTotal, activityTotal)
+	}
+
+	first, last := result.Days[0].Activities, result.Days[2].Activities
+	if first[0].Type != "transport" || last[len(last)-1].Type != "transport" {
+		t.Errorf("Expected arrival on the first day and departure on the last day")
+	}
+	if last[len(last)-2].Type == offlineCategoryHotel {
+		t.Errorf("Last day should not include a hotel night")
+	}
+}
+
+func TestOfflinePlanner_DayTripAndUnknownDestination(t *testing.T) {
+	planner := NewOfflinePlanner(false, nil)
+	result, err := planner.GeneratePlan(newOfflineRequest("Reykjavik", 1, 500), "en-US")
+	if err != nil {
+		t.Fatalf("GeneratePlan failed: %v", err)
+	}
+
+	if _, ok := result.Budget.Breakdown["accommodation"]; ok {
+		t.Errorf("Day trip should not budget accommodation: %v", result.Budget.Breakdown)
+	}
+	for _, activity := range result.Days[0].Activities {
+		if activity.Type == offlineCategoryAttraction && !strings.HasPrefix(activity.Title, "Reykjavik ") {
+			t.Errorf("Expected generic English attraction, got %q", activity.Title)
+		}
+	}
+	if !strings.Contains(result.Recommendations[0], "offline") {
+		t.Errorf("Expected English recommendations, got %v", result.Recommendations)
+	}
+
+	if _, err := planner.GeneratePlan(newOfflineRequest("杭州", 0, 500), "zh-CN"); err == nil {
+		t.Errorf("Expected error when end date is before start date")
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// UsageEndpointGroundedPlanGeneration 基于高德POI的工具调用式行程生成
+const UsageEndpointGroundedPlanGeneration = "grounded_plan_generation"
+
+// maxPlanToolRounds 单次生成允许的最多工具调用轮数，防止模型无限循环
+const maxPlanToolRounds = 12
+
+// maxToolPOIs 每次POI搜索返回给模型的最多结果数
+const maxToolPOIs = 8
+
+// GenerateGroundedTravelPlanWithKey 让模型在规划过程中调用地图服务的POI搜索、地理编码和距离计算工具，
+// 并将最终的活动与真实POI对应（POI ID、地址、坐标）
+func (s *LLMService) GenerateGroundedTravelPlanWithKey(meta LLMCallMeta, request *models.CreateTravelPlanRequest, maps MapProvider, apiKey, baseURL string) (*TravelPlanResult, error) {
+	meta.PromptVersions = make(map[string]string)
+	meta.Endpoint = UsageEndpointGroundedPlanGeneration
+	data, err := s.travelPromptData(meta, request)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.renderPrompt(meta, "travel_plan_grounded", data)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt, err := s.renderPrompt(meta, "system", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := newPOITools(maps, request.Destination)
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+
+	for round := 0; round < maxPlanToolRounds; round++ {
+		reply, err := s.chatCompletionWithKey(meta, messages, tools.definitions(), apiKey, baseURL, request.OpenAIModel)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(reply.ToolCalls) == 0 {
+			response := extractJSON(reply.Content)
+			var result TravelPlanResult
+			if err := json.Unmarshal([]byte(response), &result); err != nil {
+				return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+			}
+			tools.ground(&result)
+			result.PromptVersions = meta.PromptVersions
+			result.Generator = PlanGeneratorLLM
+			result.Provider, result.Model = s.ModelInfo(baseURL, request.OpenAIModel)
+			return &result, nil
+		}
+
+		messages = append(messages, *reply)
+		for _, call := range reply.ToolCalls {
+			messages = append(messages, Message{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    tools.call(call),
+			})
+		}
+	}
+
+	return nil, fmt.Errorf("LLM did not finish planning within %d tool rounds", maxPlanToolRounds)
+}
+
+// poiTools 由地图服务支撑的模型工具，并记录模型见过的POI用于最终校验
+type poiTools struct {
+	maps MapProvider
+	city string
+	seen map[string]POI // POI ID -> POI
+}
+
+func newPOITools(maps MapProvider, city string) *poiTools {
+	return &poiTools{maps: maps, city: city, seen: make(map[string]POI)}
+}
+
+// definitions 返回工具的 JSON Schema 定义
+func (t *poiTools) definitions() []OpenAITool {
+	str := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": description}
+	}
+	return []OpenAITool{
+		{Type: "function", Function: OpenAIFunction{
+			Name:        "search_poi",
+			Description: "按关键词搜索目的地的真实地点（景点、餐厅、酒店等），返回 POI ID、名称、地址、坐标和人均消费",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"keyword": str("搜索关键词，如 \"西湖\"、\"杭帮菜\""),
+					"city":    str("城市，默认使用行程目的地"),
+					"types":   str("可选的高德POI类型，如 \"风景名胜\"、\"餐饮服务\""),
+				},
+				"required": []string{"keyword"},
+			},
+		}},
+		{Type: "function", Function: OpenAIFunction{
+			Name:        "geocode",
+			Description: "将地址转换为坐标（经度,纬度）",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"address": str("详细地址")},
+				"required":   []string{"address"},
+			},
+		}},
+		{Type: "function", Function: OpenAIFunction{
+			Name:        "calculate_distance",
+			Description: "计算两个坐标之间的距离（米）和耗时（秒），用于检查行程是否顺路",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"origin":      str("起点坐标，格式 \"经度,纬度\""),
+					"destination": str("终点坐标，格式 \"经度,纬度\""),
+					"mode":        str("0 直线距离，1 驾车距离，3 步行距离，默认 1"),
+				},
+				"required": []string{"origin", "destination"},
+			},
+		}},
+	}
+}
+
+// call 执行一次工具调用，错误以JSON形式返回给模型，便于其调整参数重试
+func (t *poiTools) call(call ToolCall) string {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &raw); err != nil {
+		return toolError(fmt.Errorf("invalid arguments: %v", err))
+	}
+	// 模型偶尔会把数字参数（如 mode）传成数字，统一转为字符串
+	args := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if value != nil {
+			args[key] = fmt.Sprint(value)
+		}
+	}
+
+	var result interface{}
+	var err error
+	switch call.Function.Name {
+	case "search_poi":
+		result, err = t.searchPOI(args["keyword"], orDefault(args["city"], t.city), args["types"])
+	case "geocode":
+		result, err = t.geocode(args["address"])
+	case "calculate_distance":
+		result, err = t.distance(args["origin"], args["destination"], orDefault(args["mode"], "1"))
+	default:
+		err = fmt.Errorf("unknown tool %s", call.Function.Name)
+	}
+	if err != nil {
+		return toolError(err)
+	}
+
+	data, _ := json.Marshal(result)
+	return string(data)
+}
+
+// toolPOI 返回给模型的POI精简结构
+type toolPOI struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Address  string  `json:"address"`
+	Location string  `json:"location"`
+	AvgCost  float64 `json:"avg_cost,omitempty"`
+}
+
+func (t *poiTools) searchPOI(keyword, city, types string) ([]toolPOI, error) {
+	if keyword == "" {
+		return nil, fmt.Errorf("keyword is required")
+	}
+	resp, err := t.maps.SearchPOI(keyword, city, types)
+	if err != nil {
+		return nil, err
+	}
+
+	pois := []toolPOI{}
+	for _, poi := range resp.Pois {
+		if len(pois) >= maxToolPOIs {
+			break
+		}
+		t.seen[poi.ID] = poi
+		pois = append(pois, toolPOI{
+			ID:       poi.ID,
+			Name:     poi.Name,
+			Type:     poi.Type,
+			Address:  poi.Address,
+			Location: poi.Location,
+			AvgCost:  poi.AverageCost(),
+		})
+	}
+	return pois, nil
+}
+
+func (t *poiTools) geocode(address string) (interface{}, error) {
+	if address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	resp, err := t.maps.Geocode(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Geocodes) == 0 {
+		return nil, fmt.Errorf("address not found")
+	}
+	geocode := resp.Geocodes[0]
+	return map[string]string{
+		"formatted_address": geocode.FormattedAddress,
+		"location":          geocode.Location,
+	}, nil
+}
+
+func (t *poiTools) distance(origin, destination, mode string) (interface{}, error) {
+	if origin == "" || destination == "" {
+		return nil, fmt.Errorf("origin and destination are required")
+	}
+	resp, err := t.maps.CalculateDistance(origin, destination, mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, fmt.Errorf("no distance result")
+	}
+	return map[string]string{
+		"distance_meters":  resp.Results[0].Distance,
+		"duration_seconds": resp.Results[0].Duration,
+	}, nil
+}
+
+// ground 用真实POI数据覆盖模型给出的地点信息：
+// 模型引用了搜索过的 POI ID 时直接使用该POI；否则按地点名称补搜一次，名称足够接近时使用。
+// 找不到可信POI的活动清空位置字段、标记为 unresolved，并记录在 UngroundedActivities 中
+func (t *poiTools) ground(result *TravelPlanResult) {
+	result.UngroundedActivities = nil
+	for d := range result.Days {
+		for i := range result.Days[d].Activities {
+			activity := &result.Days[d].Activities[i]
+
+			confidence := 1.0
+			poi, ok := t.seen[activity.POIID]
+			if !ok {
+				poi, confidence, ok = t.lookup(activity)
+			}
+			if !ok {
+				activity.POIID, activity.Address = "", ""
+				activity.Latitude, activity.Longitude = 0, 0
+				activity.CoordinateSystem = ""
+				activity.GeocodeStatus, activity.GeocodeConfidence = models.GeocodeStatusUnresolved, 0
+				result.UngroundedActivities = append(result.UngroundedActivities, UngroundedActivity{
+					Day:      result.Days[d].Day,
+					Time:     activity.Time,
+					Title:    activity.Title,
+					Location: activity.Location,
+				})
+				continue
+			}
+
+			activity.POIID = poi.ID
+			activity.Address = poi.Address
+			activity.Location = poi.Name
+			activity.Longitude, activity.Latitude, _ = poi.Coordinates()
+			activity.CoordinateSystem = t.maps.Datum()
+			activity.GeocodeStatus, activity.GeocodeConfidence = models.GeocodeStatusResolved, confidence
+			if activity.Cost == 0 {
+				activity.Cost = poi.AverageCost()
+			}
+		}
+	}
+}
+
+// lookup 按活动的地点或标题搜索POI，取名称最接近的一个；名称相似度低于 geocodeResolvedConfidence 时视为没有找到
+func (t *poiTools) lookup(activity *Activity) (POI, float64, bool) {
+	location, title := strings.TrimSpace(activity.Location), strings.TrimSpace(activity.Title)
+	keyword := orDefault(location, title)
+	if keyword == "" {
+		return POI{}, 0, false
+	}
+	resp, err := t.maps.SearchPOI(keyword, t.city, "")
+	if err != nil {
+		return POI{}, 0, false
+	}
+
+	var best POI
+	var bestConfidence float64
+	for i, poi := range resp.Pois {
+		if i >= geocodeMaxPOICandidates {
+			break
+		}
+		if _, _, ok := poi.Coordinates(); !ok {
+			continue
+		}
+		confidence := math.Max(nameConfidence(location, poi.Name), nameConfidence(title, poi.Name))
+		if confidence > bestConfidence {
+			best, bestConfidence = poi, confidence
+		}
+	}
+	if bestConfidence < geocodeResolvedConfidence {
+		return POI{}, 0, false
+	}
+	t.seen[best.ID] = best
+	return best, bestConfidence, true
+}
+
+// toolError 将错误编码为工具调用结果
+func toolError(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(data)
+}
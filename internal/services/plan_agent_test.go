@@ -0,0 +1,128 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"ai-travel-planner/internal/prompts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newGroundedTestServer 模拟 OpenAI 和高德接口：第一轮模型调用 search_poi，第二轮返回最终行程
+func newGroundedTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/place/text", func(w http.ResponseWriter, r *http.Request) {
+		pois := map[string]string{
+			"西湖":   `{"id":"B001","name":"西湖风景名胜区","type":"风景名胜","location":"120.14,30.24","address":"龙井路1号","biz_ext":[]}`,
+			"灵隐寺":  `{"id":"B002","name":"灵隐寺","type":"寺庙","location":"120.10,30.24","address":"法云弄1号","biz_ext":{"cost":"75.00"}}`,
+			"断桥残雪": `{"id":"B003","name":"湖滨银泰in77","type":"购物中心","location":"120.16,30.26","address":"东坡路7号","biz_ext":[]}`,
+		}
+		poi, ok := pois[r.URL.Query().Get("keywords")]
+		if !ok {
+			w.Write([]byte(`{"status":"1","count":"0","pois":[]}`))
+			return
+		}
+		w.Write([]byte(`{"status":"1","count":"1","pois":[` + poi + `]}`))
+	})
+
+	calls := 0
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) == 0 {
+			t.Errorf("Expected tools in request")
+		}
+		calls++
+
+		var message map[string]interface{}
+		if calls == 1 {
+			message = map[string]interface{}{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]interface{}{{
+					"id": "call-1", "type": "function",
+					"function": map[string]string{"name": "search_poi", "arguments": `{"keyword":"西湖"}`},
+				}},
+			}
+		} else {
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role != "tool" || last.ToolCallID != "call-1" {
+				t.Errorf("Expected tool result as last message, got %+v", last)
+			}
+			plan := `{"days":[{"day":1,"date":"2025-05-01","activities":[` +
+				`{"time":"09:00","title":"游西湖","location":"西湖","poi_id":"B001","cost":0,"type":"attraction"},` +
+				`{"time":"14:00","title":"灵隐寺","location":"灵隐寺","poi_id":"made-up","type":"attraction"},` +
+				`{"time":"16:00","title":"看断桥","location":"断桥残雪","type":"attraction"},` +
+				`{"time":"18:00","title":"返程","location":"不存在的地方","poi_id":"made-up-2","type":"transport"}]}],` +
+				`"budget":{"total":1000},"recommendations":[]}`
+			message = map[string]interface{}{"role": "assistant", "content": plan}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": message}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLLMService_GenerateGroundedTravelPlan(t *testing.T) {
+	server := newGroundedTestServer(t)
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.APIs.Amap.APIKey = "amap-key"
+	cfg.APIs.Amap.BaseURL = server.URL
+	store, err := prompts.NewStore(config.PromptsConfig{Dir: "../../prompts"})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	llmService := NewLLMService(cfg, store, nil, nil, nil, nil)
+
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	req := &models.CreateTravelPlanRequest{
+		Destination: "杭州",
+		StartDate:   models.DateOnly{Time: start},
+		EndDate:     models.DateOnly{Time: start},
+		Budget:      1000,
+		People:      1,
+	}
+	result, err := llmService.GenerateGroundedTravelPlanWithKey(LLMCallMeta{UserID: "user-1"}, req, NewAmapService(cfg), "sk-test", server.URL)
+	if err != nil {
+		t.Fatalf("GenerateGroundedTravelPlanWithKey failed: %v", err)
+	}
+
+	activities := result.Days[0].Activities
+	if a := activities[0]; a.POIID != "B001" || a.Address != "龙井路1号" || a.Longitude != 120.14 || a.Location != "西湖风景名胜区" {
+		t.Errorf("Expected activity grounded to searched POI, got %+v", a)
+	}
+	// 模型编造的 POI ID 通过补搜纠正，并使用POI的人均消费
+	if a := activities[1]; a.POIID != "B002" || a.Latitude != 30.24 || a.Cost != 75 {
+		t.Errorf("Expected activity grounded by fallback search, got %+v", a)
+	}
+	if activities[0].GeocodeStatus != models.GeocodeStatusResolved || activities[1].GeocodeConfidence != 1 {
+		t.Errorf("Expected grounded activities to be resolved, got %+v, %+v", activities[0], activities[1])
+	}
+	// 补搜结果的名称不相符时不采用，保留模型给出的地点
+	if a := activities[2]; a.POIID != "" || a.Latitude != 0 || a.Location != "断桥残雪" || a.GeocodeStatus != models.GeocodeStatusUnresolved {
+		t.Errorf("Expected a poorly matching POI to be rejected, got %+v", a)
+	}
+	// 找不到真实地点时不保留编造的 POI ID
+	if a := activities[3]; a.POIID != "" || a.Latitude != 0 || a.GeocodeStatus != models.GeocodeStatusUnresolved {
+		t.Errorf("Expected ungrounded activity to have no POI, got %+v", a)
+	}
+	if ungrounded := result.UngroundedActivities; len(ungrounded) != 2 || ungrounded[0].Title != "看断桥" || ungrounded[1].Day != 1 {
+		t.Errorf("Expected ungrounded activities in the result, got %+v", ungrounded)
+	}
+	if record := activities[3].ToRecord("day-1", start); record.GeocodeStatus != models.GeocodeStatusUnresolved {
+		t.Errorf("Expected the saved activity to be marked unresolved, got %q", record.GeocodeStatus)
+	}
+	if result.PromptVersions["travel_plan_grounded"] == "" {
+		t.Errorf("Expected prompt version to be recorded, got %v", result.PromptVersions)
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// UsageEndpointPlanRebalance 行程超出预算时压缩费用
+const UsageEndpointPlanRebalance = "plan_rebalance"
+
+// OverBudgetRebalance 超出预算时让模型压缩费用（planner.over_budget）
+const OverBudgetRebalance = "rebalance"
+
+// planBudgetTolerance 费用核算允许的误差比例，避免模型的取整误差触发提醒
+const planBudgetTolerance = 0.02
+
+// activityBudgetCategories 活动类型对应的预算分类，未列出的类型计入 other
+var activityBudgetCategories = map[string]string{
+	"attraction": "attractions",
+	"restaurant": "food",
+	"hotel":      "accommodation",
+	"transport":  "transport",
+}
+
+// PlanBudgetCheck 按活动重新核算的行程费用
+type PlanBudgetCheck struct {
+	Budget        float64                `json:"budget"`
+	ActivityTotal float64                `json:"activity_total"`
+	PerPerson     float64                `json:"per_person"`
+	Categories    map[string]float64     `json:"categories"` // 预算分类 -> 活动费用之和
+	OverBudget    bool                   `json:"over_budget"`
+	Warnings      []models.BudgetWarning `json:"warnings"`
+}
+
+// Overage 活动费用超出预算的金额
+func (c *PlanBudgetCheck) Overage() float64 {
+	return math.Max(0, roundMoney(c.ActivityTotal-c.Budget))
+}
+
+// ValidatePlanBudget 按活动重新核算费用（活动费用为全部人数的合计）并与用户预算比对，提醒按计划语言生成。
+// 同时修正结果中的 budget.total（改为用户预算），breakdown 与活动费用不符时按活动重新计算
+func ValidatePlanBudget(result *TravelPlanResult, budget float64, people int, currency, locale string) *PlanBudgetCheck {
+	if people < 1 {
+		people = 1
+	}
+	check := &PlanBudgetCheck{
+		Budget:     roundMoney(budget),
+		Categories: make(map[string]float64),
+		Warnings:   []models.BudgetWarning{},
+	}
+	for _, day := range result.Days {
+		for _, activity := range day.Activities {
+			category, ok := activityBudgetCategories[activity.Type]
+			if !ok {
+				category = "other"
+			}
+			check.Categories[category] = roundMoney(check.Categories[category] + activity.Cost)
+			check.ActivityTotal += activity.Cost
+		}
+	}
+	check.ActivityTotal = roundMoney(check.ActivityTotal)
+	check.PerPerson = roundMoney(check.ActivityTotal / float64(people))
+	tolerance := math.Max(1, budget*planBudgetTolerance)
+
+	if check.ActivityTotal > budget+tolerance {
+		check.OverBudget = true
+		check.Warnings = append(check.Warnings, models.BudgetWarning{
+			Category: models.BudgetOverallCategory,
+			Severity: models.BudgetSeverityCritical,
+			Message: fmt.Sprintf(localizedText(locale,
+				"活动费用合计 %.2f %s（人均 %.2f），超出预算 %.2f %s（预算 %.2f %s）",
+				"Activity costs add up to %.2f %s (%.2f per person), %.2f %s over the budget of %.2f %s"),
+				check.ActivityTotal, currency, check.PerPerson, check.Overage(), currency, check.Budget, currency),
+		})
+	}
+
+	var breakdownTotal float64
+	for _, amount := range result.Budget.Breakdown {
+		breakdownTotal += amount
+	}
+	if math.Abs(breakdownTotal-check.ActivityTotal) > tolerance {
+		check.Warnings = append(check.Warnings, models.BudgetWarning{
+			Category: models.BudgetOverallCategory,
+			Severity: models.BudgetSeverityInfo,
+			Message: fmt.Sprintf(localizedText(locale,
+				"预算分配合计 %.2f %s，但活动费用合计 %.2f %s，已按活动重新计算预算分配",
+				"Budget breakdown added up to %.2f %s but activities add up to %.2f %s; the breakdown was recomputed from activities"),
+				roundMoney(breakdownTotal), currency, check.ActivityTotal, currency),
+		})
+		result.Budget.Breakdown = make(map[string]float64)
+		for category, amount := range check.Categories {
+			result.Budget.Breakdown[category] = amount
+		}
+	}
+	result.Budget.Total = check.Budget
+	return check
+}
+
+// PlanTreeBudgetCheck 按已保存行程的活动重新核算费用，提醒按 locale 生成。
+// 返回核算结果和预算分配的副本（与活动费用不符时已按活动重新计算），不修改原行程
+func PlanTreeBudgetCheck(tree *PlanTree, locale string) (*PlanBudgetCheck, map[string]float64) {
+	result := &TravelPlanResult{}
+	result.Budget.Breakdown = make(map[string]float64, len(tree.Plan.BudgetBreakdown))
+	for category, amount := range tree.Plan.BudgetBreakdown {
+		result.Budget.Breakdown[category] = amount
+	}
+	for _, day := range tree.Days {
+		dayPlan := DayPlan{Day: day.Day.DayNumber}
+		for _, activity := range day.Activities {
+			dayPlan.Activities = append(dayPlan.Activities, Activity{Type: activity.Type, Cost: activity.Cost})
+		}
+		result.Days = append(result.Days, dayPlan)
+	}
+	check := ValidatePlanBudget(result, tree.Plan.Budget, tree.Plan.People, planCurrency(tree.Plan), locale)
+	return check, result.Budget.Breakdown
+}
+
+// RebalanceEnabled 是否在行程超出预算时让模型压缩费用
+func (s *LLMService) RebalanceEnabled() bool {
+	return s.config.Planner.OverBudget == OverBudgetRebalance
+}
+
+// RebalancePlanBudgetWithKey 将超出预算的行程交给模型压缩费用，返回的新行程天数和日期与原行程一致且费用更低
+func (s *LLMService) RebalancePlanBudgetWithKey(meta LLMCallMeta, request *models.CreateTravelPlanRequest, result *TravelPlanResult, check *PlanBudgetCheck, apiKey, baseURL string) (*TravelPlanResult, error) {
+	meta.PromptVersions = make(map[string]string)
+	meta.Endpoint = UsageEndpointPlanRebalance
+	destination, err := s.userInput(meta, "destination", request.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	current := *result
+	current.PromptVersions = nil
+	current.Generator, current.Provider, current.Model = "", "", ""
+	plan, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.renderPrompt(meta, "plan_rebalance", map[string]interface{}{
+		"Destination":   destination,
+		"Budget":        request.Budget,
+		"People":        request.People,
+		"Currency":      CurrencyForLocale(ResolveLocale(meta.Locale)),
+		"ActivityTotal": check.ActivityTotal,
+		"Overage":       check.Overage(),
+		"Plan":          string(plan),
+		"Language":      LocaleName(ResolveLocale(meta.Locale)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, request.OpenAIModel)
+	if err != nil {
+		return nil, err
+	}
+	response = extractJSON(response)
+	var rebalanced TravelPlanResult
+	if err := json.Unmarshal([]byte(response), &rebalanced); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+
+	if !sameDays(result, &rebalanced) {
+		return nil, fmt.Errorf("rebalanced plan changed the days of the trip")
+	}
+	if planActivityTotal(&rebalanced) >= check.ActivityTotal {
+		return nil, fmt.Errorf("rebalanced plan did not reduce costs")
+	}
+
+	// 生成时的模板版本与本次压缩使用的版本一并记录
+	rebalanced.PromptVersions = make(map[string]string)
+	for name, version := range result.PromptVersions {
+		rebalanced.PromptVersions[name] = version
+	}
+	for name, version := range meta.PromptVersions {
+		rebalanced.PromptVersions[name] = version
+	}
+	rebalanced.Generator = result.Generator
+	rebalanced.Provider, rebalanced.Model = result.Provider, result.Model
+	return &rebalanced, nil
+}
+
+// sameDays 判断两个行程的天数和日期是否一致，且每天都有活动
+func sameDays(a, b *TravelPlanResult) bool {
+	if len(a.Days) != len(b.Days) {
+		return false
+	}
+	days := func(result *TravelPlanResult) []string {
+		var keys []string
+		for _, day := range result.Days {
+			keys = append(keys, fmt.Sprintf("%d|%s", day.Day, day.Date))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	aDays, bDays := days(a), days(b)
+	for i := range aDays {
+		if aDays[i] != bDays[i] || len(b.Days[i].Activities) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// planActivityTotal 行程所有活动的费用之和
+func planActivityTotal(result *TravelPlanResult) float64 {
+	var total float64
+	for _, day := range result.Days {
+		for _, activity := range day.Activities {
+			total += activity.Cost
+		}
+	}
+	return roundMoney(total)
+}
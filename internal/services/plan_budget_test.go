@@ -0,0 +1,90 @@
+package services
+
+import (
+	"ai-travel-planner/internal/fakeopenai"
+	"ai-travel-planner/internal/models"
+	"strings"
+	"testing"
+)
+
+func newOverBudgetResult() *TravelPlanResult {
+	result := &TravelPlanResult{Generator: PlanGeneratorLLM, PromptVersions: map[string]string{"travel_plan": "v1"}}
+	result.Days = []DayPlan{
+		{Day: 1, Date: "2025-05-01", Activities: []Activity{
+			{Time: "09:00", Title: "景点", Type: "attraction", Cost: 400},
+			{Time: "12:00", Title: "午餐", Type: "restaurant", Cost: 600},
+			{Time: "20:00", Title: "酒店", Type: "hotel", Cost: 1800},
+		}},
+		{Day: 2, Date: "2025-05-02", Activities: []Activity{
+			{Time: "10:00", Title: "购物", Type: "shopping", Cost: 500},
+		}},
+	}
+	result.Budget.Total = 2000
+	result.Budget.Breakdown = map[string]float64{"accommodation": 1000, "food": 500, "attractions": 500}
+	return result
+}
+
+func TestValidatePlanBudget(t *testing.T) {
+	result := newOverBudgetResult()
+	check := ValidatePlanBudget(result, 3000, 2, "CNY", "zh-CN")
+
+	if check.ActivityTotal != 3300 || check.PerPerson != 1650 || !check.OverBudget || check.Overage() != 300 {
+		t.Fatalf("Unexpected check: %+v", check)
+	}
+	if check.Categories["other"] != 500 || check.Categories["accommodation"] != 1800 {
+		t.Errorf("Unexpected categories: %v", check.Categories)
+	}
+	if len(check.Warnings) != 2 || check.Warnings[0].Severity != models.BudgetSeverityCritical {
+		t.Fatalf("Expected over-budget and breakdown warnings, got %+v", check.Warnings)
+	}
+	if result.Budget.Total != 3000 || result.Budget.Breakdown["accommodation"] != 1800 {
+		t.Errorf("Totals not corrected: %+v", result.Budget)
+	}
+	if message := check.Warnings[0].Message; message != "活动费用合计 3300.00 CNY（人均 1650.00），超出预算 300.00 CNY（预算 3000.00 CNY）" {
+		t.Errorf("Unexpected Chinese warning: %s", message)
+	}
+	if english := ValidatePlanBudget(newOverBudgetResult(), 3000, 2, "USD", "en-US"); !strings.HasPrefix(english.Warnings[0].Message, "Activity costs add up to 3300.00 USD") {
+		t.Errorf("Unexpected English warning: %s", english.Warnings[0].Message)
+	}
+
+	// 在误差范围内且分配一致时没有提醒
+	consistent := newOverBudgetResult()
+	consistent.Budget.Breakdown = map[string]float64{"total": 3310}
+	if check := ValidatePlanBudget(consistent, 3280, 2, "CNY", "zh-CN"); check.OverBudget || len(check.Warnings) != 0 {
+		t.Errorf("Expected no warnings within tolerance, got %+v", check.Warnings)
+	}
+}
+
+func TestLLMService_RebalancePlanBudgetWithKey(t *testing.T) {
+	llmService, _, fake := newPipelineTestService(t)
+	meta := LLMCallMeta{UserID: "user-1", Locale: "zh-CN"}
+	request := newPipelineTestRequest()
+	result := newOverBudgetResult()
+	check := ValidatePlanBudget(result, request.Budget, request.People, "CNY", "zh-CN")
+
+	cheaper := `{"days":[` +
+		`{"day":1,"date":"2025-05-01","activities":[{"time":"09:00","title":"免费景点","type":"attraction","cost":0},{"time":"20:00","title":"青旅","type":"hotel","cost":600}]},` +
+		`{"day":2,"date":"2025-05-02","activities":[{"time":"10:00","title":"逛街","type":"shopping","cost":100}]}],` +
+		`"budget":{"total":3000,"breakdown":{"accommodation":600,"other":100}},"recommendations":["改住青旅"]}`
+	fake.Enqueue(fakeopenai.Response{Content: cheaper, Fault: fakeopenai.FaultCodeFence})
+
+	rebalanced, err := llmService.RebalancePlanBudgetWithKey(meta, request, result, check, "sk-user", "")
+	if err != nil {
+		t.Fatalf("RebalancePlanBudgetWithKey failed: %v", err)
+	}
+	if planActivityTotal(rebalanced) != 700 || rebalanced.Generator != PlanGeneratorLLM {
+		t.Errorf("Unexpected rebalanced plan: %+v", rebalanced)
+	}
+	if rebalanced.PromptVersions["travel_plan"] != "v1" || rebalanced.PromptVersions["plan_rebalance"] != "v2" {
+		t.Errorf("Prompt versions not merged: %v", rebalanced.PromptVersions)
+	}
+	if prompt := fake.Requests()[0].Messages[1].Content; !strings.Contains(prompt, "超出 300.00") {
+		t.Errorf("Prompt should include the overage: %s", prompt)
+	}
+
+	// 改变了天数的结果不被接受
+	fake.Enqueue(fakeopenai.Response{Content: `{"days":[{"day":1,"date":"2025-05-01","activities":[{"title":"x","cost":1}]}]}`})
+	if _, err := llmService.RebalancePlanBudgetWithKey(meta, request, result, check, "sk-user", ""); err == nil {
+		t.Errorf("Expected error when the rebalanced plan drops a day")
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"ai-travel-planner/internal/coord"
+	"ai-travel-planner/internal/models"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 行程导出格式
+const (
+	ExportFormatGeoJSON = "geojson"
+	ExportFormatKML     = "kml"
+	ExportFormatGPX     = "gpx"
+)
+
+// PlanExport 导出用的行程：按天分组的已定位活动及相邻活动之间的路线
+type PlanExport struct {
+	Plan             *models.TravelPlan
+	CoordinateSystem string // 导出的坐标系
+	Days             []ExportDay
+	MissingRoutes    int // 没有查到路线的相邻活动对数
+}
+
+// ExportDay 导出的一天
+type ExportDay struct {
+	DayNumber int
+	Date      time.Time
+	Waypoints []*models.Activity
+	Tracks    []ExportTrack
+}
+
+// ExportTrack 两个相邻活动之间的路线轨迹
+type ExportTrack struct {
+	From            *models.Activity
+	To              *models.Activity
+	Mode            string
+	DistanceMeters  int
+	DurationSeconds int
+	Points          [][2]float64 // [经度, 纬度]
+}
+
+// BuildPlanExport 收集行程中已定位的活动，并查询每天相邻活动之间的路线轨迹（出行方式与可行性检查相同），
+// 活动和轨迹的坐标转换到 datum 坐标系（为空时保持原样）。
+// provider 为 nil 时只导出活动；路线查询失败或没有轨迹时跳过该段，计入 MissingRoutes
+func (s *TravelService) BuildPlanExport(tree *PlanTree, provider MapProvider, datum string) *PlanExport {
+	export := &PlanExport{Plan: tree.Plan, CoordinateSystem: datum, Days: []ExportDay{}}
+	for _, day := range tree.Days {
+		exportDay := ExportDay{DayNumber: day.Day.DayNumber, Date: day.Day.Date, Waypoints: []*models.Activity{}, Tracks: []ExportTrack{}}
+		for _, activity := range day.Activities {
+			if hasCoordinates(activity) {
+				exportDay.Waypoints = append(exportDay.Waypoints, ActivityInDatum(activity, datum))
+			}
+		}
+		for i := 1; provider != nil && i < len(exportDay.Waypoints); i++ {
+			from, to := exportDay.Waypoints[i-1], exportDay.Waypoints[i]
+			track, ok := routeTrack(provider, s.travelMode(tree.Plan, from, to), from, to, tree.Plan.Destination, datum)
+			if !ok {
+				export.MissingRoutes++
+				continue
+			}
+			exportDay.Tracks = append(exportDay.Tracks, track)
+		}
+		export.Days = append(export.Days, exportDay)
+	}
+	return export
+}
+
+// routeTrack 查询两个活动之间的路线并解析轨迹（转换到 datum 坐标系），公交查询失败时改为驾车
+func routeTrack(provider MapProvider, mode string, from, to *models.Activity, city, datum string) (ExportTrack, bool) {
+	origin, destination := ActivityLocation(from, provider.Datum()), ActivityLocation(to, provider.Datum())
+	response, err := PlanRoute(provider, mode, origin, destination, city)
+	if err != nil && mode == RouteModeTransit {
+		mode = RouteModeDriving
+		response, err = PlanRoute(provider, mode, origin, destination, city)
+	}
+	if err != nil {
+		return ExportTrack{}, false
+	}
+	routes := NormalizeRoutes(provider.Name(), mode, response)
+	if len(routes) == 0 {
+		return ExportTrack{}, false
+	}
+	points := routePolyline(routes[0])
+	if len(points) < 2 {
+		return ExportTrack{}, false
+	}
+
+	track := ExportTrack{From: from, To: to, Mode: mode, DistanceMeters: routes[0].DistanceMeters, DurationSeconds: routes[0].DurationSeconds}
+	for _, point := range points {
+		lng, lat := coord.Convert(point.lng, point.lat, provider.Datum(), datum)
+		track.Points = append(track.Points, [2]float64{lng, lat})
+	}
+	return track, true
+}
+
+// EncodePlanExport 按格式编码导出的行程，返回内容和 Content-Type
+func EncodePlanExport(export *PlanExport, format string) ([]byte, string, error) {
+	switch format {
+	case ExportFormatGeoJSON:
+		data, err := encodeGeoJSON(export)
+		return data, "application/geo+json", err
+	case ExportFormatKML:
+		data, err := encodeKML(export)
+		return data, "application/vnd.google-earth.kml+xml", err
+	case ExportFormatGPX:
+		data, err := encodeGPX(export)
+		return data, "application/gpx+xml", err
+	}
+	return nil, "", fmt.Errorf("unsupported export format %q", format)
+}
+
+// activityNotes 活动的说明：描述、备注和地址
+func activityNotes(activity *models.Activity) string {
+	address := activity.Address
+	if address == "" {
+		address = activity.Location
+	}
+	var parts []string
+	for _, part := range []string{activity.Description, activity.Notes, address} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// activityTimeRange 活动的时间段，如 "09:00-11:00"
+func activityTimeRange(activity *models.Activity) string {
+	if activity.StartTime.IsZero() {
+		return ""
+	}
+	if activity.EndTime.After(activity.StartTime) {
+		return activity.StartTime.Format("15:04") + "-" + activity.EndTime.Format("15:04")
+	}
+	return activity.StartTime.Format("15:04")
+}
+
+// formatExportTime 导出的时间（RFC 3339），零值为空
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// name 导出中一天的名称，如 "Day 1 (2025-05-01)"
+func (day ExportDay) name() string {
+	if day.Date.IsZero() {
+		return fmt.Sprintf("Day %d", day.DayNumber)
+	}
+	return fmt.Sprintf("Day %d (%s)", day.DayNumber, day.Date.Format("2006-01-02"))
+}
+
+// name 路线的名称，如 "西湖 → 灵隐寺"
+func (track ExportTrack) name() string {
+	return track.From.Title + " → " + track.To.Title
+}
+
+// summary 路线的方式、距离和时间，如 "walking, 1.2 km, 15 min"
+func (track ExportTrack) summary() string {
+	return fmt.Sprintf("%s, %.1f km, %d min", track.Mode, float64(track.DistanceMeters)/1000, (track.DurationSeconds+59)/60)
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// encodeGeoJSON 活动为 Point，路线为 LineString，属性中的 day 用于分组
+func encodeGeoJSON(export *PlanExport) ([]byte, error) {
+	features := []geoJSONFeature{}
+	for _, day := range export.Days {
+		for _, activity := range day.Waypoints {
+			properties := map[string]interface{}{
+				"kind":        "activity",
+				"id":          activity.ID,
+				"name":        activity.Title,
+				"day":         day.DayNumber,
+				"type":        activity.Type,
+				"time":        activityTimeRange(activity),
+				"start_time":  formatExportTime(activity.StartTime),
+				"end_time":    formatExportTime(activity.EndTime),
+				"description": activity.Description,
+				"notes":       activity.Notes,
+				"address":     orDefault(activity.Address, activity.Location),
+				"cost":        activity.Cost,
+			}
+			features = append(features, geoJSONFeature{
+				Type:       "Feature",
+				Geometry:   geoJSONGeometry{Type: "Point", Coordinates: [2]float64{activity.Longitude, activity.Latitude}},
+				Properties: properties,
+			})
+		}
+		for _, track := range day.Tracks {
+			features = append(features, geoJSONFeature{
+				Type:     "Feature",
+				Geometry: geoJSONGeometry{Type: "LineString", Coordinates: track.Points},
+				Properties: map[string]interface{}{
+					"kind":             "route",
+					"name":             track.name(),
+					"day":              day.DayNumber,
+					"from":             track.From.ID,
+					"to":               track.To.ID,
+					"mode":             track.Mode,
+					"distance_meters":  track.DistanceMeters,
+					"duration_seconds": track.DurationSeconds,
+				},
+			})
+		}
+	}
+
+	// 坐标较多，不缩进；说明中的 <、& 原样输出
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(map[string]interface{}{
+		"type": "FeatureCollection",
+		"name": export.Plan.Title,
+		// 非标准的成员，标明坐标系（RFC 7946 要求 WGS-84）
+		"coordinate_system": export.CoordinateSystem,
+		"features":          features,
+	})
+	return buffer.Bytes(), err
+}
+
+type kmlDocument struct {
+	XMLName xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name    string      `xml:"Document>name"`
+	Desc    string      `xml:"Document>description,omitempty"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string       `xml:"name"`
+	Description string       `xml:"description,omitempty"`
+	TimeSpan    *kmlTimeSpan `xml:"TimeSpan,omitempty"`
+	Data        []kmlData    `xml:"ExtendedData>Data,omitempty"`
+	Point       *kmlGeometry `xml:"Point,omitempty"`
+	LineString  *kmlGeometry `xml:"LineString,omitempty"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin,omitempty"`
+	End   string `xml:"end,omitempty"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlGeometry struct {
+	Tessellate  int    `xml:"tessellate,omitempty"`
+	Coordinates string `xml:"coordinates"`
+}
+
+// encodeKML 每天一个 Folder，包含活动的 Placemark 和路线的 LineString
+func encodeKML(export *PlanExport) ([]byte, error) {
+	document := kmlDocument{Name: export.Plan.Title, Desc: export.Plan.Destination}
+	for _, day := range export.Days {
+		folder := kmlFolder{Name: day.name()}
+		for _, activity := range day.Waypoints {
+			placemark := kmlPlacemark{
+				Name:        activity.Title,
+				Description: strings.TrimSpace(activityTimeRange(activity) + "\n" + activityNotes(activity)),
+				Data:        []kmlData{{Name: "id", Value: activity.ID}, {Name: "type", Value: activity.Type}},
+				Point:       &kmlGeometry{Coordinates: kmlCoordinate(activity.Longitude, activity.Latitude)},
+			}
+			if !activity.StartTime.IsZero() {
+				placemark.TimeSpan = &kmlTimeSpan{Begin: formatExportTime(activity.StartTime), End: formatExportTime(activity.EndTime)}
+			}
+			folder.Placemarks = append(folder.Placemarks, placemark)
+		}
+		for _, track := range day.Tracks {
+			coordinates := make([]string, 0, len(track.Points))
+			for _, point := range track.Points {
+				coordinates = append(coordinates, kmlCoordinate(point[0], point[1]))
+			}
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name:        track.name(),
+				Description: track.summary(),
+				Data:        []kmlData{{Name: "mode", Value: track.Mode}},
+				LineString:  &kmlGeometry{Tessellate: 1, Coordinates: strings.Join(coordinates, " ")},
+			})
+		}
+		document.Folders = append(document.Folders, folder)
+	}
+	return marshalXML(document)
+}
+
+// kmlCoordinate KML 坐标 "经度,纬度,0"
+func kmlCoordinate(lng, lat float64) string {
+	return strconv.FormatFloat(lng, 'f', 6, 64) + "," + strconv.FormatFloat(lat, 'f', 6, 64) + ",0"
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Name      string        `xml:"metadata>name"`
+	Desc      string        `xml:"metadata>desc,omitempty"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+// gpxWaypoint 元素顺序与 GPX 1.1 schema 一致
+type gpxWaypoint struct {
+	Lat     string `xml:"lat,attr"`
+	Lon     string `xml:"lon,attr"`
+	Time    string `xml:"time,omitempty"`
+	Name    string `xml:"name"`
+	Comment string `xml:"cmt,omitempty"`
+	Desc    string `xml:"desc,omitempty"`
+	Type    string `xml:"type,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat string `xml:"lat,attr"`
+	Lon string `xml:"lon,attr"`
+}
+
+// encodeGPX 活动为航点（type 为所在的天），每天的路线为一条轨迹，每段路线一个 trkseg
+func encodeGPX(export *PlanExport) ([]byte, error) {
+	document := gpxDocument{Version: "1.1", Creator: "ai-travel-planner", Name: export.Plan.Title, Desc: export.Plan.Destination}
+	for _, day := range export.Days {
+		for _, activity := range day.Waypoints {
+			document.Waypoints = append(document.Waypoints, gpxWaypoint{
+				Lat:     strconv.FormatFloat(activity.Latitude, 'f', 6, 64),
+				Lon:     strconv.FormatFloat(activity.Longitude, 'f', 6, 64),
+				Time:    formatExportTime(activity.StartTime),
+				Name:    activity.Title,
+				Comment: activityTimeRange(activity),
+				Desc:    activityNotes(activity),
+				Type:    day.name(),
+			})
+		}
+		if len(day.Tracks) == 0 {
+			continue
+		}
+		track := gpxTrack{Name: day.name()}
+		for _, route := range day.Tracks {
+			segment := gpxSegment{}
+			for _, point := range route.Points {
+				segment.Points = append(segment.Points, gpxPoint{
+					Lat: strconv.FormatFloat(point[1], 'f', 6, 64),
+					Lon: strconv.FormatFloat(point[0], 'f', 6, 64),
+				})
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+		document.Tracks = append(document.Tracks, track)
+	}
+	return marshalXML(document)
+}
+
+// marshalXML 带 XML 声明的缩进输出
+func marshalXML(document interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestBuildPlanExport(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, exportTestPlan)
+	provider := &stubMapProvider{minutes: func(from, to string) float64 { return 20 }}
+
+	export := travelService.BuildPlanExport(tree, provider, "")
+	if len(export.Days) != 2 || len(export.Days[0].Waypoints) != 2 || len(export.Days[0].Tracks) != 1 || len(export.Days[1].Tracks) != 0 || export.MissingRoutes != 0 {
+		t.Fatalf("Unexpected export: %+v", export)
+	}
+	track := export.Days[0].Tracks[0]
+	if track.From.ID != "act-1" || track.To.ID != "act-3" || track.Mode != RouteModeDriving || len(track.Points) != 2 || track.Points[1] != [2]float64{120.10, 30.25} {
+		t.Errorf("Unexpected track: %+v", track)
+	}
+
+	if withoutRoutes := travelService.BuildPlanExport(tree, nil, ""); len(withoutRoutes.Days[0].Tracks) != 0 || withoutRoutes.MissingRoutes != 0 {
+		t.Errorf("Expected no routes without a provider, got %+v", withoutRoutes.Days[0])
+	}
+	if failed := travelService.BuildPlanExport(tree, &stubMapProvider{}, ""); failed.MissingRoutes != 1 {
+		t.Errorf("Expected one missing route, got %d", failed.MissingRoutes)
+	}
+}
+
+func TestEncodePlanExport(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, exportTestPlan)
+	export := travelService.BuildPlanExport(tree, &stubMapProvider{minutes: func(from, to string) float64 { return 20 }}, "")
+
+	data, contentType, err := EncodePlanExport(export, ExportFormatGeoJSON)
+	if err != nil || contentType != "application/geo+json" {
+		t.Fatalf("GeoJSON export failed: %v", err)
+	}
+	var geoJSON struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &geoJSON); err != nil || geoJSON.Type != "FeatureCollection" || len(geoJSON.Features) != 4 {
+		t.Fatalf("Unexpected GeoJSON: %s", data)
+	}
+	first := geoJSON.Features[0]
+	if first.Geometry.Type != "Point" || string(first.Geometry.Coordinates) != "[120.14,30.25]" || first.Properties["time"] != "09:00-10:30" || first.Properties["notes"] != "<带好雨伞>" {
+		t.Errorf("Unexpected activity feature: %+v", first)
+	}
+	if route := geoJSON.Features[2]; route.Geometry.Type != "LineString" || route.Properties["day"] != float64(1) || route.Properties["from"] != "act-1" {
+		t.Errorf("Unexpected route feature: %+v", route)
+	}
+
+	data, _, err = EncodePlanExport(export, ExportFormatKML)
+	if err != nil {
+		t.Fatalf("KML export failed: %v", err)
+	}
+	var kml struct {
+		Folders []struct {
+			Name       string `xml:"name"`
+			Placemarks []struct {
+				Name        string `xml:"name"`
+				Description string `xml:"description"`
+				Begin       string `xml:"TimeSpan>begin"`
+				Point       string `xml:"Point>coordinates"`
+				Line        string `xml:"LineString>coordinates"`
+			} `xml:"Placemark"`
+		} `xml:"Document>Folder"`
+	}
+	if err := xml.Unmarshal(data, &kml); err != nil || len(kml.Folders) != 2 || kml.Folders[0].Name != "Day 1 (2025-05-01)" || len(kml.Folders[0].Placemarks) != 3 {
+		t.Fatalf("Unexpected KML (%v): %s", err, data)
+	}
+	placemark := kml.Folders[0].Placemarks[0]
+	if placemark.Point != "120.140000,30.250000,0" || placemark.Begin != "2025-05-01T09:00:00Z" || !strings.Contains(placemark.Description, "<带好雨伞>") {
+		t.Errorf("Unexpected KML placemark: %+v", placemark)
+	}
+	if line := kml.Folders[0].Placemarks[2].Line; line != "120.140000,30.250000,0 120.100000,30.250000,0" {
+		t.Errorf("Unexpected KML line: %q", line)
+	}
+
+	data, contentType, err = EncodePlanExport(export, ExportFormatGPX)
+	if err != nil || contentType != "application/gpx+xml" {
+		t.Fatalf("GPX export failed: %v", err)
+	}
+	var gpx struct {
+		XMLName   xml.Name
+		Waypoints []struct {
+			Lat  string `xml:"lat,attr"`
+			Name string `xml:"name"`
+			Time string `xml:"time"`
+			Type string `xml:"type"`
+		} `xml:"wpt"`
+		Tracks []struct {
+			Name     string `xml:"name"`
+			Segments []struct {
+				Points []struct {
+					Lon string `xml:"lon,attr"`
+				} `xml:"trkpt"`
+			} `xml:"trkseg"`
+		} `xml:"trk"`
+	}
+	if err := xml.Unmarshal(data, &gpx); err != nil || gpx.XMLName.Space != "http://www.topografix.com/GPX/1/1" || len(gpx.Waypoints) != 3 || len(gpx.Tracks) != 1 {
+		t.Fatalf("Unexpected GPX (%v): %s", err, data)
+	}
+	if wpt := gpx.Waypoints[2]; wpt.Name != "西溪湿地" || wpt.Type != "Day 2 (2025-05-02)" || wpt.Lat != "30.250000" || wpt.Time != "2025-05-02T09:00:00Z" {
+		t.Errorf("Unexpected GPX waypoint: %+v", wpt)
+	}
+	if points := gpx.Tracks[0].Segments[0].Points; len(points) != 2 || points[1].Lon != "120.100000" {
+		t.Errorf("Unexpected GPX track: %+v", gpx.Tracks[0])
+	}
+
+	if _, _, err := EncodePlanExport(export, "shp"); err == nil {
+		t.Errorf("Expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"testing"
+	"time"
+)
+
+// testActivity 测试行程中的一个活动；Type 默认 attraction，Location 默认与 Title 相同，Lng 为 0 表示没有坐标
+type testActivity struct {
+	ID, Time, Type, Title, Description, Location, Notes string
+	Cost                                                float64
+	Lng, Lat                                            float64
+	Minutes                                             int // 持续时间，0 表示不设置结束时间
+}
+
+// testPlan 测试行程：plan-1 / user-1 / 杭州，从 2025-05-01 开始，Days 中每一项是一天的活动（day-1、day-2……）
+type testPlan struct {
+	Title  string
+	Budget float64
+	People int
+	Days   [][]testActivity
+}
+
+// testPlanStart 测试行程的第一天
+var testPlanStart = time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestTree 按表格创建测试行程并返回行程树
+func newTestTree(t *testing.T, travelService *TravelService, fixture testPlan) *PlanTree {
+	t.Helper()
+	travelService.CreateTravelPlan(&models.TravelPlan{
+		ID: "plan-1", UserID: "user-1", Title: fixture.Title, Destination: "杭州", Budget: fixture.Budget, People: fixture.People,
+		StartDate: testPlanStart, EndDate: testPlanStart.AddDate(0, 0, len(fixture.Days)-1),
+	})
+	for i, activities := range fixture.Days {
+		day := &models.TravelDay{ID: "day-" + string(rune('1'+i)), PlanID: "plan-1", DayNumber: i + 1, Date: testPlanStart.AddDate(0, 0, i)}
+		travelService.CreateTravelDay(day)
+		for _, a := range activities {
+			activityType := a.Type
+			if activityType == "" {
+				activityType = "attraction"
+			}
+			location := a.Location
+			if location == "" {
+				location = a.Title
+			}
+			activity := BuildActivity(day.ID, day.Date, a.Time, activityType, a.Title, a.Description, location, a.Cost)
+			activity.ID = a.ID
+			activity.Notes = a.Notes
+			if a.Minutes > 0 {
+				activity.EndTime = activity.StartTime.Add(time.Duration(a.Minutes) * time.Minute)
+			}
+			if a.Lng != 0 {
+				activity.Longitude, activity.Latitude = a.Lng, a.Lat
+			}
+			travelService.CreateActivity(activity)
+		}
+	}
+
+	tree, err := travelService.GetPlanTree("plan-1", "user-1")
+	if err != nil || tree == nil {
+		t.Fatalf("GetPlanTree failed: %v", err)
+	}
+	return tree
+}
+
+// twoDayTestPlan 两天、每天一个活动
+var twoDayTestPlan = testPlan{Days: [][]testActivity{
+	{{ID: "act-1", Time: "09:00", Title: "景点A", Location: "西湖", Cost: 100}},
+	{{ID: "act-2", Time: "09:00", Title: "景点B", Location: "西湖", Cost: 100}},
+}}
+
+// regenerateTestPlan 三天的行程，第二天上午和下午各一个活动
+var regenerateTestPlan = testPlan{Budget: 3000, People: 2, Days: [][]testActivity{
+	{{ID: "day-1-a", Time: "09:00", Title: "游览西湖", Cost: 100}},
+	{{ID: "day-2-a", Time: "09:00", Title: "灵隐寺", Cost: 100}, {ID: "day-2-b", Time: "14:00", Title: "龙井村喝茶", Cost: 100}},
+	{{ID: "day-3-a", Time: "09:00", Title: "西溪湿地", Cost: 100}},
+}}
+
+// optimizeTestPlan 一天的行程：来回折返的三个景点和 12:30 的午餐
+var optimizeTestPlan = testPlan{Days: [][]testActivity{{
+	{ID: "act-a", Time: "09:00", Title: "act-a", Cost: 100, Lng: 120.0, Lat: 30},
+	{ID: "act-c", Time: "10:40", Title: "act-c", Cost: 100, Lng: 120.3, Lat: 30},
+	{ID: "lunch", Time: "12:30", Type: "restaurant", Title: "lunch", Cost: 100, Lng: 120.2, Lat: 30},
+	{ID: "act-b", Time: "14:00", Title: "act-b", Cost: 100, Lng: 120.1, Lat: 30},
+}}}
+
+// feasibilityTestPlan 第一天有一段来不及的驾车和一个没有坐标的活动，第二天路上时间过长
+var feasibilityTestPlan = testPlan{Days: [][]testActivity{
+	{
+		{ID: "act-a", Time: "09:00", Title: "act-a", Lng: 120.0, Lat: 30.2},
+		{ID: "act-b", Time: "10:00", Title: "act-b", Lng: 120.005, Lat: 30.2},
+		{ID: "act-c", Time: "10:30", Title: "act-c", Lng: 120.5, Lat: 30.2},
+		{ID: "act-d", Time: "14:00", Title: "act-d"},
+	},
+	{
+		{ID: "act-e", Time: "09:00", Title: "act-e", Lng: 120.0, Lat: 30.2},
+		{ID: "act-f", Time: "13:00", Title: "act-f", Lng: 122.0, Lat: 30.2},
+	},
+}}
+
+// exportTestPlan 两天的行程：第一天三个活动（其中一个没有坐标），第二天一个活动
+var exportTestPlan = testPlan{Title: "杭州两日游", Days: [][]testActivity{
+	{
+		{ID: "act-1", Time: "09:00", Title: "游览西湖", Description: "湖边散步 & 拍照", Notes: "<带好雨伞>", Minutes: 90, Lng: 120.14, Lat: 30.25},
+		{ID: "act-2", Time: "11:00", Title: "神秘小店", Description: "湖边散步 & 拍照", Notes: "<带好雨伞>", Minutes: 90},
+		{ID: "act-3", Time: "13:00", Title: "灵隐寺", Description: "湖边散步 & 拍照", Notes: "<带好雨伞>", Minutes: 90, Lng: 120.10, Lat: 30.25},
+	},
+	{
+		{ID: "act-4", Time: "09:00", Title: "西溪湿地", Description: "湖边散步 & 拍照", Notes: "<带好雨伞>", Minutes: 90, Lng: 120.07, Lat: 30.25},
+	},
+}}
+
+// geocodeTestPlan 一天四个活动的杭州行程，只有最后一个活动已有坐标
+var geocodeTestPlan = testPlan{Days: [][]testActivity{{
+	{ID: "act-1", Time: "09:00", Title: "游览西湖", Location: "西湖"},
+	{ID: "act-2", Time: "10:00", Title: "逛河坊街", Location: "河坊街"},
+	{ID: "act-3", Time: "11:00", Title: "神秘小店", Location: "不存在的地方"},
+	{ID: "act-4", Time: "12:00", Title: "午餐", Location: "楼外楼", Lng: 120.15, Lat: 30.25},
+}}}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AddPlanChatMessage 保存一条行程对话消息，新的修改建议会使之前未应用的建议失效
+func (s *TravelService) AddPlanChatMessage(message *models.PlanChatMessage) error {
+	if message.Patch != nil && message.PatchStatus == models.PatchStatusPending {
+		history, err := s.db.GetPlanChatMessages(message.PlanID)
+		if err != nil {
+			return err
+		}
+		for _, previous := range history {
+			s.db.SetPlanChatPatchStatus(previous.ID, models.PatchStatusPending, models.PatchStatusSuperseded)
+		}
+	}
+	return s.db.CreatePlanChatMessage(message)
+}
+
+// GetPlanChatMessages 获取行程的对话历史（按时间排序）
+func (s *TravelService) GetPlanChatMessages(planID string) ([]*models.PlanChatMessage, error) {
+	messages, err := s.db.GetPlanChatMessages(planID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+// UpdatePlanChatMessage 更新对话消息
+func (s *TravelService) UpdatePlanChatMessage(message *models.PlanChatMessage) error {
+	return s.db.UpdatePlanChatMessage(message)
+}
+
+// SetPlanChatPatchStatus 修改建议的状态为 from 时改为 to，返回修改后的消息和是否修改（并发安全的比较并设置）
+func (s *TravelService) SetPlanChatPatchStatus(messageID, from, to string) (*models.PlanChatMessage, bool) {
+	return s.db.SetPlanChatPatchStatus(messageID, from, to)
+}
+
+// NewPlanChatMessage 创建对话消息
+func NewPlanChatMessage(planID, userID, role, content string) *models.PlanChatMessage {
+	now := time.Now()
+	return &models.PlanChatMessage{
+		ID:        uuid.New().String(),
+		PlanID:    planID,
+		UserID:    userID,
+		Role:      role,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// DiffPlanPatch 校验补丁并生成修改前后的对比，不修改数据
+func (s *TravelService) DiffPlanPatch(tree *PlanTree, patch *models.PlanPatch) ([]models.PlanDiffEntry, error) {
+	if patch == nil || len(patch.Operations) == 0 {
+		return nil, fmt.Errorf("patch contains no operations")
+	}
+
+	var diff []models.PlanDiffEntry
+	touched := make(map[string]bool)
+	for i, op := range patch.Operations {
+		entry := models.PlanDiffEntry{Op: op.Op, Day: op.Day, ActivityID: op.ActivityID}
+
+		switch op.Op {
+		case models.PatchOpAdd:
+			day := tree.FindDay(op.Day)
+			if day == nil {
+				return nil, fmt.Errorf("operation %d: day %d not found", i+1, op.Day)
+			}
+			if op.Activity == nil || op.Activity.Title == "" {
+				return nil, fmt.Errorf("operation %d: add requires an activity with a title", i+1)
+			}
+			entry.After = patchedActivity(nil, day, op.Activity)
+
+		case models.PatchOpRemove, models.PatchOpModify:
+			day, activity := tree.FindActivity(op.ActivityID)
+			if activity == nil {
+				return nil, fmt.Errorf("operation %d: activity %q not found in plan", i+1, op.ActivityID)
+			}
+			if touched[op.ActivityID] {
+				return nil, fmt.Errorf("operation %d: activity %q is changed more than once", i+1, op.ActivityID)
+			}
+			touched[op.ActivityID] = true
+			entry.Day = day.Day.DayNumber
+			entry.Before = activity
+
+			if op.Op == models.PatchOpModify {
+				if op.Activity == nil {
+					return nil, fmt.Errorf("operation %d: modify requires an activity", i+1)
+				}
+				target := day
+				if op.Day != 0 && op.Day != day.Day.DayNumber {
+					if target = tree.FindDay(op.Day); target == nil {
+						return nil, fmt.Errorf("operation %d: day %d not found", i+1, op.Day)
+					}
+					entry.Day = op.Day
+				}
+				entry.After = patchedActivity(activity, target, op.Activity)
+			}
+
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i+1, op.Op)
+		}
+
+		diff = append(diff, entry)
+	}
+	return diff, nil
+}
+
+// ApplyPlanPatch 校验并应用补丁，返回应用的对比结果
+func (s *TravelService) ApplyPlanPatch(tree *PlanTree, patch *models.PlanPatch) ([]models.PlanDiffEntry, error) {
+	// 先完整校验，避免部分应用
+	diff, err := s.DiffPlanPatch(tree, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range diff {
+		switch entry.Op {
+		case models.PatchOpAdd:
+			err = s.CreateActivity(entry.After)
+		case models.PatchOpRemove:
+			err = s.DeleteActivity(entry.ActivityID)
+		case models.PatchOpModify:
+			err = s.UpdateActivity(entry.After)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.touchPlan(tree.Plan)
+	return diff, nil
+}
+
+// touchPlan 更新计划的修改时间。活动已经保存，失败时只记录日志
+func (s *TravelService) touchPlan(plan *models.TravelPlan) {
+	if err := s.UpdateTravelPlan(plan.ID, plan.UserID, map[string]interface{}{}); err != nil {
+		log.Printf("更新计划修改时间失败(%s): %v", plan.ID, err)
+	}
+}
+
+// patchedActivity 基于原活动（可为空）和补丁内容生成新的活动记录
+func patchedActivity(original *models.Activity, day *PlanTreeDay, patch *models.PatchActivity) *models.Activity {
+	if original == nil {
+		cost := 0.0
+		if patch.Cost != nil {
+			cost = *patch.Cost
+		}
+		return BuildActivity(day.Day.ID, day.Day.Date, patch.Time, patch.Type,
+			patch.Title, patch.Description, patch.Location, cost)
+	}
+
+	// 补丁中留空的字段视为不变
+	updated := *original
+	updated.DayID = day.Day.ID
+	if patch.Title != "" {
+		updated.Title = patch.Title
+	}
+	if patch.Description != "" {
+		updated.Description = patch.Description
+	}
+	if patch.Cost != nil {
+		updated.Cost = *patch.Cost
+	}
+	if patch.Type != "" {
+		updated.Type = patch.Type
+	}
+	if patch.Location != "" && patch.Location != updated.Location {
+		// 地点变化后原坐标失效
+		updated.Location = patch.Location
+		updated.Latitude = 0
+		updated.Longitude = 0
+		updated.CoordinateSystem = ""
+	}
+	if patch.Time != "" {
+		updated.StartTime = ParseTimeOfDay(day.Day.Date, patch.Time)
+	} else if day.Day.ID != original.DayID {
+		updated.StartTime = ParseTimeOfDay(day.Day.Date, original.StartTime.Format("15:04"))
+	}
+	updated.UpdatedAt = time.Now()
+	return &updated
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"testing"
+)
+
+func TestTravelService_ApplyPlanPatch(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, twoDayTestPlan)
+
+	addCost, modifyCost := 80.0, 75.0
+	patch := &models.PlanPatch{
+		Summary: "第二天改为美食之旅",
+		Operations: []models.PlanPatchOperation{
+			{Op: models.PatchOpRemove, ActivityID: "act-2"},
+			{Op: models.PatchOpAdd, Day: 2, Activity: &models.PatchActivity{Time: "11:30", Title: "河坊街小吃", Type: "restaurant", Cost: &addCost}},
+			{Op: models.PatchOpModify, ActivityID: "act-1", Activity: &models.PatchActivity{Time: "10:00", Title: "灵隐寺", Location: "灵隐寺", Cost: &modifyCost}},
+		},
+	}
+
+	diff, err := travelService.ApplyPlanPatch(tree, patch)
+	if err != nil {
+		t.Fatalf("ApplyPlanPatch failed: %v", err)
+	}
+	if len(diff) != 3 {
+		t.Fatalf("Expected 3 diff entries, got %d", len(diff))
+	}
+
+	updated, _ := travelService.GetPlanTree("plan-1", "user-1")
+	day2 := updated.FindDay(2)
+	if len(day2.Activities) != 1 || day2.Activities[0].Title != "河坊街小吃" {
+		t.Errorf("Unexpected day 2 activities: %+v", day2.Activities)
+	}
+	if day2.Activities[0].StartTime.Format("15:04") != "11:30" {
+		t.Errorf("Expected added activity at 11:30, got %s", day2.Activities[0].StartTime.Format("15:04"))
+	}
+	_, modified := updated.FindActivity("act-1")
+	if modified == nil || modified.Title != "灵隐寺" || modified.StartTime.Format("15:04") != "10:00" {
+		t.Errorf("Unexpected modified activity: %+v", modified)
+	}
+}
+
+func TestTravelService_ApplyPlanPatchKeepsEmptyFields(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, twoDayTestPlan)
+	travelService.db.ModifyActivity("act-1", func(activity *models.Activity) {
+		activity.Description = "断桥残雪"
+		activity.Latitude, activity.Longitude = 30.2590, 120.1488
+	})
+	tree, _ = travelService.GetPlanTree("plan-1", "user-1")
+
+	// 只改时间，其余字段留空
+	patch := &models.PlanPatch{
+		Operations: []models.PlanPatchOperation{
+			{Op: models.PatchOpModify, ActivityID: "act-1", Activity: &models.PatchActivity{Time: "10:30"}},
+		},
+	}
+	if _, err := travelService.ApplyPlanPatch(tree, patch); err != nil {
+		t.Fatalf("ApplyPlanPatch failed: %v", err)
+	}
+
+	modified, err := travelService.GetActivity("act-1")
+	if err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if modified.StartTime.Format("15:04") != "10:30" {
+		t.Errorf("Expected activity moved to 10:30, got %s", modified.StartTime.Format("15:04"))
+	}
+	if modified.Title != "景点A" || modified.Description != "断桥残雪" || modified.Cost != 100 {
+		t.Errorf("Empty patch fields should leave the activity unchanged: %+v", modified)
+	}
+	if modified.Location != "西湖" || modified.Latitude != 30.2590 || modified.Longitude != 120.1488 {
+		t.Errorf("Empty location should keep location and coordinates: %+v", modified)
+	}
+}
+
+func TestTravelService_DiffPlanPatchRejectsUnknownActivity(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+	tree := newTestTree(t, travelService, twoDayTestPlan)
+
+	patch := &models.PlanPatch{
+		Operations: []models.PlanPatchOperation{
+			{Op: models.PatchOpRemove, ActivityID: "act-1"},
+			{Op: models.PatchOpRemove, ActivityID: "does-not-exist"},
+		},
+	}
+	if _, err := travelService.ApplyPlanPatch(tree, patch); err == nil {
+		t.Fatal("Expected error for unknown activity")
+	}
+
+	// 校验失败时不应部分应用
+	if _, activity := tree.FindActivity("act-1"); activity == nil {
+		t.Fatal("act-1 missing from tree")
+	}
+	if _, err := travelService.GetActivity("act-1"); err != nil {
+		t.Errorf("act-1 should not have been removed: %v", err)
+	}
+}
+
+func TestTravelService_SetPlanChatPatchStatus(t *testing.T) {
+	travelService := NewTravelService(&config.Config{})
+
+	message := NewPlanChatMessage("plan-1", "user-1", "assistant", "第二天改为美食之旅")
+	message.Patch = &models.PlanPatch{Operations: []models.PlanPatchOperation{{Op: models.PatchOpRemove, ActivityID: "act-2"}}}
+	message.PatchStatus = models.PatchStatusPending
+	if err := travelService.AddPlanChatMessage(message); err != nil {
+		t.Fatalf("AddPlanChatMessage failed: %v", err)
+	}
+
+	applied, ok := travelService.SetPlanChatPatchStatus(message.ID, models.PatchStatusPending, models.PatchStatusApplied)
+	if !ok || applied.PatchStatus != models.PatchStatusApplied {
+		t.Fatalf("Expected first confirmation to apply the patch, got %+v", applied)
+	}
+
+	// 重复确认时状态已不是待确认，不能再次标记成功
+	again, ok := travelService.SetPlanChatPatchStatus(message.ID, models.PatchStatusPending, models.PatchStatusApplied)
+	if ok {
+		t.Fatal("Expected second confirmation to be rejected")
+	}
+	if again == nil || again.PatchStatus != models.PatchStatusApplied {
+		t.Errorf("Expected current status applied, got %+v", again)
+	}
+
+	if _, ok := travelService.SetPlanChatPatchStatus("missing", models.PatchStatusPending, models.PatchStatusApplied); ok {
+		t.Error("Expected unknown message to be rejected")
+	}
+}
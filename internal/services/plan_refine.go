@@ -0,0 +1,117 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// UsageEndpointPlanRefinement 对话式修改行程
+const UsageEndpointPlanRefinement = "plan_refinement"
+
+// promptActivity 发送给LLM的活动精简结构
+type promptActivity struct {
+	ID          string  `json:"id"`
+	Time        string  `json:"time"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Location    string  `json:"location"`
+	Cost        float64 `json:"cost"`
+	Type        string  `json:"type"`
+}
+
+// promptDay 发送给LLM的日程精简结构
+type promptDay struct {
+	Day        int              `json:"day"`
+	Date       string           `json:"date"`
+	Activities []promptActivity `json:"activities"`
+}
+
+// formatPlanDays 将行程树的日程序列化为提示词中的JSON
+func formatPlanDays(days []*PlanTreeDay) string {
+	var out []promptDay
+	for _, day := range days {
+		pd := promptDay{
+			Day:        day.Day.DayNumber,
+			Date:       day.Day.Date.Format("2006-01-02"),
+			Activities: []promptActivity{},
+		}
+		for _, activity := range day.Activities {
+			pd.Activities = append(pd.Activities, promptActivity{
+				ID:          activity.ID,
+				Time:        activity.StartTime.Format("15:04"),
+				Title:       activity.Title,
+				Description: activity.Description,
+				Location:    activity.Location,
+				Cost:        activity.Cost,
+				Type:        activity.Type,
+			})
+		}
+		out = append(out, pd)
+	}
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return string(data)
+}
+
+// RefinePlanWithKey 根据用户的自然语言指令生成行程修改补丁
+func (s *LLMService) RefinePlanWithKey(meta LLMCallMeta, tree *PlanTree, history []*models.PlanChatMessage, instruction, apiKey, baseURL, model string) (*models.PlanPatch, error) {
+	plan := tree.Plan
+	meta.Endpoint = UsageEndpointPlanRefinement
+	instruction, err := s.checkInput(meta, "instruction", instruction)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.userInput(meta, "destination", plan.Destination)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.renderPrompt(meta, "plan_refine", map[string]interface{}{
+		"Destination": destination,
+		"StartDate":   plan.StartDate.Format("2006-01-02"),
+		"EndDate":     plan.EndDate.Format("2006-01-02"),
+		"Budget":      plan.Budget,
+		"People":      plan.People,
+		"Currency":    planCurrency(plan),
+		"Days":        formatPlanDays(tree.Days),
+		"Language":    LocaleName(ResolveLocale(plan.Locale)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt, err := s.renderPrompt(meta, "plan_refine_system", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+	// 附带之前的对话，便于理解"再轻松一点"之类的追问
+	for _, message := range history {
+		content := message.Content
+		if message.Role == "assistant" && message.Patch != nil {
+			patchJSON, _ := json.Marshal(message.Patch)
+			content = string(patchJSON)
+		}
+		messages = append(messages, Message{Role: message.Role, Content: content})
+	}
+	messages = append(messages, Message{Role: "user", Content: instruction})
+
+	response, err := s.callOpenAIMessagesWithKey(meta, messages, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	response = extractJSON(response)
+	var patch models.PlanPatch
+	if err := json.Unmarshal([]byte(response), &patch); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+	for i := range patch.Operations {
+		patch.Operations[i].Op = strings.ToLower(strings.TrimSpace(patch.Operations[i].Op))
+	}
+
+	return &patch, nil
+}
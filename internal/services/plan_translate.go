@@ -0,0 +1,151 @@
+package services
+
+import (
+	"ai-travel-planner/internal/guardrails"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageEndpointPlanTranslation 翻译行程
+const UsageEndpointPlanTranslation = "plan_translation"
+
+// PlanTranslation LLM返回的行程译文
+type PlanTranslation struct {
+	Title           string                `json:"title"`
+	Destination     string                `json:"destination"`
+	Activities      []ActivityTranslation `json:"activities"`
+	Recommendations []string              `json:"recommendations,omitempty"`
+}
+
+// ActivityTranslation 单个活动的译文，按 ID 对应原活动
+type ActivityTranslation struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Notes       string `json:"notes"`
+}
+
+// TranslatePlanWithKey 将行程中的文字内容翻译为目标语言
+func (s *LLMService) TranslatePlanWithKey(meta LLMCallMeta, tree *PlanTree, locale, apiKey, baseURL, model string) (*PlanTranslation, error) {
+	meta.Locale = locale
+	meta.Endpoint = UsageEndpointPlanTranslation
+	title, err := s.checkInput(meta, "title", tree.Plan.Title)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.checkInput(meta, "destination", tree.Plan.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	source := PlanTranslation{Title: title, Destination: destination}
+	// 建议可以由用户编辑，和标题一样先检查再拼进提示词
+	for _, recommendation := range tree.Plan.Recommendations {
+		clean, err := s.checkInput(meta, "recommendations", recommendation)
+		if err != nil {
+			return nil, err
+		}
+		source.Recommendations = append(source.Recommendations, clean)
+	}
+	for _, day := range tree.Days {
+		for _, activity := range day.Activities {
+			source.Activities = append(source.Activities, ActivityTranslation{
+				ID:          activity.ID,
+				Title:       activity.Title,
+				Description: activity.Description,
+				Location:    activity.Location,
+				Notes:       activity.Notes,
+			})
+		}
+	}
+	sourceJSON, _ := json.MarshalIndent(source, "", "  ")
+
+	prompt, err := s.renderPrompt(meta, "plan_translate", map[string]interface{}{
+		"Language": LocaleName(locale),
+		"Locale":   locale,
+		"Plan":     guardrails.Delimit(string(sourceJSON)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.callOpenAIWithKey(meta, prompt, apiKey, baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	response = extractJSON(response)
+	var result PlanTranslation
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %v. Raw response: %s", err, response)
+	}
+	return &result, nil
+}
+
+// CopyTranslatedPlan 以译文创建行程树的副本，金额和时间保持不变，原行程不受影响
+func (s *TravelService) CopyTranslatedPlan(tree *PlanTree, locale string, translation *PlanTranslation) (*PlanTree, error) {
+	translated := make(map[string]ActivityTranslation)
+	for _, activity := range translation.Activities {
+		translated[activity.ID] = activity
+	}
+
+	now := time.Now()
+	plan := *tree.Plan
+	plan.ID = uuid.New().String()
+	plan.Title = orDefault(translation.Title, plan.Title)
+	plan.Destination = orDefault(translation.Destination, plan.Destination)
+	plan.Locale = locale
+	plan.Currency = planCurrency(tree.Plan) // 只翻译文字，不换算金额
+	plan.PromptVersions = nil
+	plan.Recommendations = append([]string(nil), tree.Plan.Recommendations...)
+	if len(translation.Recommendations) == len(plan.Recommendations) {
+		copy(plan.Recommendations, translation.Recommendations)
+	}
+	plan.Preferences = tree.Plan.Preferences.Clone()
+	// 预算提醒按目标语言重新核算，预算分配使用核算得到的副本
+	check, breakdown := PlanTreeBudgetCheck(tree, locale)
+	plan.BudgetBreakdown = breakdown
+	plan.BudgetWarnings = check.Warnings
+	plan.CreatedAt = now
+	plan.UpdatedAt = now
+	if err := s.CreateTravelPlan(&plan); err != nil {
+		return nil, err
+	}
+
+	copied := &PlanTree{Plan: &plan}
+	for _, day := range tree.Days {
+		newDay := *day.Day
+		newDay.ID = uuid.New().String()
+		newDay.PlanID = plan.ID
+		newDay.CreatedAt = now
+		newDay.UpdatedAt = now
+		if err := s.CreateTravelDay(&newDay); err != nil {
+			return nil, err
+		}
+
+		treeDay := &PlanTreeDay{Day: &newDay}
+		for _, activity := range day.Activities {
+			newActivity := *activity
+			newActivity.ID = uuid.New().String()
+			newActivity.DayID = newDay.ID
+			newActivity.CreatedAt = now
+			newActivity.UpdatedAt = now
+			if t, ok := translated[activity.ID]; ok {
+				newActivity.Title = orDefault(t.Title, activity.Title)
+				newActivity.Description = orDefault(t.Description, activity.Description)
+				newActivity.Location = orDefault(t.Location, activity.Location)
+				newActivity.Notes = orDefault(t.Notes, activity.Notes)
+			}
+			if err := s.CreateActivity(&newActivity); err != nil {
+				return nil, err
+			}
+			treeDay.Activities = append(treeDay.Activities, &newActivity)
+		}
+		copied.Days = append(copied.Days, treeDay)
+	}
+	return copied, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 偏好取值及其在提示词中的中英文描述
+var (
+	paceLabels = map[string][2]string{
+		"relaxed":  {"节奏轻松：每天不超过3个活动，留出休息时间", "Relaxed pace: at most 3 activities per day with time to rest"},
+		"moderate": {"节奏适中：每天4-5个活动", "Moderate pace: 4-5 activities per day"},
+		"packed":   {"节奏紧凑：在合理范围内尽量多安排景点", "Packed pace: fit in as many sights as is practical"},
+	}
+	interestLabels = map[string][2]string{
+		"food":         {"美食", "food"},
+		"history":      {"历史", "history"},
+		"culture":      {"文化", "culture"},
+		"nature":       {"自然风光", "nature"},
+		"art":          {"艺术", "art"},
+		"museums":      {"博物馆", "museums"},
+		"shopping":     {"购物", "shopping"},
+		"nightlife":    {"夜生活", "nightlife"},
+		"outdoor":      {"户外运动", "outdoor activities"},
+		"photography":  {"摄影", "photography"},
+		"architecture": {"建筑", "architecture"},
+		"beach":        {"海滩", "beaches"},
+	}
+	dietaryLabels = map[string][2]string{
+		"vegetarian":      {"素食", "vegetarian"},
+		"vegan":           {"纯素", "vegan"},
+		"halal":           {"清真", "halal"},
+		"kosher":          {"犹太洁食", "kosher"},
+		"gluten_free":     {"无麸质", "gluten-free"},
+		"no_pork":         {"不吃猪肉", "no pork"},
+		"no_beef":         {"不吃牛肉", "no beef"},
+		"no_spicy":        {"不吃辣", "no spicy food"},
+		"nut_allergy":     {"坚果过敏", "nut allergy"},
+		"seafood_allergy": {"海鲜过敏", "seafood allergy"},
+	}
+	mobilityLabels = map[string][2]string{
+		"full":       {"", ""},
+		"limited":    {"行动不便：减少步行距离和台阶，避免登山徒步", "Limited mobility: minimise walking and stairs, avoid hikes"},
+		"wheelchair": {"使用轮椅：只安排轮椅可达的地点、餐厅和交通", "Wheelchair user: only wheelchair-accessible places, restaurants and transport"},
+	}
+	accessibilityLabels = map[string][2]string{
+		"step_free_access":    {"无台阶通道", "step-free access"},
+		"elevator":            {"电梯", "elevators"},
+		"accessible_restroom": {"无障碍卫生间", "accessible restrooms"},
+		"frequent_rest":       {"经常休息", "frequent rest breaks"},
+		"visual_assistance":   {"视障辅助", "assistance for visual impairment"},
+		"hearing_assistance":  {"听障辅助", "assistance for hearing impairment"},
+	}
+	lodgingLabels = map[string][2]string{
+		"budget":   {"住宿档次：经济型（青旅、快捷酒店）", "Lodging tier: budget (hostels, budget hotels)"},
+		"midrange": {"住宿档次：舒适型（三星、四星酒店）", "Lodging tier: mid-range (3-4 star hotels)"},
+		"upscale":  {"住宿档次：高档型（四星、五星酒店）", "Lodging tier: upscale (4-5 star hotels)"},
+		"luxury":   {"住宿档次：豪华型（五星及精品度假酒店）", "Lodging tier: luxury (5 star and boutique resorts)"},
+	}
+	transportLabels = map[string][2]string{
+		"walking":        {"步行", "walking"},
+		"public_transit": {"公共交通", "public transit"},
+		"taxi":           {"出租车/网约车", "taxi or ride-hailing"},
+		"car":            {"自驾", "driving"},
+		"bicycle":        {"骑行", "cycling"},
+		"train":          {"火车/高铁", "train"},
+		"flight":         {"飞机", "flights"},
+	}
+)
+
+// maxChildren 偏好中最多填写的儿童数量
+const maxChildren = 10
+
+// NormalizeTripPreferences 校验偏好取值，并统一为小写、去掉空白和重复项
+func NormalizeTripPreferences(preferences *models.TripPreferences) error {
+	if preferences == nil {
+		return nil
+	}
+	preferences.Description = strings.TrimSpace(preferences.Description)
+
+	var err error
+	if preferences.Pace, err = normalizeChoice("pace", preferences.Pace, paceLabels); err != nil {
+		return err
+	}
+	if preferences.Mobility, err = normalizeChoice("mobility", preferences.Mobility, mobilityLabels); err != nil {
+		return err
+	}
+	if preferences.LodgingTier, err = normalizeChoice("lodging_tier", preferences.LodgingTier, lodgingLabels); err != nil {
+		return err
+	}
+	if preferences.Interests, err = normalizeChoices("interests", preferences.Interests, interestLabels); err != nil {
+		return err
+	}
+	if preferences.DietaryRestrictions, err = normalizeChoices("dietary_restrictions", preferences.DietaryRestrictions, dietaryLabels); err != nil {
+		return err
+	}
+	if preferences.AccessibilityNeeds, err = normalizeChoices("accessibility_needs", preferences.AccessibilityNeeds, accessibilityLabels); err != nil {
+		return err
+	}
+	if preferences.TransportModes, err = normalizeChoices("transport_modes", preferences.TransportModes, transportLabels); err != nil {
+		return err
+	}
+
+	if len(preferences.ChildrenAges) > maxChildren {
+		return fmt.Errorf("children_ages: at most %d children are allowed", maxChildren)
+	}
+	for _, age := range preferences.ChildrenAges {
+		if age < 0 || age > 17 {
+			return fmt.Errorf("children_ages: %d is not between 0 and 17", age)
+		}
+	}
+	return nil
+}
+
+// normalizeChoice 校验单选偏好
+func normalizeChoice(field, value string, labels map[string][2]string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	if _, ok := labels[value]; !ok {
+		return "", fmt.Errorf("%s: unsupported value %q (allowed: %s)", field, value, allowedChoices(labels))
+	}
+	return value, nil
+}
+
+// normalizeChoices 校验多选偏好并去重
+func normalizeChoices(field string, values []string, labels map[string][2]string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value, err := normalizeChoice(field, value, labels)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" && !seen[value] {
+			seen[value] = true
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized, nil
+}
+
+func allowedChoices(labels map[string][2]string) string {
+	var choices []string
+	for choice := range labels {
+		choices = append(choices, choice)
+	}
+	sort.Strings(choices)
+	return strings.Join(choices, ", ")
+}
+
+// PreferenceConstraints 将偏好转换为提示词中的约束条目，中文语言使用中文描述，其余语言使用英文
+func PreferenceConstraints(preferences *models.TripPreferences, locale string) []string {
+	if preferences == nil {
+		return nil
+	}
+	lang := 1
+	if locale == "" || strings.HasPrefix(locale, "zh") {
+		lang = 0
+	}
+	text := func(zh, en string) string {
+		if lang == 0 {
+			return zh
+		}
+		return en
+	}
+	list := func(values []string, labels map[string][2]string) string {
+		var items []string
+		for _, value := range values {
+			items = append(items, labels[value][lang])
+		}
+		return strings.Join(items, text("、", ", "))
+	}
+
+	var constraints []string
+	if label := paceLabels[preferences.Pace][lang]; label != "" {
+		constraints = append(constraints, label)
+	}
+	if len(preferences.Interests) > 0 {
+		constraints = append(constraints, text("兴趣：", "Interests: ")+list(preferences.Interests, interestLabels))
+	}
+	if len(preferences.DietaryRestrictions) > 0 {
+		constraints = append(constraints, text("饮食限制（所有餐厅安排都必须满足）：", "Dietary restrictions (every meal must comply): ")+
+			list(preferences.DietaryRestrictions, dietaryLabels))
+	}
+	if label := mobilityLabels[preferences.Mobility][lang]; label != "" {
+		constraints = append(constraints, label)
+	}
+	if len(preferences.AccessibilityNeeds) > 0 {
+		constraints = append(constraints, text("无障碍需求：", "Accessibility needs: ")+list(preferences.AccessibilityNeeds, accessibilityLabels))
+	}
+	if label := lodgingLabels[preferences.LodgingTier][lang]; label != "" {
+		constraints = append(constraints, label)
+	}
+	if len(preferences.TransportModes) > 0 {
+		constraints = append(constraints, text("交通方式：只使用", "Transport: only use ")+list(preferences.TransportModes, transportLabels))
+	}
+	if len(preferences.ChildrenAges) > 0 {
+		var ages []string
+		for _, age := range preferences.ChildrenAges {
+			ages = append(ages, fmt.Sprint(age))
+		}
+		constraints = append(constraints, text(
+			fmt.Sprintf("同行儿童年龄：%s岁，安排适合儿童的活动，避免行程过长", strings.Join(ages, "、")),
+			fmt.Sprintf("Traveling with children aged %s: include child-friendly activities and avoid long days", strings.Join(ages, ", "))))
+	}
+	return constraints
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTripPreferences(t *testing.T) {
+	preferences := &models.TripPreferences{
+		Description:         "  想吃小吃  ",
+		Pace:                " Relaxed ",
+		Interests:           []string{"food", "FOOD", " history", ""},
+		DietaryRestrictions: []string{"vegetarian"},
+		Mobility:            "wheelchair",
+		ChildrenAges:        []int{3, 7},
+	}
+	if err := NormalizeTripPreferences(preferences); err != nil {
+		t.Fatalf("NormalizeTripPreferences failed: %v", err)
+	}
+	if preferences.Description != "想吃小吃" || preferences.Pace != "relaxed" {
+		t.Errorf("Fields not normalized: %+v", preferences)
+	}
+	if strings.Join(preferences.Interests, ",") != "food,history" {
+		t.Errorf("Interests not deduplicated: %v", preferences.Interests)
+	}
+
+	invalid := []*models.TripPreferences{
+		{Pace: "fast"},
+		{Interests: []string{"gambling"}},
+		{LodgingTier: "palace"},
+		{TransportModes: []string{"teleport"}},
+		{ChildrenAges: []int{18}},
+		{ChildrenAges: []int{-1}},
+	}
+	for _, preferences := range invalid {
+		if err := NormalizeTripPreferences(preferences); err == nil {
+			t.Errorf("Expected error for %+v", preferences)
+		}
+	}
+	if err := NormalizeTripPreferences(nil); err != nil {
+		t.Errorf("nil preferences should be valid, got %v", err)
+	}
+}
+
+func TestPreferenceConstraints(t *testing.T) {
+	preferences := &models.TripPreferences{
+		Pace:                "relaxed",
+		DietaryRestrictions: []string{"halal", "no_spicy"},
+		Mobility:            "full",
+		LodgingTier:         "budget",
+		TransportModes:      []string{"walking", "public_transit"},
+		ChildrenAges:        []int{5},
+	}
+
+	zh := strings.Join(PreferenceConstraints(preferences, "zh-CN"), "\n")
+	for _, expected := range []string{"节奏轻松", "清真、不吃辣", "经济型", "步行、公共交通", "5岁"} {
+		if !strings.Contains(zh, expected) {
+			t.Errorf("Chinese constraints missing %q: %s", expected, zh)
+		}
+	}
+	en := PreferenceConstraints(preferences, "en-US")
+	if len(en) != 5 || !strings.Contains(strings.Join(en, "\n"), "halal, no spicy food") {
+		t.Errorf("Unexpected English constraints: %v", en)
+	}
+	if constraints := PreferenceConstraints(&models.TripPreferences{Mobility: "full"}, "zh-CN"); len(constraints) != 0 {
+		t.Errorf("Full mobility should not add a constraint: %v", constraints)
+	}
+}
+
+func TestLLMPipeline_PreferenceConstraintsInPrompt(t *testing.T) {
+	llmService, _, fake := newPipelineTestService(t)
+	request := newPipelineTestRequest()
+	request.Preferences.DietaryRestrictions = []string{"vegetarian"}
+	request.Preferences.Mobility = "limited"
+
+	if _, err := llmService.GenerateTravelPlanWithKey(LLMCallMeta{UserID: "user-1", Locale: "zh-CN"}, request, "sk-user", ""); err != nil {
+		t.Fatalf("GenerateTravelPlanWithKey failed: %v", err)
+	}
+	prompt := fake.Requests()[0].Messages[1].Content
+	if !strings.Contains(prompt, "必须满足的出行要求") || !strings.Contains(prompt, "素食") || !strings.Contains(prompt, "行动不便") {
+		t.Errorf("Prompt should list the preference constraints: %s", prompt)
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"time"
+)
+
+// QuotaService 管理使用服务端Key时的每用户LLM配额
+type QuotaService struct {
+	config       *config.Config
+	db           *MemoryDB
+	usageService *UsageService
+	now          func() time.Time
+}
+
+// QuotaExceededError 配额超限错误
+type QuotaExceededError struct {
+	Period  string    `json:"period"` // daily, monthly
+	Metric  string    `json:"metric"` // requests, tokens
+	Limit   int       `json:"limit"`
+	Used    int       `json:"used"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s LLM %s quota exceeded (%d/%d), resets at %s",
+		e.Period, e.Metric, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+func NewQuotaService(cfg *config.Config, usageService *UsageService) *QuotaService {
+	return &QuotaService{
+		config:       cfg,
+		db:           NewMemoryDB(),
+		usageService: usageService,
+		now:          time.Now,
+	}
+}
+
+// GetQuota 获取用户生效的配额，返回是否为管理员覆盖值
+func (s *QuotaService) GetQuota(userID string) (models.LLMQuota, bool, error) {
+	override, err := s.db.GetQuotaOverride(userID)
+	if err != nil {
+		return models.LLMQuota{}, false, err
+	}
+	if override != nil {
+		return override.Quota, true, nil
+	}
+
+	defaults := s.config.APIs.OpenAI.Quota
+	return models.LLMQuota{
+		DailyRequests:   defaults.DailyRequests,
+		DailyTokens:     defaults.DailyTokens,
+		MonthlyRequests: defaults.MonthlyRequests,
+		MonthlyTokens:   defaults.MonthlyTokens,
+	}, false, nil
+}
+
+// SetQuotaOverride 为用户设置配额（覆盖默认配置）
+func (s *QuotaService) SetQuotaOverride(userID string, quota models.LLMQuota, updatedBy string) (*models.UserQuotaOverride, error) {
+	override := &models.UserQuotaOverride{
+		UserID:    userID,
+		Quota:     quota,
+		UpdatedBy: updatedBy,
+		UpdatedAt: s.now(),
+	}
+	if err := s.db.SetQuotaOverride(override); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+// DeleteQuotaOverride 删除用户的配额覆盖，恢复默认配置
+func (s *QuotaService) DeleteQuotaOverride(userID string) error {
+	return s.db.DeleteQuotaOverride(userID)
+}
+
+// GetQuotaStatus 获取用户当前周期的配额使用情况
+func (s *QuotaService) GetQuotaStatus(userID string) (*models.QuotaStatus, error) {
+	quota, overridden, err := s.GetQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	dayStart, dayReset := dayWindow(now)
+	monthStart, monthReset := monthWindow(now)
+
+	daily, err := s.usageService.GetServerKeyUsageSince(userID, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := s.usageService.GetServerKeyUsageSince(userID, monthStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaStatus{
+		UserID:     userID,
+		Overridden: overridden,
+		Daily: models.QuotaPeriodStatus{
+			RequestLimit: quota.DailyRequests,
+			RequestsUsed: daily.Requests,
+			TokenLimit:   quota.DailyTokens,
+			TokensUsed:   daily.TotalTokens,
+			ResetAt:      dayReset,
+		},
+		Monthly: models.QuotaPeriodStatus{
+			RequestLimit: quota.MonthlyRequests,
+			RequestsUsed: monthly.Requests,
+			TokenLimit:   quota.MonthlyTokens,
+			TokensUsed:   monthly.TotalTokens,
+			ResetAt:      monthReset,
+		},
+	}, nil
+}
+
+// CheckQuota 检查用户是否还能使用服务端Key，超限时返回 *QuotaExceededError
+func (s *QuotaService) CheckQuota(userID string) error {
+	status, err := s.GetQuotaStatus(userID)
+	if err != nil {
+		return err
+	}
+
+	// 月度超限时重置时间更晚，优先报告
+	periods := []struct {
+		name   string
+		status models.QuotaPeriodStatus
+	}{
+		{"monthly", status.Monthly},
+		{"daily", status.Daily},
+	}
+	for _, period := range periods {
+		p := period.status
+		if p.RequestLimit > 0 && p.RequestsUsed >= p.RequestLimit {
+			return &QuotaExceededError{Period: period.name, Metric: "requests", Limit: p.RequestLimit, Used: p.RequestsUsed, ResetAt: p.ResetAt}
+		}
+		if p.TokenLimit > 0 && p.TokensUsed >= p.TokenLimit {
+			return &QuotaExceededError{Period: period.name, Metric: "tokens", Limit: p.TokenLimit, Used: p.TokensUsed, ResetAt: p.ResetAt}
+		}
+	}
+	return nil
+}
+
+// dayWindow 返回当天的起止时间（本地时区）
+func dayWindow(now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// monthWindow 返回当月的起止时间（本地时区）
+func monthWindow(now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 1, 0)
+}
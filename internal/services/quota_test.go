@@ -0,0 +1,92 @@
+package services
+
+import (
+	"ai-travel-planner/internal/models"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQuotaService_CheckQuota(t *testing.T) {
+	usageService := newTestUsageService()
+	usageService.config.APIs.OpenAI.Quota.DailyRequests = 2
+	quotaService := NewQuotaService(usageService.config, usageService)
+
+	meta := LLMCallMeta{UserID: "u1", Endpoint: UsageEndpointPlanGeneration}
+	usage := OpenAIUsage{PromptTokens: 10, CompletionTokens: 10}
+
+	// 使用用户自己的Key不计入配额
+	for i := 0; i < 3; i++ {
+		usageService.RecordLLMUsage(meta, "gpt-4o-mini", KeySourceUser, usage)
+	}
+	if err := quotaService.CheckQuota("u1"); err != nil {
+		t.Fatalf("User key usage should not count towards quota: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		usageService.RecordLLMUsage(meta, "gpt-4o-mini", KeySourceServer, usage)
+	}
+	err := quotaService.CheckQuota("u1")
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("Expected QuotaExceededError, got %v", err)
+	}
+	if quotaErr.Period != "daily" || quotaErr.Metric != "requests" || quotaErr.Used != 2 {
+		t.Errorf("Unexpected quota error: %+v", quotaErr)
+	}
+
+	// 管理员覆盖后恢复可用
+	if _, err := quotaService.SetQuotaOverride("u1", models.LLMQuota{DailyRequests: 10}, "admin@example.com"); err != nil {
+		t.Fatalf("SetQuotaOverride failed: %v", err)
+	}
+	if err := quotaService.CheckQuota("u1"); err != nil {
+		t.Errorf("Expected quota override to allow request, got %v", err)
+	}
+
+	// 其他用户不受影响
+	if err := quotaService.CheckQuota("u2"); err != nil {
+		t.Errorf("Expected u2 to be under quota, got %v", err)
+	}
+}
+
+func TestUsageService_ReserveLLMUsageWithQuota(t *testing.T) {
+	usageService := newTestUsageService()
+	usageService.config.APIs.OpenAI.Quota.DailyRequests = 2
+	quotaService := NewQuotaService(usageService.config, usageService)
+	meta := LLMCallMeta{UserID: "u1", Endpoint: UsageEndpointPlanGeneration}
+	check := func() error { return quotaService.CheckQuota("u1") }
+
+	// 并发预留时只有配额内的请求能通过检查
+	var wg sync.WaitGroup
+	var reserved int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := usageService.ReserveLLMUsage(meta, "gpt-4o-mini", KeySourceServer, check); err == nil {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 2 {
+		t.Fatalf("Expected 2 reservations within quota, got %d", reserved)
+	}
+
+	// 结算后记录实际用量，失败的调用保留在记录中
+	usages, _ := usageService.db.GetLLMUsages("u1")
+	if err := usageService.SettleLLMUsage(usages[0].ID, OpenAIUsage{PromptTokens: 10, CompletionTokens: 20}, false); err != nil {
+		t.Fatalf("SettleLLMUsage failed: %v", err)
+	}
+	if err := usageService.SettleLLMUsage(usages[1].ID, OpenAIUsage{}, true); err != nil {
+		t.Fatalf("SettleLLMUsage failed: %v", err)
+	}
+	report, _ := usageService.GetUserUsage("u1")
+	if report.Total.Requests != 2 || report.Total.FailedRequests != 1 || report.Total.TotalTokens != 30 {
+		t.Errorf("Unexpected usage after settling: %+v", report.Total)
+	}
+	if err := usageService.SettleLLMUsage("missing", OpenAIUsage{}, true); err == nil {
+		t.Error("Expected error settling an unknown reservation")
+	}
+}
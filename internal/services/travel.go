@@ -1,19 +1,28 @@
 package services
 
 import (
+	"ai-travel-planner/internal/cache"
 	"ai-travel-planner/internal/config"
 	"ai-travel-planner/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type TravelService struct {
-	config *config.Config
-	db     *MemoryDB
+	config     *config.Config
+	db         *MemoryDB
+	routeCache cache.Cache // 可行性检查查询的路线时间
 }
 
 func NewTravelService(cfg *config.Config) *TravelService {
 	return &TravelService{
-		config: cfg,
-		db:     NewMemoryDB(),
+		config:     cfg,
+		db:         NewMemoryDB(),
+		routeCache: cache.NewMemoryCache(2000),
 	}
 }
 
@@ -37,6 +46,23 @@ func (s *TravelService) UpdateTravelPlan(id, userID string, updates map[string]i
 	return s.db.UpdateTravelPlan(id, userID, updates)
 }
 
+// MaxRecommendations 每个计划最多保存的旅行建议条数
+const MaxRecommendations = 50
+
+// UpdatePlanRecommendations 替换计划的旅行建议列表，去掉空白条目
+func (s *TravelService) UpdatePlanRecommendations(id, userID string, recommendations []string) ([]string, error) {
+	cleaned := []string{}
+	for _, recommendation := range recommendations {
+		if recommendation = strings.TrimSpace(recommendation); recommendation != "" {
+			cleaned = append(cleaned, recommendation)
+		}
+	}
+	if err := s.db.UpdateTravelPlan(id, userID, map[string]interface{}{"recommendations": cleaned}); err != nil {
+		return nil, err
+	}
+	return cleaned, nil
+}
+
 // DeleteTravelPlan 删除旅行计划
 func (s *TravelService) DeleteTravelPlan(id, userID string) error {
 	return s.db.DeleteTravelPlan(id, userID)
@@ -62,6 +88,120 @@ func (s *TravelService) GetActivities(dayID string) ([]*models.Activity, error)
 	return s.db.GetActivities(dayID)
 }
 
+// GetActivity 获取单个活动
+func (s *TravelService) GetActivity(id string) (*models.Activity, error) {
+	return s.db.GetActivity(id)
+}
+
+// UpdateActivity 更新活动
+func (s *TravelService) UpdateActivity(activity *models.Activity) error {
+	return s.db.UpdateActivity(activity)
+}
+
+// DeleteActivity 删除活动
+func (s *TravelService) DeleteActivity(id string) error {
+	return s.db.DeleteActivity(id)
+}
+
+// PlanTree 行程树：计划及按天排序的活动
+type PlanTree struct {
+	Plan *models.TravelPlan `json:"plan"`
+	Days []*PlanTreeDay     `json:"days"`
+}
+
+// PlanTreeDay 行程树中的一天
+type PlanTreeDay struct {
+	Day        *models.TravelDay  `json:"day"`
+	Activities []*models.Activity `json:"activities"`
+}
+
+// FindDay 按天数查找日程
+func (t *PlanTree) FindDay(dayNumber int) *PlanTreeDay {
+	for _, day := range t.Days {
+		if day.Day.DayNumber == dayNumber {
+			return day
+		}
+	}
+	return nil
+}
+
+// FindActivity 查找活动及其所在日程
+func (t *PlanTree) FindActivity(activityID string) (*PlanTreeDay, *models.Activity) {
+	for _, day := range t.Days {
+		for _, activity := range day.Activities {
+			if activity.ID == activityID {
+				return day, activity
+			}
+		}
+	}
+	return nil, nil
+}
+
+// GetPlanTree 获取用户的行程树，计划不存在时返回 nil
+func (s *TravelService) GetPlanTree(planID, userID string) (*PlanTree, error) {
+	plan, err := s.GetTravelPlan(planID, userID)
+	if err != nil || plan == nil {
+		return nil, err
+	}
+
+	days, err := s.GetTravelDays(planID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].DayNumber < days[j].DayNumber })
+
+	tree := &PlanTree{Plan: plan}
+	for _, day := range days {
+		activities, err := s.GetActivities(day.ID)
+		if err != nil {
+			return nil, err
+		}
+		SortActivities(activities)
+		tree.Days = append(tree.Days, &PlanTreeDay{Day: day, Activities: activities})
+	}
+	return tree, nil
+}
+
+// SortActivities 按开始时间排序活动
+func SortActivities(activities []*models.Activity) {
+	sort.SliceStable(activities, func(i, j int) bool {
+		if !activities[i].StartTime.Equal(activities[j].StartTime) {
+			return activities[i].StartTime.Before(activities[j].StartTime)
+		}
+		return activities[i].CreatedAt.Before(activities[j].CreatedAt)
+	})
+}
+
+// BuildActivity 根据日期和 HH:MM 时间构建活动记录
+func BuildActivity(dayID string, date time.Time, timeOfDay, activityType, title, description, location string, cost float64) *models.Activity {
+	now := time.Now()
+	return &models.Activity{
+		ID:          uuid.New().String(),
+		DayID:       dayID,
+		Type:        activityType,
+		Title:       title,
+		Description: description,
+		Location:    location,
+		StartTime:   ParseTimeOfDay(date, timeOfDay),
+		Cost:        cost,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// ParseTimeOfDay 将 HH:MM 解析为指定日期的时间，解析失败时返回当天零点
+func ParseTimeOfDay(date time.Time, timeOfDay string) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(timeOfDay), "%d:%d", &hour, &minute); err != nil {
+		return day
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return day
+	}
+	return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
 // CreateExpense 创建费用记录
 func (s *TravelService) CreateExpense(expense *models.Expense) error {
 	return s.db.CreateExpense(expense)
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/models"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LLM调用场景，用于用量报表分类
+const (
+	UsageEndpointPlanGeneration     = "plan_generation"
+	UsageEndpointVoiceUnderstanding = "voice_understanding"
+	UsageEndpointBudgetAnalysis     = "budget_analysis"
+)
+
+// API Key 来源
+const (
+	KeySourceServer = "server"
+	KeySourceUser   = "user"
+)
+
+type UsageService struct {
+	config *config.Config
+	db     *MemoryDB
+
+	// reserveMutex 保证配额检查和预留用量记录之间不会插入其他预留
+	reserveMutex sync.Mutex
+}
+
+func NewUsageService(cfg *config.Config) *UsageService {
+	return &UsageService{
+		config: cfg,
+		db:     NewMemoryDB(),
+	}
+}
+
+// RecordLLMUsage 记录一次已完成的LLM调用的token用量和估算费用
+func (s *UsageService) RecordLLMUsage(meta LLMCallMeta, model, keySource string, usage OpenAIUsage) (*models.LLMUsage, error) {
+	record := newUsageRecord(meta, model, keySource)
+	s.applyUsage(record, usage, models.UsageStatusSucceeded)
+	if err := s.db.CreateLLMUsage(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ReserveLLMUsage 在调用前预留一条用量记录（立即计入请求次数），调用结束后用 SettleLLMUsage 结算。
+// check 不为空时（如配额检查）与预留在同一把锁内执行，check 返回错误时不预留
+func (s *UsageService) ReserveLLMUsage(meta LLMCallMeta, model, keySource string, check func() error) (*models.LLMUsage, error) {
+	s.reserveMutex.Lock()
+	defer s.reserveMutex.Unlock()
+
+	if check != nil {
+		if err := check(); err != nil {
+			return nil, err
+		}
+	}
+	record := newUsageRecord(meta, model, keySource)
+	if err := s.db.CreateLLMUsage(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// SettleLLMUsage 按实际用量结算预留的记录，调用失败时记录保留并标记为 failed
+func (s *UsageService) SettleLLMUsage(id string, usage OpenAIUsage, failed bool) error {
+	status := models.UsageStatusSucceeded
+	if failed {
+		status = models.UsageStatusFailed
+	}
+	if !s.db.ModifyLLMUsage(id, func(record *models.LLMUsage) { s.applyUsage(record, usage, status) }) {
+		return fmt.Errorf("usage record %s not found", id)
+	}
+	return nil
+}
+
+// newUsageRecord 创建一条待结算的用量记录
+func newUsageRecord(meta LLMCallMeta, model, keySource string) *models.LLMUsage {
+	return &models.LLMUsage{
+		ID:        uuid.New().String(),
+		UserID:    meta.UserID,
+		PlanID:    meta.PlanID,
+		Endpoint:  meta.Endpoint,
+		Model:     model,
+		KeySource: keySource,
+		Status:    models.UsageStatusPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// applyUsage 将token用量和估算费用写入记录
+func (s *UsageService) applyUsage(record *models.LLMUsage, usage OpenAIUsage, status string) {
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	record.PromptTokens = usage.PromptTokens
+	record.CompletionTokens = usage.CompletionTokens
+	record.TotalTokens = totalTokens
+	record.Cost = s.EstimateCost(record.Model, usage.PromptTokens, usage.CompletionTokens)
+	record.Status = status
+}
+
+// EstimateCost 按配置的价格表估算费用，未配置价格的模型按 "default" 计算
+func (s *UsageService) EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	pricing, ok := s.config.APIs.OpenAI.Pricing[model]
+	if !ok {
+		pricing, ok = s.config.APIs.OpenAI.Pricing["default"]
+		if !ok {
+			return 0
+		}
+	}
+	return (float64(promptTokens)*pricing.PromptPerMillion +
+		float64(completionTokens)*pricing.CompletionPerMillion) / 1e6
+}
+
+// GetUserUsage 获取用户的用量报表（按场景和计划分类）
+func (s *UsageService) GetUserUsage(userID string) (*models.UsageReport, error) {
+	usages, err := s.db.GetLLMUsages(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.newReport()
+	report.ByPlan = make(map[string]*models.UsageTotals)
+	for _, usage := range usages {
+		report.Total.Add(usage)
+		addUsageTo(report.ByEndpoint, usage.Endpoint, usage)
+		if usage.PlanID != "" {
+			addUsageTo(report.ByPlan, usage.PlanID, usage)
+		}
+	}
+	return report, nil
+}
+
+// GetUsageReport 获取全站用量报表（按场景和用户分类）
+func (s *UsageService) GetUsageReport() (*models.UsageReport, error) {
+	usages, err := s.db.GetLLMUsages("")
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.newReport()
+	report.ByUser = make(map[string]*models.UsageTotals)
+	for _, usage := range usages {
+		report.Total.Add(usage)
+		addUsageTo(report.ByEndpoint, usage.Endpoint, usage)
+		addUsageTo(report.ByUser, usage.UserID, usage)
+	}
+	return report, nil
+}
+
+// GetServerKeyUsageSince 统计用户自某时间起使用服务端Key的用量
+func (s *UsageService) GetServerKeyUsageSince(userID string, since time.Time) (models.UsageTotals, error) {
+	var totals models.UsageTotals
+	usages, err := s.db.GetLLMUsages(userID)
+	if err != nil {
+		return totals, err
+	}
+
+	for _, usage := range usages {
+		if usage.KeySource == KeySourceServer && !usage.CreatedAt.Before(since) {
+			totals.Add(usage)
+		}
+	}
+	return totals, nil
+}
+
+func (s *UsageService) newReport() *models.UsageReport {
+	return &models.UsageReport{
+		Currency:   s.config.APIs.OpenAI.PricingCurrency,
+		ByEndpoint: make(map[string]*models.UsageTotals),
+	}
+}
+
+// addUsageTo 将用量累加到分组中
+func addUsageTo(groups map[string]*models.UsageTotals, key string, usage *models.LLMUsage) {
+	totals, ok := groups[key]
+	if !ok {
+		totals = &models.UsageTotals{}
+		groups[key] = totals
+	}
+	totals.Add(usage)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"ai-travel-planner/internal/config"
+	"math"
+	"testing"
+)
+
+func newTestUsageService() *UsageService {
+	cfg := &config.Config{
+		APIs: config.APIConfig{
+			OpenAI: config.OpenAIConfig{
+				PricingCurrency: "USD",
+				Pricing: map[string]config.ModelPricing{
+					"gpt-4o-mini": {PromptPerMillion: 0.15, CompletionPerMillion: 0.6},
+					"default":     {PromptPerMillion: 1, CompletionPerMillion: 2},
+				},
+			},
+		},
+	}
+	return NewUsageService(cfg)
+}
+
+func TestUsageService_EstimateCost(t *testing.T) {
+	usageService := newTestUsageService()
+
+	cost := usageService.EstimateCost("gpt-4o-mini", 1000000, 1000000)
+	if math.Abs(cost-0.75) > 1e-9 {
+		t.Errorf("Expected cost 0.75, got %f", cost)
+	}
+
+	// 未配置的模型使用 default 价格
+	cost = usageService.EstimateCost("unknown-model", 500000, 250000)
+	if math.Abs(cost-1.0) > 1e-9 {
+		t.Errorf("Expected default cost 1.0, got %f", cost)
+	}
+}
+
+func TestUsageService_Reports(t *testing.T) {
+	usageService := newTestUsageService()
+
+	records := []struct {
+		meta  LLMCallMeta
+		usage OpenAIUsage
+	}{
+		{LLMCallMeta{UserID: "u1", PlanID: "p1", Endpoint: UsageEndpointPlanGeneration}, OpenAIUsage{PromptTokens: 100, CompletionTokens: 200}},
+		{LLMCallMeta{UserID: "u1", Endpoint: UsageEndpointVoiceUnderstanding}, OpenAIUsage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30}},
+		{LLMCallMeta{UserID: "u2", PlanID: "p2", Endpoint: UsageEndpointPlanGeneration}, OpenAIUsage{PromptTokens: 50, CompletionTokens: 50}},
+	}
+	for _, r := range records {
+		if _, err := usageService.RecordLLMUsage(r.meta, "gpt-4o-mini", KeySourceServer, r.usage); err != nil {
+			t.Fatalf("RecordLLMUsage failed: %v", err)
+		}
+	}
+
+	userReport, err := usageService.GetUserUsage("u1")
+	if err != nil {
+		t.Fatalf("GetUserUsage failed: %v", err)
+	}
+	if userReport.Total.Requests != 2 || userReport.Total.TotalTokens != 330 {
+		t.Errorf("Unexpected user totals: %+v", userReport.Total)
+	}
+	if userReport.ByPlan["p1"] == nil || userReport.ByPlan["p1"].TotalTokens != 300 {
+		t.Errorf("Expected plan p1 to have 300 tokens, got %+v", userReport.ByPlan["p1"])
+	}
+
+	adminReport, err := usageService.GetUsageReport()
+	if err != nil {
+		t.Fatalf("GetUsageReport failed: %v", err)
+	}
+	if adminReport.ByEndpoint[UsageEndpointPlanGeneration].Requests != 2 {
+		t.Errorf("Expected 2 plan generation requests, got %+v", adminReport.ByEndpoint[UsageEndpointPlanGeneration])
+	}
+	if len(adminReport.ByUser) != 2 {
+		t.Errorf("Expected 2 users in report, got %d", len(adminReport.ByUser))
+	}
+}
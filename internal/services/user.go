@@ -3,7 +3,12 @@ package services
 import (
 	"ai-travel-planner/internal/config"
 	"ai-travel-planner/internal/models"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type UserService struct {
@@ -59,3 +64,45 @@ func (s *UserService) GetUserProfile(userID string) (*models.UserProfile, error)
 func (s *UserService) UpdateUserProfile(userID string, updates map[string]interface{}) error {
 	return s.db.UpdateUserProfile(userID, updates)
 }
+
+// GetUserSettings 解析用户资料中保存的设置（API Key等），没有资料或设置时返回空
+func (s *UserService) GetUserSettings(userID string) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	profile, err := s.db.GetUserProfile(userID)
+	if err != nil || profile == nil || profile.Preferences == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(profile.Preferences), &settings); err != nil {
+		return make(map[string]interface{}), nil
+	}
+	return settings, nil
+}
+
+// AmapAPIKey 用户在设置中保存的高德地图API Key，没有保存时返回空
+func (s *UserService) AmapAPIKey(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	settings, _ := s.GetUserSettings(userID)
+	key, _ := settings["amap_api_key"].(string)
+	return strings.TrimSpace(key)
+}
+
+// EnsureUserProfile 获取用户资料，不存在时创建一个空资料
+func (s *UserService) EnsureUserProfile(userID string) (*models.UserProfile, error) {
+	profile, err := s.db.GetUserProfile(userID)
+	if err != nil || profile != nil {
+		return profile, err
+	}
+
+	profile = &models.UserProfile{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.CreateUserProfile(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
@@ -2,35 +2,69 @@ package main
 
 import (
 	"ai-travel-planner/internal/config"
+	"ai-travel-planner/internal/fakeopenai"
 	"ai-travel-planner/internal/handlers"
 	"ai-travel-planner/internal/middleware"
+	"ai-travel-planner/internal/prompts"
 	"ai-travel-planner/internal/services"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 子命令：启动本地的假 OpenAI 服务，用于测试和演示
+	if len(os.Args) > 1 && os.Args[1] == "fake-openai" {
+		if err := fakeopenai.Run(os.Args[2:]); err != nil {
+			log.Fatalf("假 OpenAI 服务退出: %v", err)
+		}
+		return
+	}
+
 	// 加载配置（从 YAML 文件）
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	// 加载提示词模板
+	promptStore, err := prompts.NewStore(cfg.Prompts)
+	if err != nil {
+		log.Fatalf("加载提示词模板失败: %v", err)
+	}
+	promptStore.Watch()
+
 	// 初始化服务
 	userService := services.NewUserService(cfg)
 	authService := services.NewAuthService(cfg)
 	travelService := services.NewTravelService(cfg)
 	voiceService := services.NewVoiceService(cfg)
-	llmService := services.NewLLMService(cfg)
-	mapService := services.NewAmapService(cfg)
+	usageService := services.NewUsageService(cfg)
+	quotaService := services.NewQuotaService(cfg, usageService)
+	guardrailService := services.NewGuardrailService(cfg)
+	llmCache, err := services.NewLLMResponseCache(cfg)
+	if err != nil {
+		log.Fatalf("初始化LLM响应缓存失败: %v", err)
+	}
+	llmService := services.NewLLMService(cfg, promptStore, usageService, quotaService, guardrailService, llmCache)
+	mapCache, err := services.NewMapCache(cfg)
+	if err != nil {
+		log.Fatalf("初始化地图查询缓存失败: %v", err)
+	}
+	mapService := services.NewAmapService(cfg).WithCache(mapCache)
+	mapRouter := services.NewMapRouter(mapService, services.NewOSMMapService(cfg))
+	offlinePlanner := services.NewOfflinePlanner(cfg.Planner.OfflineFallback, mapService)
 
 	// 初始化处理器
 	userHandler := handlers.NewUserHandler(userService, authService)
-	travelHandler := handlers.NewTravelHandler(travelService, llmService)
-	voiceHandler := handlers.NewVoiceHandler(voiceService)
+	travelHandler := handlers.NewTravelHandler(travelService, llmService, userService, mapRouter, offlinePlanner)
+	voiceHandler := handlers.NewVoiceHandler(voiceService, llmService)
 	settingsHandler := handlers.NewSettingsHandler(userService, llmService)
-	mapHandler := handlers.NewMapHandler(mapService)
+	mapHandler := handlers.NewMapHandler(mapRouter, userService)
+	usageHandler := handlers.NewUsageHandler(usageService, quotaService)
+	guardrailHandler := handlers.NewGuardrailHandler(guardrailService)
+	mapCacheHandler := handlers.NewMapCacheHandler(mapCache)
 
 	// 设置Gin模式
 	if cfg.GetMode() == "release" {
@@ -62,8 +96,8 @@ func main() {
 			auth.POST("/refresh", userHandler.RefreshToken)
 		}
 
-		// 公开的地图API Key接口（获取系统配置的API Key）
-		api.GET("/map/api-key", mapHandler.GetAmapApiKey)
+		// 公开的地图API Key接口（登录用户返回设置中保存的Key，否则返回系统配置的Key）
+		api.GET("/map/api-key", middleware.AuthOptional(authService), mapHandler.GetAmapApiKey)
 
 		// 需要认证的路由
 		protected := api.Group("/")
@@ -78,6 +112,10 @@ func main() {
 			protected.PUT("/settings", settingsHandler.UpdateSettings)
 			protected.POST("/settings/test-api-key", settingsHandler.TestApiKey)
 
+			// LLM用量
+			protected.GET("/usage", usageHandler.GetUsage)
+			protected.GET("/usage/quota", usageHandler.GetQuota)
+
 			// 旅行规划
 			travel := protected.Group("/travel")
 			{
@@ -85,7 +123,25 @@ func main() {
 				travel.GET("/plans", travelHandler.GetTravelPlans)
 				travel.GET("/plans/:id", travelHandler.GetTravelPlan)
 				travel.PUT("/plans/:id", travelHandler.UpdateTravelPlan)
+				travel.PUT("/plans/:id/recommendations", travelHandler.UpdatePlanRecommendations)
+				travel.POST("/plans/:id/geocode", travelHandler.GeocodePlanActivities)
 				travel.DELETE("/plans/:id", travelHandler.DeleteTravelPlan)
+				// 对话式修改行程
+				travel.GET("/plans/:id/chat", travelHandler.GetPlanChat)
+				travel.POST("/plans/:id/chat", travelHandler.ChatWithPlan)
+				travel.POST("/plans/:id/chat/:message_id/apply", travelHandler.ApplyPlanChatPatch)
+				// 翻译行程
+				travel.POST("/plans/:id/translate", travelHandler.TranslatePlan)
+				// 单日行程
+				travel.POST("/plans/:id/days/:n/regenerate", travelHandler.RegenerateDay)
+				travel.POST("/plans/:id/days/:n/optimize", travelHandler.OptimizeDay)
+				// 活动周边和沿途搜索
+				travel.GET("/plans/:id/activities/:activity_id/nearby", travelHandler.ActivityNearby)
+				travel.POST("/plans/:id/along-route", travelHandler.SearchBetweenActivities)
+				// 导出行程（GeoJSON、KML、GPX）
+				travel.GET("/plans/:id/export", travelHandler.ExportPlan)
+				// 预算分析
+				travel.POST("/plans/:id/budget-analysis", travelHandler.AnalyzePlanBudget)
 				// 费用
 				travel.GET("/expenses", travelHandler.GetExpenses)
 				travel.POST("/expenses", travelHandler.CreateExpense)
@@ -108,10 +164,25 @@ func main() {
 				mapGroup.POST("/geocode", mapHandler.Geocode)           // 地理编码
 				mapGroup.POST("/regeocode", mapHandler.Regeocode)       // 逆地理编码
 				mapGroup.POST("/search-poi", mapHandler.SearchPOI)      // POI搜索
+				mapGroup.POST("/nearby", mapHandler.Nearby)             // 周边搜索
+				mapGroup.POST("/along-route", mapHandler.AlongRoute)    // 沿途搜索
 				mapGroup.POST("/route", mapHandler.Route)               // 路线规划
 				mapGroup.POST("/distance", mapHandler.Distance)         // 距离计算
 			}
 
+			// 管理员功能
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminRequired(cfg))
+			{
+				admin.GET("/usage", usageHandler.GetUsageReport)
+				admin.GET("/quotas/:user_id", usageHandler.GetUserQuota)
+				admin.PUT("/quotas/:user_id", usageHandler.SetUserQuota)
+				admin.DELETE("/quotas/:user_id", usageHandler.DeleteUserQuota)
+				admin.GET("/guardrails/violations", guardrailHandler.GetViolations)
+				admin.GET("/map-cache", mapCacheHandler.GetStats)
+				admin.DELETE("/map-cache", mapCacheHandler.Purge)
+			}
+
 		}
 	}
 
@@ -2183,12 +2183,13 @@ class TravelPlannerApp {
                 }
             }
             
-            // 最后尝试从后端系统配置获取（公开接口，不需要认证）
+            // 最后尝试从后端获取（公开接口，已登录时带上token以使用设置中保存的Key）
             try {
                 const response = await fetch(`${this.apiBase}/map/api-key`, {
                     method: 'GET',
                     headers: {
                         'Content-Type': 'application/json',
+                        'Authorization': this.token ? `Bearer ${this.token}` : ''
                     }
                 });
                 if (response.ok) {